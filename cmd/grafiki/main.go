@@ -69,18 +69,23 @@ func main() {
 	}
 
 	tmpl := template.Must(template.New("gallery").Parse(app.PageTemplate))
+	template.Must(tmpl.New("image").Parse(app.ImageShareTemplate))
+	template.Must(tmpl.New("embed").Parse(app.EmbedTemplate))
 	srv, err := app.NewServer(app.ServerOptions{
-		Dir:      dir,
-		Config:   cfg,
-		Template: tmpl,
-		Sessions: app.NewSessionStore(15 * time.Minute),
-		Logger:   reqLogger,
-		DB:       db,
-		Favicon:  faviconPath,
+		Dir:           dir,
+		Config:        cfg,
+		Template:      tmpl,
+		Sessions:      app.NewSessionStore(15 * time.Minute),
+		Logger:        reqLogger,
+		DB:            db,
+		Favicon:       faviconPath,
+		QuarantineDir: filepath.Join(filepath.Dir(configPath), "quarantine"),
+		PreviewDir:    filepath.Join(filepath.Dir(configPath), "previews"),
 	})
 	if err != nil {
 		log.Fatalf("init server: %v", err)
 	}
+	srv.StartWebhookWorker()
 
 	mux := http.NewServeMux()
 	srv.RegisterRoutes(mux)
@@ -92,7 +97,7 @@ func main() {
 		configPath,
 		logsPath,
 	)
-	if err := http.ListenAndServe(*addrFlag, mux); err != nil {
+	if err := http.ListenAndServe(*addrFlag, srv.WithClientAddr(mux)); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
 }
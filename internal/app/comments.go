@@ -0,0 +1,524 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	commentMaxLength       = 2000
+	commentAuthorMaxLength = 60
+	commentMaxDepth        = 4
+
+	commentMaxAttempts = 10
+	commentWindow      = 10 * time.Minute
+
+	// commentAdminAuthor is shown instead of the login name, which is half
+	// of the admin credentials.
+	commentAdminAuthor = "Administrator"
+)
+
+var (
+	errCommentEmpty         = errors.New("komentarz nie moze byc pusty")
+	errCommentTooLong       = errors.New("komentarz jest za dlugi")
+	errCommentAuthor        = errors.New("podaj swoje imie")
+	errCommentAuthorTooLong = errors.New("imie jest za dlugie")
+	errCommentParent        = errors.New("komentarz, na ktory odpowiadasz, nie istnieje")
+)
+
+type commentRecord struct {
+	ID        int64
+	FolderID  sql.NullInt64
+	Image     sql.NullString
+	EntryID   sql.NullInt64
+	ParentID  sql.NullInt64
+	Author    string
+	Admin     bool
+	Body      string
+	Hidden    bool
+	CreatedAt time.Time
+}
+
+type commentView struct {
+	ID        int64  `json:"id"`
+	ParentID  int64  `json:"parentId,omitempty"`
+	Depth     int    `json:"depth"`
+	Author    string `json:"author"`
+	Admin     bool   `json:"admin"`
+	Body      string `json:"body"`
+	Hidden    bool   `json:"hidden"`
+	CreatedAt string `json:"createdAt"`
+}
+
+const commentColumns = `id, folder_id, image, entry_id, parent_id, author, admin, body, hidden, created_at`
+
+func scanComment(row rowScanner) (*commentRecord, error) {
+	var rec commentRecord
+	if err := row.Scan(&rec.ID, &rec.FolderID, &rec.Image, &rec.EntryID, &rec.ParentID, &rec.Author, &rec.Admin,
+		&rec.Body, &rec.Hidden, &rec.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (c commentRecord) toView(depth int) commentView {
+	author := c.Author
+	if c.Admin {
+		author = commentAdminAuthor
+	}
+	return commentView{
+		ID:        c.ID,
+		ParentID:  c.ParentID.Int64,
+		Depth:     min(depth, commentMaxDepth),
+		Author:    author,
+		Admin:     c.Admin,
+		Body:      c.Body,
+		Hidden:    c.Hidden,
+		CreatedAt: c.CreatedAt.Local().Format("2006-01-02 15:04"),
+	}
+}
+
+// commentTarget is the thing being discussed: a gallery image or a
+// submission entry.
+type commentTarget struct {
+	folder *folderRecord
+	image  string
+	entry  *submissionEntryRecord
+	group  *submissionGroupRecord
+}
+
+func (t commentTarget) where() (string, []any) {
+	if t.entry != nil {
+		return `entry_id = ?`, []any{t.entry.ID}
+	}
+	return `folder_id = ? AND image = ?`, []any{t.folder.ID, t.image}
+}
+
+func (t commentTarget) owns(c *commentRecord) bool {
+	if t.entry != nil {
+		return c.EntryID.Valid && c.EntryID.Int64 == t.entry.ID
+	}
+	return c.FolderID.Valid && c.FolderID.Int64 == t.folder.ID && c.Image.String == t.image
+}
+
+// openToVisitors reports whether anonymous viewers may post. Admins can
+// always comment.
+func (t commentTarget) openToVisitors() bool {
+	if t.entry != nil {
+		return t.group.CommentsOpen && t.group.Visibility == visibilityPublic && t.entry.Status == submissionStatusApproved
+	}
+	return t.folder.CommentsOpen && t.folder.Visibility == visibilityPublic
+}
+
+// listComments returns the thread in reading order with replies right after
+// their parent. Visitors do not see hidden comments; a hidden comment that
+// still has visible replies is kept as an empty placeholder.
+func (s *Server) listComments(target commentTarget, loggedIn bool) ([]commentView, error) {
+	where, args := target.where()
+	rows, err := s.db.Query(`SELECT `+commentColumns+` FROM comments WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	var roots []*commentRecord
+	replies := map[int64][]*commentRecord{}
+	for rows.Next() {
+		rec, err := scanComment(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if rec.ParentID.Valid {
+			replies[rec.ParentID.Int64] = append(replies[rec.ParentID.Int64], rec)
+		} else {
+			roots = append(roots, rec)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	var walk func(c *commentRecord, depth int) []commentView
+	walk = func(c *commentRecord, depth int) []commentView {
+		var children []commentView
+		for _, reply := range replies[c.ID] {
+			children = append(children, walk(reply, depth+1)...)
+		}
+		view := c.toView(depth)
+		if c.Hidden && !loggedIn {
+			if len(children) == 0 {
+				return nil
+			}
+			view.Author, view.Body, view.Admin = "", "", false
+		}
+		return append([]commentView{view}, children...)
+	}
+	comments := []commentView{}
+	for _, root := range roots {
+		comments = append(comments, walk(root, 0)...)
+	}
+	return comments, nil
+}
+
+func countVisibleComments(comments []commentView, loggedIn bool) int {
+	count := 0
+	for _, c := range comments {
+		if loggedIn || !c.Hidden {
+			count++
+		}
+	}
+	return count
+}
+
+// folderCommentCounts maps image names to their number of comments.
+func (s *Server) folderCommentCounts(folderID int64, loggedIn bool) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT image, COUNT(*) FROM comments WHERE folder_id = ? AND (? OR hidden = 0) GROUP BY image`, folderID, loggedIn)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	for rows.Next() {
+		var image string
+		var count int
+		if err := rows.Scan(&image, &count); err != nil {
+			return nil, err
+		}
+		counts[image] = count
+	}
+	return counts, rows.Err()
+}
+
+func (s *Server) applyImageCommentCounts(folder *folderRecord, images []imageInfo, loggedIn bool) {
+	if folder == nil || len(images) == 0 {
+		return
+	}
+	counts, err := s.folderCommentCounts(folder.ID, loggedIn)
+	if err != nil {
+		log.Printf("comment counts: %v", err)
+		return
+	}
+	for i := range images {
+		images[i].Comments = counts[images[i].Name]
+	}
+}
+
+// applySubmissionComments turns on the comment threads of the listed entries
+// and fills in their comment counts.
+func (s *Server) applySubmissionComments(data *pageData, group *submissionGroupRecord, loggedIn bool) {
+	if group == nil {
+		return
+	}
+	data.SubmissionComments = true
+	rows, err := s.db.Query(`SELECT c.entry_id, COUNT(*) FROM comments c JOIN submissions s ON s.id = c.entry_id
+		WHERE s.group_id = ? AND (? OR c.hidden = 0) GROUP BY c.entry_id`, group.ID, loggedIn)
+	if err != nil {
+		log.Printf("submission comment counts: %v", err)
+		return
+	}
+	counts := map[int64]int{}
+	for rows.Next() {
+		var entryID int64
+		var count int
+		if err := rows.Scan(&entryID, &count); err != nil {
+			log.Printf("submission comment counts: %v", err)
+			break
+		}
+		counts[entryID] = count
+	}
+	rows.Close()
+	for i := range data.SubmissionEntries {
+		data.SubmissionEntries[i].Comments = counts[data.SubmissionEntries[i].ID]
+	}
+}
+
+func (s *Server) updateFolderCommentsOpen(id int64, open bool) (*folderRecord, error) {
+	if _, err := s.db.Exec(`UPDATE folders SET comments_open = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, open, id); err != nil {
+		return nil, err
+	}
+	return s.getFolderByID(id)
+}
+
+func (s *Server) updateSubmissionGroupCommentsOpen(id int64, open bool) (*submissionGroupRecord, error) {
+	if _, err := s.db.Exec(`UPDATE submission_groups SET comments_open = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, open, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
+func (s *Server) renameImageComments(folderID int64, oldName, newName string) error {
+	_, err := s.db.Exec(`UPDATE comments SET image = ? WHERE folder_id = ? AND image = ?`, newName, folderID, oldName)
+	return err
+}
+
+func (s *Server) deleteImageComments(folderID int64, image string) error {
+	_, err := s.db.Exec(`DELETE FROM comments WHERE folder_id = ? AND image = ?`, folderID, image)
+	return err
+}
+
+func (s *Server) deleteFolderComments(folderID int64) error {
+	_, err := s.db.Exec(`DELETE FROM comments WHERE folder_id = ?`, folderID)
+	return err
+}
+
+func (s *Server) deleteSubmissionComments(entryID int64) {
+	if _, err := s.db.Exec(`DELETE FROM comments WHERE entry_id = ?`, entryID); err != nil {
+		log.Printf("delete submission comments: %v", err)
+	}
+}
+
+type commentRequest struct {
+	Folder   string `json:"folder"`
+	Name     string `json:"name"`
+	Entry    int64  `json:"entry"`
+	ParentID int64  `json:"parentId"`
+	Author   string `json:"author"`
+	Body     string `json:"body"`
+}
+
+// commentTargetFor resolves the image or entry of a request and checks that
+// the viewer can see it.
+func (s *Server) commentTargetFor(w http.ResponseWriter, r *http.Request, req *commentRequest, loggedIn bool) (commentTarget, bool) {
+	if req.Entry != 0 {
+		entry, group, err := s.getSubmissionEntry(req.Entry)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("comment entry: %v", err)
+			}
+			writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+			return commentTarget{}, false
+		}
+		if !s.submissionEntryViewable(w, r, entry, group) {
+			writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+			return commentTarget{}, false
+		}
+		return commentTarget{entry: entry, group: group}, true
+	}
+
+	folder, err := s.getFolderBySlug(sanitizeFilename(strings.TrimSpace(req.Folder)))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("comment folder: %v", err)
+		}
+		writeJSONError(w, http.StatusNotFound, "Folder nie istnieje")
+		return commentTarget{}, false
+	}
+	if !s.canAccessFolder(folder, loggedIn) {
+		writeJSONError(w, http.StatusNotFound, "Folder nie istnieje")
+		return commentTarget{}, false
+	}
+	path, err := s.folderImagePath(folder, req.Name)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+		return commentTarget{}, false
+	}
+	return commentTarget{folder: folder, image: filepath.Base(path)}, true
+}
+
+// handleComments lists the thread of an image (?folder=&name=) or a
+// submission entry (?entry=) and adds comments to it with POST.
+func (s *Server) handleComments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	loggedIn := s.sessions.authenticated(w, r)
+
+	req := &commentRequest{}
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req.Folder = query.Get("folder")
+		req.Name = query.Get("name")
+		if raw := query.Get("entry"); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id <= 0 {
+				writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy plik")
+				return
+			}
+			req.Entry = id
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+
+	target, ok := s.commentTargetFor(w, r, req, loggedIn)
+	if !ok {
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.createComment(w, r, target, req, loggedIn)
+		return
+	}
+
+	comments, err := s.listComments(target, loggedIn)
+	if err != nil {
+		log.Printf("list comments: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac komentarzy")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"comments":    comments,
+		"count":       countVisibleComments(comments, loggedIn),
+		"canComment":  loggedIn || target.openToVisitors(),
+		"canModerate": loggedIn,
+	})
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request, target commentTarget, req *commentRequest, loggedIn bool) {
+	if !loggedIn && !target.openToVisitors() {
+		writeJSONError(w, http.StatusForbidden, "Komentowanie jest wylaczone")
+		return
+	}
+
+	limiterKey := "comment|" + clientIP(r)
+	if !loggedIn {
+		if blocked, wait := s.commentLimiter.blocked(limiterKey); blocked {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Zbyt wiele komentarzy. Sprobuj ponownie za %d min.", int(wait.Minutes())+1))
+			return
+		}
+	}
+
+	body := strings.TrimSpace(req.Body)
+	author := strings.TrimSpace(req.Author)
+	var err error
+	switch {
+	case body == "":
+		err = errCommentEmpty
+	case utf8.RuneCountInString(body) > commentMaxLength:
+		err = errCommentTooLong
+	case loggedIn:
+		author = commentAdminAuthor
+	case author == "":
+		err = errCommentAuthor
+	case utf8.RuneCountInString(author) > commentAuthorMaxLength:
+		err = errCommentAuthorTooLong
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var parentID sql.NullInt64
+	if req.ParentID != 0 {
+		parent, err := scanComment(s.db.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE id = ?`, req.ParentID))
+		if err != nil || !target.owns(parent) || (parent.Hidden && !loggedIn) {
+			writeJSONError(w, http.StatusBadRequest, errCommentParent.Error())
+			return
+		}
+		parentID = sql.NullInt64{Int64: parent.ID, Valid: true}
+	}
+
+	if !loggedIn {
+		s.commentLimiter.fail(limiterKey)
+	}
+	var folderID, entryID sql.NullInt64
+	var image sql.NullString
+	if target.entry != nil {
+		entryID = sql.NullInt64{Int64: target.entry.ID, Valid: true}
+	} else {
+		folderID = sql.NullInt64{Int64: target.folder.ID, Valid: true}
+		image = sql.NullString{String: target.image, Valid: true}
+	}
+	res, err := s.db.Exec(`INSERT INTO comments (folder_id, image, entry_id, parent_id, author, admin, body) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		folderID, image, entryID, parentID, author, loggedIn, body)
+	if err != nil {
+		log.Printf("create comment: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac komentarza")
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		log.Printf("create comment: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac komentarza")
+		return
+	}
+	comment, err := scanComment(s.db.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE id = ?`, id))
+	if err != nil {
+		log.Printf("create comment: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac komentarza")
+		return
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "komentarz")
+	}
+	writeJSON(w, http.StatusCreated, comment.toView(0))
+}
+
+// handleCommentByID lets admins moderate a single comment:
+// PATCH /api/comments/<id> with {"hidden": bool} or DELETE, which also
+// removes the replies.
+func (s *Server) handleCommentByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "PATCH, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/comments/"), "/"), 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy komentarz")
+		return
+	}
+	comment, err := scanComment(s.db.QueryRow(`SELECT `+commentColumns+` FROM comments WHERE id = ?`, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Komentarz nie istnieje")
+			return
+		}
+		log.Printf("get comment: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac komentarza")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		res, err := s.db.Exec(`WITH RECURSIVE thread(id) AS (
+			SELECT id FROM comments WHERE id = ?
+			UNION ALL SELECT c.id FROM comments c JOIN thread t ON c.parent_id = t.id
+		) DELETE FROM comments WHERE id IN (SELECT id FROM thread)`, comment.ID)
+		if err != nil {
+			log.Printf("delete comment: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac komentarza")
+			return
+		}
+		deleted, _ := res.RowsAffected()
+		if s.logger != nil {
+			s.logger.Log(r, "usunkom")
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "deleted": deleted})
+		return
+	}
+
+	var req struct {
+		Hidden *bool `json:"hidden"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Hidden == nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	if _, err := s.db.Exec(`UPDATE comments SET hidden = ? WHERE id = ?`, *req.Hidden, comment.ID); err != nil {
+		log.Printf("hide comment: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac komentarza")
+		return
+	}
+	comment.Hidden = *req.Hidden
+	if s.logger != nil {
+		s.logger.Log(r, "ukryjkom")
+	}
+	writeJSON(w, http.StatusOK, comment.toView(0))
+}
@@ -13,6 +13,16 @@ import (
 type Config struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
+	// Clamd enables malware scanning of uploads, e.g. "tcp://127.0.0.1:3310"
+	// or "unix:///run/clamav/clamd.ctl".
+	Clamd string `json:"clamd,omitempty"`
+	// Pdftoppm points at the poppler tool used to render PDF previews. When
+	// empty it is looked up on PATH.
+	Pdftoppm string `json:"pdftoppm,omitempty"`
+	// TrustedProxies lists reverse proxy addresses or CIDR ranges whose
+	// X-Forwarded-For and CF-Connecting-IP headers are believed. Without it
+	// the socket address identifies the client.
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
 }
 
 func EnsureDir(path string) error {
@@ -45,6 +55,8 @@ func LoadOrCreateConfig(path string) (Config, bool, error) {
 
 	cfg.Username = strings.TrimSpace(cfg.Username)
 	cfg.Password = strings.TrimSpace(cfg.Password)
+	cfg.Clamd = strings.TrimSpace(cfg.Clamd)
+	cfg.Pdftoppm = strings.TrimSpace(cfg.Pdftoppm)
 	if cfg.Username == "" || cfg.Password == "" {
 		return Config{}, false, errors.New("config requires non-empty username and password")
 	}
@@ -1,8 +1,11 @@
 package app
 
 const (
-	sessionCookieName             = "gallery_session"
-	uploadMaxSize           int64 = 32 << 20 // 32 MB
-	submissionUploadMaxSize       = 10 << 20 // 10 MB
-	submissionViewerCookie        = "submission_viewer"
+	sessionCookieName               = "gallery_session"
+	uploadMaxSize             int64 = 32 << 20  // 32 MB
+	submissionUploadMaxSize         = 10 << 20  // 10 MB
+	submissionUploadHardLimit       = 100 << 20 // upper bound for per-group limits
+	submissionGroupHardLimit  int64 = 1 << 40   // upper bound for the total size of a group
+	submissionViewerCookie          = "submission_viewer"
+	submissionVoterCookie           = "submission_voter"
 )
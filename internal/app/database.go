@@ -2,13 +2,20 @@ package app
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	_ "modernc.org/sqlite"
 )
 
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
 func OpenDatabase(path string) (*sql.DB, error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, err
@@ -85,8 +92,286 @@ func migrateDatabase(db *sql.DB) error {
 
 	CREATE INDEX IF NOT EXISTS idx_submissions_group ON submissions(group_id);
 	CREATE INDEX IF NOT EXISTS idx_submissions_created ON submissions(created_at DESC);
+
+	CREATE TABLE IF NOT EXISTS share_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_type TEXT NOT NULL,
+		target_id INTEGER NOT NULL,
+		label TEXT NOT NULL,
+		token TEXT NOT NULL UNIQUE,
+		views INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME,
+		max_views INTEGER,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		allow_view INTEGER NOT NULL DEFAULT 1,
+		allow_download INTEGER NOT NULL DEFAULT 1,
+		allow_upload INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_share_links_target ON share_links(target_type, target_id);
+
+	CREATE TABLE IF NOT EXISTS share_visits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_type TEXT NOT NULL,
+		target_id INTEGER NOT NULL,
+		link_id INTEGER,
+		visitor_hash TEXT NOT NULL,
+		user_agent TEXT NOT NULL DEFAULT '',
+		referrer TEXT NOT NULL DEFAULT '',
+		is_unique INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_share_visits_target ON share_visits(target_type, target_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_share_visits_visitor ON share_visits(target_type, target_id, visitor_hash, created_at);
+
+	CREATE TABLE IF NOT EXISTS share_image_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_type TEXT NOT NULL,
+		target_id INTEGER NOT NULL,
+		link_id INTEGER,
+		visitor_hash TEXT NOT NULL,
+		image TEXT NOT NULL,
+		action TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_share_image_events_target ON share_image_events(target_type, target_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS image_shares (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		folder_id INTEGER NOT NULL,
+		image TEXT NOT NULL,
+		token TEXT NOT NULL UNIQUE,
+		views INTEGER NOT NULL DEFAULT 0,
+		expires_at DATETIME,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(folder_id, image)
+	);
+
+	CREATE TABLE IF NOT EXISTS image_attributions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		folder_id INTEGER NOT NULL,
+		image TEXT NOT NULL,
+		author TEXT NOT NULL,
+		original_name TEXT NOT NULL DEFAULT '',
+		submission_id INTEGER,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(folder_id, image)
+	);
+
+	CREATE TABLE IF NOT EXISTS submission_changes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		submission_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		detail TEXT NOT NULL DEFAULT '',
+		uploader_name TEXT NOT NULL DEFAULT '',
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_submission_changes_group ON submission_changes(group_id, created_at);
+
+	CREATE TABLE IF NOT EXISTS submission_receipts (
+		code TEXT PRIMARY KEY,
+		group_id INTEGER NOT NULL,
+		contributor_token TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE(group_id, contributor_token)
+	);
+
+	CREATE TABLE IF NOT EXISTS submission_invites (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		code TEXT NOT NULL UNIQUE,
+		contributor_token TEXT NOT NULL UNIQUE,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS submission_votes (
+		group_id INTEGER NOT NULL,
+		entry_id INTEGER NOT NULL,
+		viewer_token TEXT NOT NULL,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (group_id, viewer_token)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_submission_votes_entry ON submission_votes(entry_id);
+
+	CREATE TABLE IF NOT EXISTS submission_scores (
+		entry_id INTEGER NOT NULL,
+		juror TEXT NOT NULL,
+		score INTEGER NOT NULL,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (entry_id, juror)
+	);
+
+	CREATE TABLE IF NOT EXISTS comments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		folder_id INTEGER,
+		image TEXT,
+		entry_id INTEGER,
+		parent_id INTEGER,
+		author TEXT NOT NULL,
+		admin INTEGER NOT NULL DEFAULT 0,
+		body TEXT NOT NULL,
+		hidden INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_comments_image ON comments(folder_id, image);
+	CREATE INDEX IF NOT EXISTS idx_comments_entry ON comments(entry_id);
+
+	CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER,
+		last_error TEXT,
+		next_attempt_at INTEGER NOT NULL DEFAULT 0,
+		delivered_at DATETIME,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	);
 	`
 
-	_, err := db.Exec(schema)
+	if _, err := db.Exec(schema); err != nil {
+		return err
+	}
+
+	columns := []struct {
+		table      string
+		name       string
+		definition string
+	}{
+		{"folders", "shared_expires_at", "DATETIME"},
+		{"folders", "shared_max_views", "INTEGER"},
+		{"submission_groups", "shared_expires_at", "DATETIME"},
+		{"submission_groups", "shared_max_views", "INTEGER"},
+		{"folders", "shared_password_hash", "TEXT"},
+		{"submission_groups", "shared_password_hash", "TEXT"},
+		{"submission_groups", "opens_at", "DATETIME"},
+		{"submission_groups", "closes_at", "DATETIME"},
+		{"submission_groups", "max_file_size", "INTEGER"},
+		{"submission_groups", "allowed_types", "TEXT"},
+		{"submission_groups", "max_files_per_contributor", "INTEGER"},
+		{"submission_groups", "max_group_size", "INTEGER"},
+		{"submission_groups", "min_image_width", "INTEGER"},
+		{"submission_groups", "min_image_height", "INTEGER"},
+		{"submissions", "status", "TEXT NOT NULL DEFAULT 'approved'"},
+		{"submissions", "review_note", "TEXT"},
+		{"submissions", "reviewed_at", "DATETIME"},
+		{"submissions", "published_folder_id", "INTEGER"},
+		{"submissions", "published_image", "TEXT"},
+		{"submissions", "published_at", "DATETIME"},
+		{"submission_groups", "form_fields", "TEXT"},
+		{"submissions", "field_values", "TEXT"},
+		{"submission_changes", "actor", "TEXT NOT NULL DEFAULT 'contributor'"},
+		{"submission_groups", "close_notified_at", "DATETIME"},
+		{"submissions", "scan_status", "TEXT"},
+		{"submissions", "scan_signature", "TEXT"},
+		{"submissions", "pdf_pages", "INTEGER"},
+		{"submissions", "pdf_title", "TEXT"},
+		{"submissions", "pdf_author", "TEXT"},
+		{"submission_groups", "invite_only", "INTEGER NOT NULL DEFAULT 0"},
+		{"submission_groups", "visitor_voting", "INTEGER NOT NULL DEFAULT 0"},
+		{"submission_groups", "jury_scoring", "INTEGER NOT NULL DEFAULT 0"},
+		{"submission_groups", "hide_results", "INTEGER NOT NULL DEFAULT 0"},
+		{"submission_groups", "comments_open", "INTEGER NOT NULL DEFAULT 0"},
+		{"folders", "comments_open", "INTEGER NOT NULL DEFAULT 0"},
+		{"submission_votes", "client_hash", "TEXT"},
+	}
+	hadCloseNotified, err := columnExists(db, "submission_groups", "close_notified_at")
+	if err != nil {
+		return err
+	}
+	for _, col := range columns {
+		if err := addColumnIfMissing(db, col.table, col.name, col.definition); err != nil {
+			return fmt.Errorf("migrate %s.%s: %w", col.table, col.name, err)
+		}
+	}
+	// Groups that closed before close notifications existed would all fire
+	// group.closed on the first start.
+	if !hadCloseNotified {
+		if err := markClosedGroupsNotified(db, time.Now()); err != nil {
+			return fmt.Errorf("migrate close notifications: %w", err)
+		}
+	}
+	return nil
+}
+
+func addColumnIfMissing(db *sql.DB, table, column, definition string) error {
+	exists, err := columnExists(db, table, column)
+	if err != nil || exists {
+		return err
+	}
+	_, err = db.Exec(fmt.Sprintf(`ALTER TABLE %s ADD COLUMN %s %s`, table, column, definition))
 	return err
 }
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	rows, err := db.Query(fmt.Sprintf(`PRAGMA table_info(%s)`, table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			return false, err
+		}
+		if strings.EqualFold(name, column) {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+func loadOrCreateSetting(db *sql.DB, key string, create func() (string, error)) (string, error) {
+	var value string
+	err := db.QueryRow(`SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if err == nil {
+		return value, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	value, err = create()
+	if err != nil {
+		return "", err
+	}
+	if _, err := db.Exec(`INSERT INTO settings (key, value) VALUES (?, ?)`, key, value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
@@ -0,0 +1,196 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	embedDefaultColumns = 3
+	embedMaxColumns     = 6
+)
+
+var (
+	embedLayouts    = []string{"grid", "masonry", "strip"}
+	errEmbedOptions = errors.New("nieprawidlowe parametry osadzenia")
+)
+
+type embedOptions struct {
+	Layout   string
+	Columns  int
+	Captions bool
+}
+
+func parseEmbedOptions(query url.Values) (embedOptions, error) {
+	opts := embedOptions{Layout: embedLayouts[0], Columns: embedDefaultColumns}
+
+	if layout := strings.ToLower(strings.TrimSpace(query.Get("layout"))); layout != "" {
+		valid := false
+		for _, candidate := range embedLayouts {
+			if layout == candidate {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return opts, errEmbedOptions
+		}
+		opts.Layout = layout
+	}
+
+	if raw := strings.TrimSpace(query.Get("columns")); raw != "" {
+		columns, err := strconv.Atoi(raw)
+		if err != nil || columns < 1 || columns > embedMaxColumns {
+			return opts, errEmbedOptions
+		}
+		opts.Columns = columns
+	}
+
+	switch strings.ToLower(query.Get("captions")) {
+	case "", "0", "false":
+	case "1", "true":
+		opts.Captions = true
+	default:
+		return opts, errEmbedOptions
+	}
+	return opts, nil
+}
+
+// query returns the options that differ from the defaults, so embed URLs
+// stay short.
+func (o embedOptions) query() string {
+	values := url.Values{}
+	if o.Layout != embedLayouts[0] {
+		values.Set("layout", o.Layout)
+	}
+	if o.Columns != embedDefaultColumns {
+		values.Set("columns", strconv.Itoa(o.Columns))
+	}
+	if o.Captions {
+		values.Set("captions", "1")
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+type embedPage struct {
+	Title         string
+	PageURL       string
+	Images        []imageInfo
+	Layout        string
+	Columns       int
+	Captions      bool
+	AllowDownload bool
+	Message       string
+}
+
+func (s *Server) renderEmbed(w http.ResponseWriter, status int, page embedPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := s.tmpl.ExecuteTemplate(w, "embed", page); err != nil {
+		log.Printf("template execute: %v", err)
+	}
+}
+
+// handleEmbed serves the iframe gallery at /embed/<slug> for folders the
+// visitor can see and /embed/shared/<token> for share links.
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts, err := parseEmbedOptions(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/embed/"), "/")
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	page := embedPage{Layout: opts.Layout, Columns: opts.Columns, Captions: opts.Captions}
+
+	var folder *folderRecord
+	var shareToken string
+	if token, ok := strings.CutPrefix(path, "shared/"); ok {
+		var access *shareAccess
+		folder, access, err = s.resolveFolderShare(token)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		page.Title = folder.Name
+		page.PageURL = shareLinkURL(baseURL, shareKindFolder, access.Token)
+
+		visit, err := s.newShareVisit(r, shareKindFolder, folder.ID, access)
+		if err != nil {
+			log.Printf("embed visit: %v", err)
+			http.Error(w, "failed to load share", http.StatusInternalServerError)
+			return
+		}
+		if access.blocked(time.Now(), visit.newViews()) {
+			page.Message = "Link do tej galerii wygasl."
+			s.renderEmbed(w, http.StatusGone, page)
+			return
+		}
+		if !s.folderShareUnlocked(r, folder) {
+			page.Message = "Galeria jest chroniona haslem."
+			s.renderEmbed(w, http.StatusUnauthorized, page)
+			return
+		}
+		if err := s.recordShareVisit(visit, access); err != nil {
+			log.Printf("embed view: %v", err)
+		}
+		if !access.Permissions.View {
+			page.Message = "Ten link nie pozwala na ogladanie zdjec."
+			s.renderEmbed(w, http.StatusOK, page)
+			return
+		}
+		page.AllowDownload = access.Permissions.Download
+		shareToken = access.Token
+	} else {
+		if strings.Contains(path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		folder, err = s.getFolderBySlug(sanitizeFilename(path))
+		if err != nil || !s.canAccessFolder(folder, s.sessions.authenticated(w, r)) {
+			http.NotFound(w, r)
+			return
+		}
+		page.Title = folder.Name
+		page.PageURL = folderPageURL(baseURL, folder.Slug)
+		page.AllowDownload = true
+	}
+
+	page.Images, err = s.imagesForFolder(folder)
+	if err != nil {
+		log.Printf("listImages: %v", err)
+		http.Error(w, "failed to load images", http.StatusInternalServerError)
+		return
+	}
+	if shareToken != "" {
+		useSharedFileURLs(page.Images, shareToken)
+	}
+	if len(page.Images) == 0 {
+		page.Message = "W tej galerii nie ma jeszcze zdjec."
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "osadz")
+	}
+	s.renderEmbed(w, http.StatusOK, page)
+}
@@ -0,0 +1,364 @@
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const feedMaxItems = 50
+
+type feedItem struct {
+	Title     string
+	Link      string
+	ImageURL  string
+	MimeType  string
+	Size      int64
+	Folder    string
+	Published time.Time
+}
+
+type feedLink struct {
+	Type  string
+	Title string
+	URL   string
+}
+
+type feedSource struct {
+	Title       string
+	Description string
+	Link        string
+	Self        string
+	Items       []feedItem
+}
+
+func (f feedSource) updated() time.Time {
+	if len(f.Items) == 0 {
+		return time.Unix(0, 0).UTC()
+	}
+	return f.Items[0].Published
+}
+
+func folderPageURL(baseURL, slug string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/?folder=" + url.QueryEscape(slug)
+}
+
+// feedLinks lists the autodiscovery links for the site-wide feed and,
+// when given, a public folder.
+func feedLinks(baseURL string, folder *folderRecord) []feedLink {
+	base := strings.TrimSuffix(baseURL, "/")
+	links := []feedLink{
+		{Type: "application/atom+xml", Title: "Nowe zdjecia (Atom)", URL: base + "/feed.atom"},
+		{Type: "application/rss+xml", Title: "Nowe zdjecia (RSS)", URL: base + "/feed.rss"},
+	}
+	if folder != nil && folder.Visibility == visibilityPublic {
+		prefix := base + "/feeds/" + url.PathEscape(folder.Slug)
+		links = append(links,
+			feedLink{Type: "application/atom+xml", Title: folder.Name + " (Atom)", URL: prefix + ".atom"},
+			feedLink{Type: "application/rss+xml", Title: folder.Name + " (RSS)", URL: prefix + ".rss"},
+		)
+	}
+	return links
+}
+
+// folderFeedItems builds entries from the image files of a folder, using
+// the modification time as the upload time.
+func (s *Server) folderFeedItems(baseURL string, folder *folderRecord) ([]feedItem, error) {
+	images, err := s.imagesForFolder(folder)
+	if err != nil {
+		return nil, err
+	}
+	dir := s.dir
+	if folder.Path != "" {
+		dir = filepath.Join(s.dir, folder.Path)
+	}
+	link := folderPageURL(baseURL, folder.Slug)
+
+	items := make([]feedItem, 0, len(images))
+	for _, img := range images {
+		info, err := os.Stat(filepath.Join(dir, img.Name))
+		if err != nil {
+			continue
+		}
+		mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(img.Name)))
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		items = append(items, feedItem{
+			Title:     img.Name,
+			Link:      link,
+			ImageURL:  absoluteURL(baseURL, img.URL),
+			MimeType:  mimeType,
+			Size:      info.Size(),
+			Folder:    folder.Name,
+			Published: info.ModTime().UTC(),
+		})
+	}
+	return items, nil
+}
+
+func sortFeedItems(items []feedItem) []feedItem {
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Published.After(items[j].Published)
+	})
+	if len(items) > feedMaxItems {
+		items = items[:feedMaxItems]
+	}
+	return items
+}
+
+func (s *Server) handleSiteFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	folders, err := s.listFolders(false)
+	if err != nil {
+		log.Printf("feed folders: %v", err)
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	baseURL := requestBaseURL(r)
+	var items []feedItem
+	for i := range folders {
+		folderItems, err := s.folderFeedItems(baseURL, &folders[i])
+		if err != nil {
+			log.Printf("feed items: %v", err)
+			continue
+		}
+		items = append(items, folderItems...)
+	}
+
+	s.writeFeed(w, r, strings.TrimPrefix(r.URL.Path, "/feed"), feedSource{
+		Title:       "Galeria zdjec - nowe zdjecia",
+		Description: "Najnowsze zdjecia ze wszystkich publicznych folderow.",
+		Link:        strings.TrimSuffix(baseURL, "/") + "/",
+		Self:        absoluteURL(baseURL, r.URL.Path),
+		Items:       sortFeedItems(items),
+	})
+}
+
+// handleFolderFeed serves /feeds/<slug>.atom and /feeds/<slug>.rss for
+// public folders.
+func (s *Server) handleFolderFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	ext := filepath.Ext(name)
+	slug := sanitizeFilename(strings.TrimSuffix(name, ext))
+	if slug == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	folder, err := s.getFolderBySlug(slug)
+	if err != nil || folder.Visibility != visibilityPublic {
+		http.NotFound(w, r)
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	items, err := s.folderFeedItems(baseURL, folder)
+	if err != nil {
+		log.Printf("feed items: %v", err)
+		http.Error(w, "failed to load feed", http.StatusInternalServerError)
+		return
+	}
+	s.writeFeed(w, r, ext, feedSource{
+		Title:       folder.Name,
+		Description: "Najnowsze zdjecia z folderu " + folder.Name + ".",
+		Link:        folderPageURL(baseURL, folder.Slug),
+		Self:        absoluteURL(baseURL, r.URL.Path),
+		Items:       sortFeedItems(items),
+	})
+}
+
+func (s *Server) writeFeed(w http.ResponseWriter, r *http.Request, ext string, feed feedSource) {
+	var doc any
+	var contentType string
+	switch ext {
+	case ".atom":
+		doc, contentType = buildAtomFeed(feed), "application/atom+xml; charset=utf-8"
+	case ".rss":
+		doc, contentType = buildRSSFeed(feed), "application/rss+xml; charset=utf-8"
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	updated := feed.updated()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Last-Modified", updated.Format(http.TimeFormat))
+	if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !updated.Truncate(time.Second).After(since) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+	if s.logger != nil {
+		s.logger.Log(r, "kanal")
+	}
+
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		log.Printf("write feed: %v", err)
+	}
+}
+
+func feedItemHTML(item feedItem) string {
+	return fmt.Sprintf(`<p><a href="%s"><img src="%s" alt="%s"></a></p><p>%s</p>`,
+		html.EscapeString(item.Link), html.EscapeString(item.ImageURL), html.EscapeString(item.Title), html.EscapeString(item.Folder))
+}
+
+type atomFeed struct {
+	XMLName  xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title    string      `xml:"title"`
+	Subtitle string      `xml:"subtitle,omitempty"`
+	ID       string      `xml:"id"`
+	Updated  string      `xml:"updated"`
+	Links    []atomLink  `xml:"link"`
+	Author   atomAuthor  `xml:"author"`
+	Entries  []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	Title     string      `xml:"title"`
+	ID        string      `xml:"id"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+	Links     []atomLink  `xml:"link"`
+	Content   atomContent `xml:"content"`
+}
+
+func buildAtomFeed(feed feedSource) atomFeed {
+	doc := atomFeed{
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		ID:       feed.Self,
+		Updated:  feed.updated().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: feed.Self, Rel: "self", Type: "application/atom+xml"},
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+		},
+		Author: atomAuthor{Name: "Galeria zdjec"},
+	}
+	for _, item := range feed.Items {
+		stamp := item.Published.Format(time.RFC3339)
+		doc.Entries = append(doc.Entries, atomEntry{
+			Title:     item.Title,
+			ID:        item.ImageURL,
+			Published: stamp,
+			Updated:   stamp,
+			Links: []atomLink{
+				{Href: item.Link, Rel: "alternate", Type: "text/html"},
+				{Href: item.ImageURL, Rel: "enclosure", Type: item.MimeType, Length: item.Size},
+			},
+			Content: atomContent{Type: "html", Body: feedItemHTML(item)},
+		})
+	}
+	return doc
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Language      string    `xml:"language"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Self          rssSelf   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string       `xml:"title"`
+	Link        string       `xml:"link"`
+	GUID        rssGUID      `xml:"guid"`
+	PubDate     string       `xml:"pubDate"`
+	Description string       `xml:"description"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+}
+
+func buildRSSFeed(feed feedSource) rssFeed {
+	doc := rssFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       feed.Title,
+			Link:        feed.Link,
+			Description: feed.Description,
+			Language:    "pl",
+			Self:        rssSelf{Href: feed.Self, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if len(feed.Items) > 0 {
+		doc.Channel.LastBuildDate = feed.Items[0].Published.Format(time.RFC1123Z)
+	}
+	for _, item := range feed.Items {
+		doc.Channel.Items = append(doc.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        rssGUID{IsPermaLink: true, Value: item.ImageURL},
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			Description: feedItemHTML(item),
+			Enclosure:   rssEnclosure{URL: item.ImageURL, Length: item.Size, Type: item.MimeType},
+		})
+	}
+	return doc
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -30,6 +31,8 @@ var (
 	errFolderRenameFailed = errors.New("Nie udalo sie zmienic nazwy folderu")
 )
 
+const folderColumns = `id, name, slug, path, visibility, shared_token, shared_views, shared_expires_at, shared_max_views, shared_password_hash, comments_open`
+
 type folderRecord struct {
 	ID          int64
 	Name        string
@@ -38,6 +41,10 @@ type folderRecord struct {
 	Visibility  string
 	SharedToken sql.NullString
 	SharedViews int
+	ShareLimits shareLimits
+
+	SharedPasswordHash sql.NullString
+	CommentsOpen       bool
 }
 
 type folderView struct {
@@ -48,6 +55,19 @@ type folderView struct {
 	SharedToken string `json:"sharedToken,omitempty"`
 	SharedViews int    `json:"sharedViews"`
 	ShareURL    string `json:"shareUrl,omitempty"`
+	shareStatus
+	PasswordProtected bool `json:"passwordProtected"`
+	CommentsOpen      bool `json:"commentsOpen"`
+}
+
+func scanFolder(row rowScanner) (*folderRecord, error) {
+	var rec folderRecord
+	err := row.Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews,
+		&rec.ShareLimits.ExpiresAt, &rec.ShareLimits.MaxViews, &rec.SharedPasswordHash, &rec.CommentsOpen)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
 }
 
 func (f folderRecord) toView(baseURL string) folderView {
@@ -57,6 +77,10 @@ func (f folderRecord) toView(baseURL string) folderView {
 		Slug:        f.Slug,
 		Visibility:  f.Visibility,
 		SharedViews: f.SharedViews,
+		shareStatus: f.ShareLimits.status(time.Now(), f.SharedViews),
+
+		PasswordProtected: f.SharedPasswordHash.Valid,
+		CommentsOpen:      f.CommentsOpen,
 	}
 	if f.SharedToken.Valid && f.SharedToken.String != "" {
 		view.SharedToken = f.SharedToken.String
@@ -131,7 +155,7 @@ func (s *Server) createFolder(name string) (*folderRecord, error) {
 }
 
 func (s *Server) listFolders(loggedIn bool) ([]folderRecord, error) {
-	query := `SELECT id, name, slug, path, visibility, shared_token, shared_views FROM folders`
+	query := `SELECT ` + folderColumns + ` FROM folders`
 	var args []any
 	if !loggedIn {
 		query += ` WHERE visibility = ?`
@@ -147,11 +171,11 @@ func (s *Server) listFolders(loggedIn bool) ([]folderRecord, error) {
 
 	var folders []folderRecord
 	for rows.Next() {
-		var rec folderRecord
-		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews); err != nil {
+		rec, err := scanFolder(rows)
+		if err != nil {
 			return nil, err
 		}
-		folders = append(folders, rec)
+		folders = append(folders, *rec)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -160,33 +184,19 @@ func (s *Server) listFolders(loggedIn bool) ([]folderRecord, error) {
 }
 
 func (s *Server) getFolderBySlug(slug string) (*folderRecord, error) {
-	var rec folderRecord
-	err := s.db.QueryRow(`SELECT id, name, slug, path, visibility, shared_token, shared_views FROM folders WHERE slug = ?`, slug).
-		Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews)
-	if err != nil {
-		return nil, err
-	}
-	return &rec, nil
+	return scanFolder(s.db.QueryRow(`SELECT `+folderColumns+` FROM folders WHERE slug = ?`, slug))
 }
 
 func (s *Server) getFolderByID(id int64) (*folderRecord, error) {
-	var rec folderRecord
-	err := s.db.QueryRow(`SELECT id, name, slug, path, visibility, shared_token, shared_views FROM folders WHERE id = ?`, id).
-		Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews)
-	if err != nil {
-		return nil, err
-	}
-	return &rec, nil
+	return scanFolder(s.db.QueryRow(`SELECT `+folderColumns+` FROM folders WHERE id = ?`, id))
+}
+
+func (s *Server) getFolderByPath(path string) (*folderRecord, error) {
+	return scanFolder(s.db.QueryRow(`SELECT `+folderColumns+` FROM folders WHERE path = ?`, path))
 }
 
 func (s *Server) getFolderByToken(token string) (*folderRecord, error) {
-	var rec folderRecord
-	err := s.db.QueryRow(`SELECT id, name, slug, path, visibility, shared_token, shared_views FROM folders WHERE shared_token = ?`, token).
-		Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews)
-	if err != nil {
-		return nil, err
-	}
-	return &rec, nil
+	return scanFolder(s.db.QueryRow(`SELECT `+folderColumns+` FROM folders WHERE shared_token = ?`, token))
 }
 
 func (s *Server) updateFolderVisibility(id int64, visibility string) (*folderRecord, error) {
@@ -243,6 +253,21 @@ func (s *Server) regenerateSharedToken(id int64) (*folderRecord, error) {
 	return s.getFolderByID(id)
 }
 
+func (s *Server) updateFolderShareLimits(id int64, limits shareLimits) (*folderRecord, error) {
+	if _, err := s.db.Exec(`UPDATE folders SET shared_expires_at = ?, shared_max_views = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		limits.ExpiresAt, limits.MaxViews, id); err != nil {
+		return nil, err
+	}
+	return s.getFolderByID(id)
+}
+
+func (s *Server) updateFolderSharePassword(id int64, passwordHash sql.NullString) (*folderRecord, error) {
+	if _, err := s.db.Exec(`UPDATE folders SET shared_password_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, passwordHash, id); err != nil {
+		return nil, err
+	}
+	return s.getFolderByID(id)
+}
+
 func (s *Server) incrementSharedViews(id int64) error {
 	_, err := s.db.Exec(`UPDATE folders SET shared_views = shared_views + 1 WHERE id = ?`, id)
 	return err
@@ -361,6 +386,24 @@ func (s *Server) deleteFolder(id int64) error {
 		return err
 	}
 
+	if err := s.deleteShareLinks(shareKindFolder, id); err != nil {
+		return err
+	}
+	if err := s.deleteShareAnalytics(shareKindFolder, id); err != nil {
+		return err
+	}
+	if err := s.deleteFolderImageShares(id); err != nil {
+		return err
+	}
+	if err := s.deleteFolderImageAttributions(id); err != nil {
+		return err
+	}
+	if err := s.unpublishSubmissions(id, ""); err != nil {
+		return err
+	}
+	if err := s.deleteFolderComments(id); err != nil {
+		return err
+	}
 	_, err = s.db.Exec(`DELETE FROM folders WHERE id = ?`, id)
 	return err
 }
@@ -7,12 +7,12 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
-
-	"github.com/skip2/go-qrcode"
+	"time"
 )
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -62,10 +62,7 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
 		return
 	}
-	if !s.sessions.authenticated(w, r) {
-		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
-		return
-	}
+	loggedIn := s.sessions.authenticated(w, r)
 
 	r.Body = http.MaxBytesReader(w, r.Body, uploadMaxSize)
 	if err := r.ParseMultipartForm(uploadMaxSize); err != nil {
@@ -73,20 +70,47 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	folderSlug := strings.TrimSpace(r.FormValue("folder"))
-	if folderSlug == "" {
-		writeJSONError(w, http.StatusBadRequest, "Wybierz folder docelowy")
+	shareToken := strings.TrimSpace(r.FormValue("shareToken"))
+	if !loggedIn && shareToken == "" {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
 		return
 	}
-	folder, err := s.getFolderBySlug(folderSlug)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			writeJSONError(w, http.StatusBadRequest, "Folder nie istnieje")
+
+	var (
+		folder *folderRecord
+		err    error
+	)
+	if loggedIn {
+		folderSlug := strings.TrimSpace(r.FormValue("folder"))
+		if folderSlug == "" {
+			writeJSONError(w, http.StatusBadRequest, "Wybierz folder docelowy")
+			return
+		}
+		folder, err = s.getFolderBySlug(folderSlug)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				writeJSONError(w, http.StatusBadRequest, "Folder nie istnieje")
+				return
+			}
+			log.Printf("folder lookup: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic folderu")
+			return
+		}
+	} else {
+		var access *shareAccess
+		folder, access, err = s.resolveFolderShare(shareToken)
+		if err != nil || !access.Permissions.Upload {
+			writeJSONError(w, http.StatusForbidden, "Ten link nie pozwala na przesylanie plikow")
+			return
+		}
+		if access.blocked(time.Now(), 0) {
+			writeJSONError(w, http.StatusForbidden, "Ten link wygasl")
+			return
+		}
+		if !s.folderShareUnlocked(r, folder) {
+			writeJSONError(w, http.StatusUnauthorized, "Link wymaga hasla")
 			return
 		}
-		log.Printf("folder lookup: %v", err)
-		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic folderu")
-		return
 	}
 
 	targetDir := s.dir
@@ -130,6 +154,31 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusBadRequest, "Nieobslugiwany typ pliku")
 		return
 	}
+	if !loggedIn && !isRasterImage(file, filename) {
+		writeJSONError(w, http.StatusBadRequest, "Dozwolone sa tylko obrazy JPG, PNG, GIF, WEBP, AVIF i BMP")
+		return
+	}
+
+	switch scanStatus, signature := s.scanUpload(file); scanStatus {
+	case scanStatusInfected:
+		if name, err := s.quarantineUpload(file, filename); err != nil {
+			log.Printf("quarantine upload: %v", err)
+		} else {
+			log.Printf("upload to %s quarantined as %s (%s)", folder.Slug, name, signature)
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "wirus")
+		}
+		writeJSONError(w, http.StatusUnprocessableEntity, "Plik zostal zablokowany przez skaner antywirusowy")
+		return
+	case scanStatusFailed:
+		// Gallery uploads are shown right away, so visitors cannot skip
+		// the scan when the scanner is down.
+		if !loggedIn {
+			writeJSONError(w, http.StatusServiceUnavailable, "Nie udalo sie sprawdzic pliku. Sprobuj ponownie pozniej")
+			return
+		}
+	}
 
 	target, err := uniqueFilename(targetDir, filename)
 	if err != nil {
@@ -159,7 +208,7 @@ func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{
 		"status": "ok",
 		"name":   filepath.Base(target),
-		"folder": folderSlug,
+		"folder": folder.Slug,
 	})
 }
 
@@ -221,6 +270,18 @@ func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac pliku")
 		return
 	}
+	if err := s.deleteImageShare(folder.ID, filename); err != nil {
+		log.Printf("delete image share: %v", err)
+	}
+	if err := s.deleteImageAttribution(folder.ID, filename); err != nil {
+		log.Printf("delete image attribution: %v", err)
+	}
+	if err := s.unpublishSubmissions(folder.ID, filename); err != nil {
+		log.Printf("unpublish submission: %v", err)
+	}
+	if err := s.deleteImageComments(folder.ID, filename); err != nil {
+		log.Printf("delete image comments: %v", err)
+	}
 
 	if s.logger != nil {
 		s.logger.Log(r, "usunzdj")
@@ -352,6 +413,18 @@ func (s *Server) handleRenameImage(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zmienic nazwy pliku")
 		return
 	}
+	if err := s.renameImageShare(folder.ID, oldFile, newFile); err != nil {
+		log.Printf("rename image share: %v", err)
+	}
+	if err := s.renameImageAttribution(folder.ID, oldFile, newFile); err != nil {
+		log.Printf("rename image attribution: %v", err)
+	}
+	if err := s.renamePublishedSubmission(folder.ID, oldFile, newFile); err != nil {
+		log.Printf("rename published submission: %v", err)
+	}
+	if err := s.renameImageComments(folder.ID, oldFile, newFile); err != nil {
+		log.Printf("rename image comments: %v", err)
+	}
 
 	if s.logger != nil {
 		s.logger.Log(r, "zmienzdj")
@@ -394,6 +467,14 @@ func (s *Server) handleFolderByID(w http.ResponseWriter, r *http.Request) {
 		s.handleFolderQR(w, r, id)
 		return
 	}
+	if len(parts) == 2 && parts[1] == "links" {
+		s.handleTargetShareLinks(w, r, shareKindFolder, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "analytics" {
+		s.handleFolderAnalytics(w, r, id)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -470,9 +551,13 @@ func (s *Server) handleUpdateFolderAPI(w http.ResponseWriter, r *http.Request, i
 	}
 
 	var req struct {
-		Name           string `json:"name"`
-		Visibility     string `json:"visibility"`
-		RegenerateLink bool   `json:"regenerateLink"`
+		Name            string  `json:"name"`
+		Visibility      string  `json:"visibility"`
+		RegenerateLink  bool    `json:"regenerateLink"`
+		SharedExpiresAt *string `json:"sharedExpiresAt"`
+		SharedMaxViews  *int64  `json:"sharedMaxViews"`
+		SharedPassword  *string `json:"sharedPassword"`
+		CommentsOpen    *bool   `json:"commentsOpen"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
@@ -520,6 +605,40 @@ func (s *Server) handleUpdateFolderAPI(w http.ResponseWriter, r *http.Request, i
 		}
 	}
 
+	if req.SharedExpiresAt != nil || req.SharedMaxViews != nil {
+		limits, err := applyShareLimitsRequest(folder.ShareLimits, req.SharedExpiresAt, req.SharedMaxViews)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		folder, err = s.updateFolderShareLimits(id, limits)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac ograniczen linku")
+			return
+		}
+	}
+
+	if req.SharedPassword != nil {
+		passwordHash, err := sharePasswordUpdate(*req.SharedPassword)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		folder, err = s.updateFolderSharePassword(id, passwordHash)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac hasla")
+			return
+		}
+	}
+
+	if req.CommentsOpen != nil {
+		folder, err = s.updateFolderCommentsOpen(id, *req.CommentsOpen)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac ustawien komentarzy")
+			return
+		}
+	}
+
 	if req.RegenerateLink {
 		if folder.Visibility != visibilityShared {
 			writeJSONError(w, http.StatusBadRequest, "Folder nie jest ustawiony jako udostepniony")
@@ -594,17 +713,54 @@ func (s *Server) handleFolderQR(w http.ResponseWriter, r *http.Request, id int64
 		folder, _ = s.getFolderByID(folder.ID)
 	}
 
-	link := requestBaseURL(r) + "/shared/" + token
-	png, err := qrcode.Encode(link, qrcode.Medium, 256)
+	if linkID := r.URL.Query().Get("link"); linkID != "" {
+		link, ok := s.shareLinkForQR(w, linkID, shareKindFolder, folder.ID)
+		if !ok {
+			return
+		}
+		token = link.Token
+	}
+
+	s.writeShareQR(w, r, shareLinkURL(requestBaseURL(r), shareKindFolder, token), "folder-"+folder.Slug)
+}
+
+// serveSharedDownload sends an image of a shared folder as an attachment, for
+// links that allow downloads only.
+func (s *Server) serveSharedDownload(w http.ResponseWriter, r *http.Request, folder *folderRecord, access *shareAccess, name string) {
+	if !access.Permissions.View || !access.Permissions.Download {
+		http.Error(w, "download not allowed", http.StatusForbidden)
+		return
+	}
+	target, err := s.folderImagePath(folder, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filepath.Base(target)+"\"")
+	http.ServeFile(w, r, target)
+}
+
+// serveSharedFile sends an image of a shared folder for display, so the
+// share page never points at the folder's /images/ path.
+func (s *Server) serveSharedFile(w http.ResponseWriter, r *http.Request, folder *folderRecord, access *shareAccess, name string) {
+	if !access.Permissions.View {
+		http.Error(w, "view not allowed", http.StatusForbidden)
+		return
+	}
+	target, err := s.folderImagePath(folder, name)
 	if err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie wygenerowac QR")
+		http.NotFound(w, r)
 		return
 	}
+	sandboxSVG(w, target)
+	http.ServeFile(w, r, target)
+}
 
-	w.Header().Set("Content-Type", "image/png")
-	w.Header().Set("Content-Disposition", "attachment; filename=\"folder-"+folder.Slug+"-qr.png\"")
-	if _, err := w.Write(png); err != nil {
-		log.Printf("write qr: %v", err)
+// useSharedFileURLs points images at the share link's file route.
+func useSharedFileURLs(images []imageInfo, token string) {
+	prefix := "/shared/" + url.PathEscape(token) + "?file="
+	for i := range images {
+		images[i].URL = prefix + url.QueryEscape(images[i].Name)
 	}
 }
 
@@ -622,26 +778,56 @@ func (s *Server) handleSharedFolder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	folder, err := s.getFolderByToken(token)
-	if err != nil || folder.Visibility != visibilityShared {
+	folder, access, err := s.resolveFolderShare(token)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	if err := s.incrementSharedViews(folder.ID); err != nil {
+	visit, err := s.newShareVisit(r, shareKindFolder, folder.ID, access)
+	if err != nil {
+		log.Printf("share visit: %v", err)
+		http.Error(w, "failed to load share", http.StatusInternalServerError)
+		return
+	}
+
+	if access.blocked(time.Now(), visit.newViews()) {
+		s.renderShareExpired(w, r, "gallery")
+		return
+	}
+
+	if !s.folderShareUnlocked(r, folder) {
+		s.renderSharePasswordPrompt(w, r, "gallery", shareKindFolder, token)
+		return
+	}
+
+	if name := r.URL.Query().Get("download"); name != "" {
+		s.serveSharedDownload(w, r, folder, access, name)
+		return
+	}
+	if name := r.URL.Query().Get("file"); name != "" {
+		s.serveSharedFile(w, r, folder, access, name)
+		return
+	}
+
+	if err := s.recordShareVisit(visit, access); err != nil {
 		log.Printf("shared view: %v", err)
-	} else {
-		folder.SharedViews++
 	}
 
 	baseURL := requestBaseURL(r)
 	view := folder.toView(baseURL)
+	view.SharedToken = access.Token
+	view.ShareURL = shareLinkURL(baseURL, shareKindFolder, access.Token)
 
-	images, err := s.imagesForFolder(folder)
-	if err != nil {
-		log.Printf("listImages: %v", err)
-		http.Error(w, "failed to load images", http.StatusInternalServerError)
-		return
+	var images []imageInfo
+	if access.Permissions.View {
+		images, err = s.imagesForFolder(folder)
+		if err != nil {
+			log.Printf("listImages: %v", err)
+			http.Error(w, "failed to load images", http.StatusInternalServerError)
+			return
+		}
+		useSharedFileURLs(images, access.Token)
 	}
 
 	data := pageData{
@@ -653,6 +839,11 @@ func (s *Server) handleSharedFolder(w http.ResponseWriter, r *http.Request) {
 		SharedMode:            true,
 		BaseURL:               baseURL,
 		AllowFolderManagement: false,
+		ShareToken:            access.Token,
+		ShareAllowView:        access.Permissions.View,
+		ShareAllowDownload:    access.Permissions.Download,
+		ShareAllowUpload:      access.Permissions.Upload,
+		Meta:                  folderPageMeta(r, folder.Name, images),
 	}
 
 	if s.logger != nil {
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+var errUnsupportedIcon = errors.New("unsupported icon format")
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// loadLogoImage reads the favicon used as the QR logo. Plain PNG files and
+// ICO files with PNG or 24/32-bit BMP entries are supported.
+func loadLogoImage(path string) (image.Image, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, pngSignature) {
+		return png.Decode(bytes.NewReader(data))
+	}
+	return decodeICO(data)
+}
+
+func decodeICO(data []byte) (image.Image, error) {
+	if len(data) < 6 || binary.LittleEndian.Uint16(data[0:2]) != 0 || binary.LittleEndian.Uint16(data[2:4]) != 1 {
+		return nil, errUnsupportedIcon
+	}
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+
+	bestSize, bestOffset, bestLength := -1, 0, 0
+	for i := 0; i < count; i++ {
+		entry := 6 + i*16
+		if entry+16 > len(data) {
+			return nil, errUnsupportedIcon
+		}
+		size := int(data[entry])
+		if size == 0 {
+			size = 256
+		}
+		length := int(binary.LittleEndian.Uint32(data[entry+8 : entry+12]))
+		offset := int(binary.LittleEndian.Uint32(data[entry+12 : entry+16]))
+		if offset < 0 || length <= 0 || offset+length > len(data) {
+			continue
+		}
+		if size > bestSize {
+			bestSize, bestOffset, bestLength = size, offset, length
+		}
+	}
+	if bestSize < 0 {
+		return nil, errUnsupportedIcon
+	}
+
+	entry := data[bestOffset : bestOffset+bestLength]
+	if bytes.HasPrefix(entry, pngSignature) {
+		return png.Decode(bytes.NewReader(entry))
+	}
+	return decodeICOBitmap(entry)
+}
+
+// decodeICOBitmap decodes the DIB stored in an ICO entry. Its height covers
+// both the colour data and the AND mask, so only the top half is the image.
+func decodeICOBitmap(data []byte) (image.Image, error) {
+	if len(data) < 40 {
+		return nil, errUnsupportedIcon
+	}
+	headerSize := int(binary.LittleEndian.Uint32(data[0:4]))
+	width := int(int32(binary.LittleEndian.Uint32(data[4:8])))
+	height := int(int32(binary.LittleEndian.Uint32(data[8:12]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(data[14:16]))
+	compression := binary.LittleEndian.Uint32(data[16:20])
+	if width <= 0 || height <= 0 || width > 1024 || height > 1024 || compression != 0 {
+		return nil, errUnsupportedIcon
+	}
+	if bpp != 32 && bpp != 24 {
+		return nil, errUnsupportedIcon
+	}
+
+	if headerSize < 40 || headerSize > len(data) {
+		return nil, errUnsupportedIcon
+	}
+
+	bytesPerPixel := bpp / 8
+	stride := (width*bytesPerPixel + 3) &^ 3
+	pixels := data[headerSize:]
+	if len(pixels) < stride*height {
+		return nil, errUnsupportedIcon
+	}
+
+	maskStride := ((width + 31) / 32) * 4
+	mask := pixels[stride*height:]
+	hasMask := bpp == 24 && len(mask) >= maskStride*height
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := pixels[(height-1-y)*stride:]
+		for x := 0; x < width; x++ {
+			p := row[x*bytesPerPixel:]
+			alpha := uint8(255)
+			if bpp == 32 {
+				alpha = p[3]
+			} else if hasMask {
+				maskRow := mask[(height-1-y)*maskStride:]
+				if maskRow[x/8]&(0x80>>(x%8)) != 0 {
+					alpha = 0
+				}
+			}
+			img.SetNRGBA(x, y, color.NRGBA{R: p[2], G: p[1], B: p[0], A: alpha})
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,20 @@
+package app
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func TestDecodeICOBitmapRejectsBadHeaderSize(t *testing.T) {
+	for _, headerSize := range []uint32{0, 39, 1 << 20, 1<<32 - 1} {
+		data := make([]byte, 40+16*16*4)
+		binary.LittleEndian.PutUint32(data[0:4], headerSize)
+		binary.LittleEndian.PutUint32(data[4:8], 16)
+		binary.LittleEndian.PutUint32(data[8:12], 32)
+		binary.LittleEndian.PutUint16(data[14:16], 32)
+		if _, err := decodeICOBitmap(data); !errors.Is(err, errUnsupportedIcon) {
+			t.Errorf("header size %d: err = %v, want errUnsupportedIcon", headerSize, err)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package app
+
+import "database/sql"
+
+// imageAttribution keeps the author of a gallery image that was published
+// from a submission group.
+type imageAttribution struct {
+	Author       string
+	OriginalName string
+	SubmissionID sql.NullInt64
+}
+
+func (s *Server) saveImageAttribution(folderID int64, image string, attr imageAttribution) error {
+	_, err := s.db.Exec(`INSERT INTO image_attributions (folder_id, image, author, original_name, submission_id) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(folder_id, image) DO UPDATE SET author = excluded.author, original_name = excluded.original_name, submission_id = excluded.submission_id`,
+		folderID, image, attr.Author, attr.OriginalName, attr.SubmissionID)
+	return err
+}
+
+func (s *Server) folderImageAttributions(folderID int64) (map[string]imageAttribution, error) {
+	rows, err := s.db.Query(`SELECT image, author, original_name, submission_id FROM image_attributions WHERE folder_id = ?`, folderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attributions := map[string]imageAttribution{}
+	for rows.Next() {
+		var image string
+		var attr imageAttribution
+		if err := rows.Scan(&image, &attr.Author, &attr.OriginalName, &attr.SubmissionID); err != nil {
+			return nil, err
+		}
+		attributions[image] = attr
+	}
+	return attributions, rows.Err()
+}
+
+func (s *Server) renameImageAttribution(folderID int64, oldName, newName string) error {
+	_, err := s.db.Exec(`UPDATE image_attributions SET image = ? WHERE folder_id = ? AND image = ?`, newName, folderID, oldName)
+	return err
+}
+
+func (s *Server) deleteImageAttribution(folderID int64, image string) error {
+	_, err := s.db.Exec(`DELETE FROM image_attributions WHERE folder_id = ? AND image = ?`, folderID, image)
+	return err
+}
+
+func (s *Server) deleteFolderImageAttributions(folderID int64) error {
+	_, err := s.db.Exec(`DELETE FROM image_attributions WHERE folder_id = ?`, folderID)
+	return err
+}
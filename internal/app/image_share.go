@@ -0,0 +1,341 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const shareKindImage = "image"
+
+var errImageNotFound = errors.New("obraz nie istnieje")
+
+type imageShareRecord struct {
+	ID        int64
+	FolderID  int64
+	Image     string
+	Token     string
+	Views     int
+	Limits    shareLimits
+	CreatedAt time.Time
+}
+
+type imageShareView struct {
+	Shared bool   `json:"shared"`
+	Image  string `json:"image"`
+	Token  string `json:"token,omitempty"`
+	URL    string `json:"url,omitempty"`
+	Views  int    `json:"views"`
+	shareStatus
+}
+
+type imageSharePage struct {
+	Name        string
+	FolderName  string
+	ImageURL    string
+	DownloadURL string
+	Expired     bool
+	Meta        *pageMeta
+}
+
+func (rec *imageShareRecord) toView(baseURL string) imageShareView {
+	return imageShareView{
+		Shared:      true,
+		Image:       rec.Image,
+		Token:       rec.Token,
+		URL:         strings.TrimSuffix(baseURL, "/") + "/i/" + rec.Token,
+		Views:       rec.Views,
+		shareStatus: rec.Limits.status(time.Now(), rec.Views),
+	}
+}
+
+const imageShareColumns = `id, folder_id, image, token, views, expires_at, created_at`
+
+func scanImageShare(row rowScanner) (*imageShareRecord, error) {
+	var rec imageShareRecord
+	if err := row.Scan(&rec.ID, &rec.FolderID, &rec.Image, &rec.Token, &rec.Views, &rec.Limits.ExpiresAt, &rec.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *Server) getImageShare(folderID int64, image string) (*imageShareRecord, error) {
+	return scanImageShare(s.db.QueryRow(`SELECT `+imageShareColumns+` FROM image_shares WHERE folder_id = ? AND image = ?`, folderID, image))
+}
+
+func (s *Server) getImageShareByToken(token string) (*imageShareRecord, error) {
+	return scanImageShare(s.db.QueryRow(`SELECT `+imageShareColumns+` FROM image_shares WHERE token = ?`, token))
+}
+
+func (s *Server) saveImageShare(folderID int64, image string, expiresAt *string, regenerate bool) (*imageShareRecord, error) {
+	rec, err := s.getImageShare(folderID, image)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if rec == nil {
+		token, err := randomToken()
+		if err != nil {
+			return nil, err
+		}
+		if _, err := s.db.Exec(`INSERT INTO image_shares (folder_id, image, token) VALUES (?, ?, ?)`, folderID, image, token); err != nil {
+			return nil, err
+		}
+		if rec, err = s.getImageShare(folderID, image); err != nil {
+			return nil, err
+		}
+	}
+
+	limits, err := applyShareLimitsRequest(rec.Limits, expiresAt, nil)
+	if err != nil {
+		return nil, err
+	}
+	token := rec.Token
+	views := rec.Views
+	if regenerate {
+		if token, err = randomToken(); err != nil {
+			return nil, err
+		}
+		views = 0
+	}
+	_, err = s.db.Exec(`UPDATE image_shares SET token = ?, views = ?, expires_at = ? WHERE id = ?`, token, views, limits.ExpiresAt, rec.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getImageShare(folderID, image)
+}
+
+func (s *Server) deleteImageShare(folderID int64, image string) error {
+	if _, err := s.db.Exec(`DELETE FROM share_visits WHERE target_type = ? AND target_id IN (SELECT id FROM image_shares WHERE folder_id = ? AND image = ?)`,
+		shareKindImage, folderID, image); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM image_shares WHERE folder_id = ? AND image = ?`, folderID, image)
+	return err
+}
+
+func (s *Server) deleteFolderImageShares(folderID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM share_visits WHERE target_type = ? AND target_id IN (SELECT id FROM image_shares WHERE folder_id = ?)`,
+		shareKindImage, folderID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM image_shares WHERE folder_id = ?`, folderID)
+	return err
+}
+
+func (s *Server) renameImageShare(folderID int64, oldName, newName string) error {
+	_, err := s.db.Exec(`UPDATE image_shares SET image = ? WHERE folder_id = ? AND image = ?`, newName, folderID, oldName)
+	return err
+}
+
+// folderImagePath resolves an image inside the folder directory and makes
+// sure the name cannot escape it.
+func (s *Server) folderImagePath(folder *folderRecord, name string) (string, error) {
+	name = filepath.Base(strings.TrimSpace(name))
+	if name == "" || name == "." || !isImageFile(name) {
+		return "", errImageNotFound
+	}
+	dir := filepath.Clean(s.dir)
+	if folder.Path != "" {
+		dir = filepath.Clean(filepath.Join(s.dir, folder.Path))
+	}
+	target := filepath.Clean(filepath.Join(dir, name))
+	if !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return "", errImageNotFound
+	}
+	info, err := os.Stat(target)
+	if err != nil || info.IsDir() {
+		return "", errImageNotFound
+	}
+	return target, nil
+}
+
+// imageShareTarget reads the folder and image name from the query string
+// (GET) or a JSON body and checks that the file exists.
+func (s *Server) imageShareTarget(w http.ResponseWriter, r *http.Request) (*folderRecord, string, *imageShareRequest, bool) {
+	req := &imageShareRequest{}
+	if r.Method == http.MethodGet {
+		req.Folder = r.URL.Query().Get("folder")
+		req.Name = r.URL.Query().Get("name")
+	} else if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return nil, "", nil, false
+	}
+
+	folder, err := s.getFolderBySlug(strings.TrimSpace(req.Folder))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusBadRequest, "Folder nie istnieje")
+			return nil, "", nil, false
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic folderu")
+		return nil, "", nil, false
+	}
+	path, err := s.folderImagePath(folder, req.Name)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+		return nil, "", nil, false
+	}
+	return folder, filepath.Base(path), req, true
+}
+
+type imageShareRequest struct {
+	Folder     string  `json:"folder"`
+	Name       string  `json:"name"`
+	ExpiresAt  *string `json:"expiresAt"`
+	Regenerate bool    `json:"regenerate"`
+}
+
+func (s *Server) handleImageShareAPI(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "GET, POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	folder, image, req, ok := s.imageShareTarget(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		rec, err := s.getImageShare(folder.ID, image)
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSON(w, http.StatusOK, imageShareView{Image: image})
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac linku")
+			return
+		}
+		writeJSON(w, http.StatusOK, rec.toView(requestBaseURL(r)))
+	case http.MethodPost:
+		rec, err := s.saveImageShare(folder.ID, image, req.ExpiresAt, req.Regenerate)
+		if err != nil {
+			if errors.Is(err, errShareLimitInvalid) {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("save image share: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac linku")
+			return
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "linkzdj")
+		}
+		writeJSON(w, http.StatusOK, rec.toView(requestBaseURL(r)))
+	case http.MethodDelete:
+		if err := s.deleteImageShare(folder.ID, image); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac linku")
+			return
+		}
+		writeJSON(w, http.StatusOK, imageShareView{Image: image})
+	}
+}
+
+func (s *Server) handleImageShareQR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+	folder, image, _, ok := s.imageShareTarget(w, r)
+	if !ok {
+		return
+	}
+	rec, err := s.getImageShare(folder.ID, image)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Obraz nie ma udostepnionego linku")
+		return
+	}
+	name := strings.TrimSuffix(image, filepath.Ext(image))
+	s.writeShareQR(w, r, rec.toView(requestBaseURL(r)).URL, "obraz-"+sanitizeFilename(name))
+}
+
+func (s *Server) handleImageSharePage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token, rest, _ := strings.Cut(strings.Trim(strings.TrimPrefix(r.URL.Path, "/i/"), "/"), "/")
+	if token == "" || (rest != "" && rest != "file") {
+		http.NotFound(w, r)
+		return
+	}
+	rec, err := s.getImageShareByToken(token)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	folder, err := s.getFolderByID(rec.FolderID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	path, err := s.folderImagePath(folder, rec.Image)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if rest == "file" {
+		if rec.Limits.expired(time.Now(), 0) {
+			http.Error(w, "link expired", http.StatusGone)
+			return
+		}
+		if r.URL.Query().Get("download") == "1" {
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+rec.Image+"\"; filename*=UTF-8''"+url.PathEscape(rec.Image))
+		}
+		http.ServeFile(w, r, path)
+		return
+	}
+
+	page := imageSharePage{
+		Name:        rec.Image,
+		FolderName:  folder.Name,
+		ImageURL:    "/i/" + rec.Token + "/file",
+		DownloadURL: "/i/" + rec.Token + "/file?download=1",
+	}
+
+	access := &shareAccess{Token: rec.Token, Views: rec.Views, Limits: rec.Limits}
+	visit, err := s.newShareVisit(r, shareKindImage, rec.ID, access)
+	if err != nil {
+		log.Printf("image share visit: %v", err)
+		http.Error(w, "failed to load share", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if access.blocked(time.Now(), visit.newViews()) {
+		page.Expired = true
+		w.WriteHeader(http.StatusGone)
+	} else {
+		if err := s.recordShareVisit(visit, access); err != nil {
+			log.Printf("image share view: %v", err)
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "obrazlink")
+		}
+		page.Meta = imageSharePageMeta(r, page)
+	}
+	if err := s.tmpl.ExecuteTemplate(w, "image", page); err != nil {
+		log.Printf("template execute: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"image"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	oEmbedDefaultWidth  = 800
+	oEmbedDefaultHeight = 600
+	oEmbedCacheAge      = 3600
+)
+
+type oEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title,omitempty"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	CacheAge     int    `json:"cache_age"`
+	URL          string `json:"url,omitempty"`
+	HTML         string `json:"html,omitempty"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+}
+
+type oEmbedError struct {
+	status  int
+	message string
+}
+
+func (e *oEmbedError) Error() string {
+	return e.message
+}
+
+var (
+	errOEmbedNotFound     = &oEmbedError{http.StatusNotFound, "Nie znaleziono zasobu"}
+	errOEmbedUnauthorized = &oEmbedError{http.StatusUnauthorized, "Zasob nie jest publiczny"}
+	errOEmbedOptions      = &oEmbedError{http.StatusBadRequest, "Nieprawidlowe parametry"}
+)
+
+// oEmbedBounds applies the consumer's maxwidth and maxheight to the
+// default frame size.
+func oEmbedBounds(query url.Values) (int, int, error) {
+	width, height := oEmbedDefaultWidth, oEmbedDefaultHeight
+	for _, field := range []struct {
+		name  string
+		value *int
+	}{{"maxwidth", &width}, {"maxheight", &height}} {
+		raw := strings.TrimSpace(query.Get(field.name))
+		if raw == "" {
+			continue
+		}
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return 0, 0, errOEmbedOptions
+		}
+		if limit < *field.value {
+			*field.value = limit
+		}
+	}
+	return width, height, nil
+}
+
+func fitSize(width, height, maxWidth, maxHeight int) (int, int) {
+	if width > maxWidth {
+		height = height * maxWidth / width
+		width = maxWidth
+	}
+	if height > maxHeight {
+		width = width * maxHeight / height
+		height = maxHeight
+	}
+	return max(width, 1), max(height, 1)
+}
+
+func (s *Server) handleOEmbed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	query := r.URL.Query()
+	if format := strings.ToLower(strings.TrimSpace(query.Get("format"))); format != "" && format != "json" {
+		writeJSONError(w, http.StatusNotImplemented, "Obslugiwany jest tylko format json")
+		return
+	}
+	target, err := url.Parse(strings.TrimSpace(query.Get("url")))
+	if err != nil || target.Path == "" {
+		writeJSONError(w, http.StatusBadRequest, "Podaj adres galerii lub obrazu")
+		return
+	}
+	maxWidth, maxHeight, err := oEmbedBounds(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts, err := parseEmbedOptions(query)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	if base, err := url.Parse(baseURL); err == nil && target.Host != "" && !strings.EqualFold(target.Host, base.Host) {
+		writeJSONError(w, http.StatusNotFound, errOEmbedNotFound.Error())
+		return
+	}
+
+	resp := oEmbedResponse{
+		Version:      "1.0",
+		ProviderName: "Galeria zdjec",
+		ProviderURL:  strings.TrimSuffix(baseURL, "/") + "/",
+		CacheAge:     oEmbedCacheAge,
+	}
+	if token, ok := strings.CutPrefix(target.Path, "/i/"); ok {
+		err = s.oEmbedImage(&resp, baseURL, strings.TrimSuffix(strings.Trim(token, "/"), "/file"), maxWidth, maxHeight)
+	} else {
+		err = s.oEmbedFolder(&resp, baseURL, target, opts, maxWidth, maxHeight)
+	}
+	if err != nil {
+		status := http.StatusInternalServerError
+		var oerr *oEmbedError
+		if errors.As(err, &oerr) {
+			status = oerr.status
+		}
+		writeJSONError(w, status, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) oEmbedFolder(resp *oEmbedResponse, baseURL string, target *url.URL, opts embedOptions, width, height int) error {
+	var folder *folderRecord
+	var embedPath string
+	if token, ok := strings.CutPrefix(target.Path, "/shared/"); ok {
+		token = strings.Trim(token, "/")
+		var access *shareAccess
+		var err error
+		folder, access, err = s.resolveFolderShare(token)
+		if err != nil || access.blocked(time.Now(), 0) {
+			return errOEmbedNotFound
+		}
+		if folder.SharedPasswordHash.Valid {
+			return errOEmbedUnauthorized
+		}
+		embedPath = "/embed/shared/" + url.PathEscape(token)
+	} else {
+		slug := strings.Trim(target.Path, "/")
+		if slug == "" {
+			slug = target.Query().Get("folder")
+		}
+		if slug == "" || strings.Contains(slug, "/") {
+			return errOEmbedNotFound
+		}
+		var err error
+		if folder, err = s.getFolderBySlug(sanitizeFilename(slug)); err != nil {
+			return errOEmbedNotFound
+		}
+		if folder.Visibility != visibilityPublic {
+			return errOEmbedUnauthorized
+		}
+		embedPath = "/embed/" + url.PathEscape(folder.Slug)
+	}
+
+	src := absoluteURL(baseURL, embedPath) + opts.query()
+	resp.Type = "rich"
+	resp.Title = folder.Name
+	resp.Width = width
+	resp.Height = height
+	resp.HTML = fmt.Sprintf(`<iframe src="%s" width="%d" height="%d" title="%s" style="border:0" loading="lazy" allowfullscreen></iframe>`,
+		html.EscapeString(src), width, height, html.EscapeString(folder.Name))
+	return nil
+}
+
+func (s *Server) oEmbedImage(resp *oEmbedResponse, baseURL, token string, maxWidth, maxHeight int) error {
+	rec, err := s.getImageShareByToken(token)
+	if err != nil || rec.Limits.expired(time.Now(), 0) {
+		return errOEmbedNotFound
+	}
+	folder, err := s.getFolderByID(rec.FolderID)
+	if err != nil {
+		return errOEmbedNotFound
+	}
+	path, err := s.folderImagePath(folder, rec.Image)
+	if err != nil {
+		return errOEmbedNotFound
+	}
+
+	fileURL := absoluteURL(baseURL, "/i/"+rec.Token+"/file")
+	resp.Title = rec.Image
+	if cfg, ok := imageDimensions(path); ok {
+		resp.Type = "photo"
+		resp.URL = fileURL
+		resp.Width, resp.Height = fitSize(cfg.Width, cfg.Height, maxWidth, maxHeight)
+		return nil
+	}
+
+	// Formats without a registered decoder are embedded as markup instead.
+	resp.Type = "rich"
+	resp.Width, resp.Height = maxWidth, maxHeight
+	resp.HTML = fmt.Sprintf(`<a href="%s"><img src="%s" alt="%s" style="max-width:%dpx;max-height:%dpx"></a>`,
+		html.EscapeString(absoluteURL(baseURL, "/i/"+rec.Token)), html.EscapeString(fileURL), html.EscapeString(rec.Image), maxWidth, maxHeight)
+	return nil
+}
+
+func imageDimensions(path string) (image.Config, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, false
+	}
+	defer file.Close()
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return image.Config{}, false
+	}
+	return cfg, true
+}
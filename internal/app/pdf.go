@@ -0,0 +1,248 @@
+package app
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// pdfPage is a minimal single-page PDF builder for generated printouts.
+// Coordinates are in points with the origin in the bottom-left corner.
+type pdfPage struct {
+	width   float64
+	height  float64
+	content bytes.Buffer
+	images  []image.Image
+	fonts   map[string]bool
+}
+
+const (
+	pdfFontRegular = "F1"
+	pdfFontBold    = "F2"
+)
+
+var pdfFontNames = map[string]string{
+	pdfFontRegular: "Helvetica",
+	pdfFontBold:    "Helvetica-Bold",
+}
+
+// Glyph widths of the standard Helvetica fonts for ASCII 32-126, in 1/1000 em.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+// pdfTransliterate maps letters missing from WinAnsiEncoding, used by the
+// standard fonts, to their closest ASCII form.
+var pdfTransliterate = strings.NewReplacer(
+	"ą", "a", "ć", "c", "ę", "e", "ł", "l", "ń", "n", "ś", "s", "ź", "z", "ż", "z",
+	"Ą", "A", "Ć", "C", "Ę", "E", "Ł", "L", "Ń", "N", "Ś", "S", "Ź", "Z", "Ż", "Z",
+	"„", "\"", "”", "\"", "–", "-", "—", "-", "…", "...",
+)
+
+func pdfEncodeText(text string) []byte {
+	text = pdfTransliterate.Replace(text)
+	out := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r >= 32 && r <= 126:
+			out = append(out, byte(r))
+		case r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			out = append(out, '?')
+		}
+	}
+	return out
+}
+
+func pdfTextWidth(text string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, b := range pdfEncodeText(text) {
+		if b >= 32 && b <= 126 {
+			total += widths[b-32]
+		} else {
+			total += 556
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// wrapPDFText splits text into lines that fit within maxWidth.
+func wrapPDFText(text string, size float64, bold bool, maxWidth float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && pdfTextWidth(candidate, size, bold) > maxWidth {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func newPDFPage(width, height float64) *pdfPage {
+	return &pdfPage{width: width, height: height}
+}
+
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func pdfColor(c color.Color) string {
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("%s %s %s", pdfNumber(float64(r>>8)/255), pdfNumber(float64(g>>8)/255), pdfNumber(float64(b>>8)/255))
+}
+
+func (p *pdfPage) fillRect(x, y, w, h float64, c color.Color) {
+	fmt.Fprintf(&p.content, "%s rg %s %s %s %s re f\n", pdfColor(c), pdfNumber(x), pdfNumber(y), pdfNumber(w), pdfNumber(h))
+}
+
+func (p *pdfPage) text(x, y, size float64, bold bool, c color.Color, text string) {
+	font := pdfFontRegular
+	if bold {
+		font = pdfFontBold
+	}
+	if p.fonts == nil {
+		p.fonts = make(map[string]bool)
+	}
+	p.fonts[font] = true
+
+	var escaped bytes.Buffer
+	for _, b := range pdfEncodeText(text) {
+		if b == '(' || b == ')' || b == '\\' {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteByte(b)
+	}
+	fmt.Fprintf(&p.content, "BT %s rg /%s %s Tf %s %s Td (%s) Tj ET\n", pdfColor(c), font, pdfNumber(size), pdfNumber(x), pdfNumber(y), escaped.Bytes())
+}
+
+func (p *pdfPage) textCentered(centerX, y, size float64, bold bool, c color.Color, text string) {
+	p.text(centerX-pdfTextWidth(text, size, bold)/2, y, size, bold, c, text)
+}
+
+func (p *pdfPage) drawImage(img image.Image, x, y, w, h float64) {
+	name := fmt.Sprintf("Im%d", len(p.images))
+	p.images = append(p.images, img)
+	fmt.Fprintf(&p.content, "q %s 0 0 %s %s %s cm /%s Do Q\n", pdfNumber(w), pdfNumber(h), pdfNumber(x), pdfNumber(y), name)
+}
+
+func deflate(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+func (p *pdfPage) bytes() []byte {
+	var objects [][]byte
+	add := func(body []byte) int {
+		objects = append(objects, body)
+		return len(objects)
+	}
+	stream := func(dict string, data []byte) []byte {
+		return []byte(fmt.Sprintf("<< %s /Length %d >>\nstream\n%s\nendstream", dict, len(data), data))
+	}
+
+	catalogID := add(nil)
+	pagesID := add(nil)
+
+	var xobjects bytes.Buffer
+	for i, img := range p.images {
+		bounds := img.Bounds()
+		rgb := make([]byte, 0, bounds.Dx()*bounds.Dy()*3)
+		alpha := make([]byte, 0, bounds.Dx()*bounds.Dy())
+		translucent := false
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+				rgb = append(rgb, c.R, c.G, c.B)
+				alpha = append(alpha, c.A)
+				if c.A != 255 {
+					translucent = true
+				}
+			}
+		}
+		smask := ""
+		if translucent {
+			maskID := add(stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode",
+				bounds.Dx(), bounds.Dy()), deflate(alpha)))
+			smask = fmt.Sprintf(" /SMask %d 0 R", maskID)
+		}
+		imageID := add(stream(fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode%s",
+			bounds.Dx(), bounds.Dy(), smask), deflate(rgb)))
+		fmt.Fprintf(&xobjects, "/Im%d %d 0 R ", i, imageID)
+	}
+
+	var fonts bytes.Buffer
+	for _, font := range []string{pdfFontRegular, pdfFontBold} {
+		if !p.fonts[font] {
+			continue
+		}
+		fontID := add([]byte(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", pdfFontNames[font])))
+		fmt.Fprintf(&fonts, "/%s %d 0 R ", font, fontID)
+	}
+
+	contentID := add(stream("/Filter /FlateDecode", deflate(p.content.Bytes())))
+	resources := ""
+	if xobjects.Len() > 0 {
+		resources += "/XObject << " + xobjects.String() + ">> "
+	}
+	if fonts.Len() > 0 {
+		resources += "/Font << " + fonts.String() + ">>"
+	}
+	pageID := add([]byte(fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << %s >> /Contents %d 0 R >>",
+		pagesID, pdfNumber(p.width), pdfNumber(p.height), resources, contentID)))
+	objects[catalogID-1] = []byte(fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesID))
+	objects[pagesID-1] = []byte(fmt.Sprintf("<< /Type /Pages /Kids [%d 0 R] /Count 1 >>", pageID))
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	offsets := make([]int, len(objects))
+	for i, body := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n", i+1)
+		out.Write(body)
+		out.WriteString("\nendobj\n")
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, catalogID, xref)
+	return out.Bytes()
+}
@@ -0,0 +1,616 @@
+package app
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	pdfThumbWidth     = 480
+	pdfPageWidth      = 1200
+	pdfRenderTimeout  = 30 * time.Second
+	pdfMetadataMax    = 200
+	pdfViewerMaxPages = 50
+	pdfPreviewDirName = ".previews"
+
+	// pdfInflateBudget caps the decompressed bytes read from one document,
+	// so a file packed with small deflate bombs cannot exhaust memory.
+	pdfInflateBudget = 64 << 20
+	// pdfWorkers limits how many documents are parsed or rendered at once.
+	pdfWorkers = 2
+	// pdfImageMaxSide bounds embedded image dimensions before they are
+	// multiplied, so the pixel count cannot overflow.
+	pdfImageMaxSide = 10000
+)
+
+var (
+	errNoPDFPreview = errors.New("no preview for this page")
+	errPDFTooLarge  = errors.New("pdf stream exceeds the inflate budget")
+)
+
+var (
+	pdfStreamPattern = regexp.MustCompile(`stream\r?\n`)
+	pdfPagesPattern  = regexp.MustCompile(`/Type\s*/Pages\b[^>]*?/Count\s+(\d+)|/Count\s+(\d+)[^>]*?/Type\s*/Pages\b`)
+	pdfPagePattern   = regexp.MustCompile(`/Type\s*/Page\b`)
+	pdfLengthPattern = regexp.MustCompile(`/Length\s+(\d+)(\s+\d+\s+R)?`)
+)
+
+// pdfMetadata is what we keep about a PDF submission. Pages is zero when the
+// document could not be read.
+type pdfMetadata struct {
+	Pages  int
+	Title  string
+	Author string
+}
+
+// pdfRenderer turns one page of a PDF into an image of the given width.
+type pdfRenderer interface {
+	RenderPage(path string, page, width int) (image.Image, error)
+}
+
+// pdftoppmRenderer uses the poppler command line tool when it is installed.
+type pdftoppmRenderer struct {
+	bin string
+}
+
+func (p pdftoppmRenderer) RenderPage(path string, page, width int) (image.Image, error) {
+	dir, err := os.MkdirTemp("", "grafiki-pdf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(context.Background(), pdfRenderTimeout)
+	defer cancel()
+	n := strconv.Itoa(page)
+	out := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, p.bin, "-png", "-f", n, "-l", n, "-singlefile",
+		"-scale-to-x", strconv.Itoa(width), "-scale-to-y", "-1", path, out)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("pdftoppm: %v: %s", err, bytes.TrimSpace(output))
+	}
+
+	file, err := os.Open(out + ".png")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+// embeddedImageRenderer is the pure Go fallback. It cannot draw pages, so it
+// shows the first image embedded in the document as the cover.
+type embeddedImageRenderer struct{}
+
+func (embeddedImageRenderer) RenderPage(path string, page, width int) (image.Image, error) {
+	if page != 1 {
+		return nil, errNoPDFPreview
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	img := firstPDFImage(data)
+	if img == nil {
+		return nil, errNoPDFPreview
+	}
+	return img, nil
+}
+
+// newPDFRenderer prefers pdftoppm from the config or PATH and falls back to
+// embedded images.
+func newPDFRenderer(bin string) pdfRenderer {
+	if bin == "" {
+		bin, _ = exec.LookPath("pdftoppm")
+	}
+	if bin == "" {
+		return embeddedImageRenderer{}
+	}
+	return fallbackPDFRenderer{pdftoppmRenderer{bin: bin}, embeddedImageRenderer{}}
+}
+
+type fallbackPDFRenderer []pdfRenderer
+
+func (renderers fallbackPDFRenderer) RenderPage(path string, page, width int) (image.Image, error) {
+	err := errNoPDFPreview
+	for _, renderer := range renderers {
+		var img image.Image
+		if img, err = renderer.RenderPage(path, page, width); err == nil {
+			return img, nil
+		}
+	}
+	return nil, err
+}
+
+// pdfObjects returns the raw file followed by the inflated contents of its
+// object streams, so dictionaries packed by newer writers are found too.
+func pdfObjects(data []byte) [][]byte {
+	sources := [][]byte{data}
+	budget := int64(pdfInflateBudget)
+	for _, stream := range pdfStreams(data) {
+		if !bytes.Contains(stream.dict, []byte("/ObjStm")) || !bytes.Contains(stream.dict, []byte("/FlateDecode")) {
+			continue
+		}
+		inflated, err := inflate(stream.data, budget)
+		if errors.Is(err, errPDFTooLarge) {
+			break
+		}
+		if err == nil {
+			sources = append(sources, inflated)
+			budget -= int64(len(inflated))
+		}
+	}
+	return sources
+}
+
+// readPDFMetadata counts pages and reads the title and author. It is a best
+// effort reader for well formed files, not a full PDF parser.
+func readPDFMetadata(path string) (pdfMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return pdfMetadata{}, err
+	}
+	if !bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-")) {
+		return pdfMetadata{}, errors.New("not a PDF file")
+	}
+
+	var meta pdfMetadata
+	pages := 0
+	encrypted := bytes.Contains(data, []byte("/Encrypt"))
+	for _, source := range pdfObjects(data) {
+		for _, match := range pdfPagesPattern.FindAllSubmatch(source, -1) {
+			count := match[1]
+			if len(count) == 0 {
+				count = match[2]
+			}
+			if n, err := strconv.Atoi(string(count)); err == nil && n > meta.Pages {
+				meta.Pages = n
+			}
+		}
+		pages += len(pdfPagePattern.FindAll(source, -1))
+		if !encrypted {
+			if meta.Title == "" {
+				meta.Title = pdfInfoString(source, "Title")
+			}
+			if meta.Author == "" {
+				meta.Author = pdfInfoString(source, "Author")
+			}
+		}
+	}
+	if meta.Pages == 0 {
+		meta.Pages = pages
+	}
+	return meta, nil
+}
+
+// pdfInfoString reads a /Key (literal) or /Key <hex> string value.
+func pdfInfoString(data []byte, key string) string {
+	marker := []byte("/" + key)
+	for offset := 0; ; {
+		i := bytes.Index(data[offset:], marker)
+		if i < 0 {
+			return ""
+		}
+		rest := bytes.TrimLeft(data[offset+i+len(marker):], " \t\r\n")
+		offset += i + len(marker)
+
+		var raw []byte
+		switch {
+		case len(rest) > 0 && rest[0] == '(':
+			raw = pdfLiteralString(rest)
+		case len(rest) > 1 && rest[0] == '<' && rest[1] != '<':
+			end := bytes.IndexByte(rest, '>')
+			if end < 0 {
+				continue
+			}
+			raw = pdfHexString(rest[1:end])
+		default:
+			continue
+		}
+		value := strings.TrimSpace(pdfTextString(raw))
+		if len(value) > pdfMetadataMax {
+			value = value[:pdfMetadataMax]
+		}
+		return value
+	}
+}
+
+func pdfLiteralString(data []byte) []byte {
+	var out []byte
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '\\' && i+1 < len(data):
+			i++
+			switch e := data[i]; e {
+			case 'n':
+				out = append(out, '\n')
+			case 'r':
+				out = append(out, '\r')
+			case 't':
+				out = append(out, '\t')
+			case 'b':
+				out = append(out, '\b')
+			case 'f':
+				out = append(out, '\f')
+			case '\r', '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					n := 0
+					for j := 0; j < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; j++ {
+						n = n*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					out = append(out, byte(n))
+				} else {
+					out = append(out, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				out = append(out, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return out
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func pdfHexString(data []byte) []byte {
+	var digits []byte
+	for _, c := range data {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		n, _ := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		out[i] = byte(n)
+	}
+	return out
+}
+
+// pdfTextString decodes UTF-16BE strings with a byte order mark and treats
+// anything else as Latin-1, which is close enough to PDFDocEncoding.
+func pdfTextString(raw []byte) string {
+	if len(raw) >= 2 && raw[0] == 0xFE && raw[1] == 0xFF {
+		units := make([]uint16, 0, len(raw)/2)
+		for i := 2; i+1 < len(raw); i += 2 {
+			units = append(units, uint16(raw[i])<<8|uint16(raw[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if bytes.HasPrefix(raw, []byte{0xEF, 0xBB, 0xBF}) {
+		return string(raw[3:])
+	}
+	runes := make([]rune, len(raw))
+	for i, b := range raw {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+type pdfStream struct {
+	dict []byte
+	data []byte
+}
+
+// pdfStreams finds stream objects with their dictionaries. Lengths given as
+// indirect references are resolved by looking for the endstream keyword.
+func pdfStreams(data []byte) []pdfStream {
+	var streams []pdfStream
+	for _, loc := range pdfStreamPattern.FindAllIndex(data, -1) {
+		if loc[0] > 0 && isPDFNameChar(data[loc[0]-1]) {
+			continue
+		}
+		dictEnd := bytes.LastIndex(data[:loc[0]], []byte(">>"))
+		dictStart := bytes.LastIndex(data[:loc[0]], []byte("obj"))
+		if dictEnd < 0 || dictStart < 0 || dictStart > dictEnd {
+			continue
+		}
+		dict := data[dictStart:dictEnd]
+		start := loc[1]
+
+		end := -1
+		if m := pdfLengthPattern.FindSubmatch(dict); m != nil && len(m[2]) == 0 {
+			if n, err := strconv.Atoi(string(m[1])); err == nil && start+n <= len(data) {
+				end = start + n
+			}
+		}
+		if end < 0 {
+			i := bytes.Index(data[start:], []byte("endstream"))
+			if i < 0 {
+				continue
+			}
+			end = start + i
+		}
+		streams = append(streams, pdfStream{dict: dict, data: data[start:end]})
+	}
+	return streams
+}
+
+func isPDFNameChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// inflate decompresses a Flate stream and fails with errPDFTooLarge when it
+// holds more than limit bytes.
+func inflate(data []byte, limit int64) ([]byte, error) {
+	reader, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	out, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(out)) > limit {
+		return nil, errPDFTooLarge
+	}
+	return out, nil
+}
+
+func pdfDictInt(dict []byte, key string) int {
+	m := regexp.MustCompile(`/` + key + `\s+(\d+)`).FindSubmatch(dict)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(string(m[1]))
+	return n
+}
+
+// firstPDFImage decodes the first JPEG or plain 8-bit RGB/grey image in the
+// document.
+func firstPDFImage(data []byte) image.Image {
+	for _, stream := range pdfStreams(data) {
+		dict := stream.dict
+		if !bytes.Contains(dict, []byte("/Image")) {
+			continue
+		}
+		switch {
+		case bytes.Contains(dict, []byte("/DCTDecode")):
+			if img, err := jpeg.Decode(bytes.NewReader(stream.data)); err == nil {
+				return img
+			}
+		case bytes.Contains(dict, []byte("/FlateDecode")) && !bytes.Contains(dict, []byte("/DecodeParms")):
+			if img := decodeFlatePDFImage(dict, stream.data); img != nil {
+				return img
+			}
+		}
+	}
+	return nil
+}
+
+func decodeFlatePDFImage(dict, data []byte) image.Image {
+	width, height := pdfDictInt(dict, "Width"), pdfDictInt(dict, "Height")
+	if width <= 0 || height <= 0 || width > pdfImageMaxSide || height > pdfImageMaxSide ||
+		width*height > 40_000_000 || pdfDictInt(dict, "BitsPerComponent") != 8 {
+		return nil
+	}
+	channels := 0
+	switch {
+	case bytes.Contains(dict, []byte("/DeviceRGB")):
+		channels = 3
+	case bytes.Contains(dict, []byte("/DeviceGray")):
+		channels = 1
+	default:
+		return nil
+	}
+	pixels, err := inflate(data, int64(width*height*channels))
+	if err != nil || len(pixels) < width*height*channels {
+		return nil
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := (y*width + x) * channels
+			if channels == 3 {
+				img.SetNRGBA(x, y, color.NRGBA{R: pixels[i], G: pixels[i+1], B: pixels[i+2], A: 255})
+			} else {
+				img.SetNRGBA(x, y, color.NRGBA{R: pixels[i], G: pixels[i], B: pixels[i], A: 255})
+			}
+		}
+	}
+	return img
+}
+
+// isPDF reports whether the entry holds a PDF document.
+func (e submissionEntryRecord) isPDF() bool {
+	return strings.EqualFold(filepath.Ext(e.OriginalName), ".pdf")
+}
+
+// withPDFWorker runs fn in one of the PDF worker slots. The slot is released
+// and a parser panic is turned into an error, so a broken document can
+// neither leak a slot nor take down the metadata goroutine.
+func (s *Server) withPDFWorker(fn func() error) (err error) {
+	s.pdfWork <- struct{}{}
+	defer func() {
+		<-s.pdfWork
+		if p := recover(); p != nil {
+			err = fmt.Errorf("pdf parser panic: %v", p)
+		}
+	}()
+	return fn()
+}
+
+// queuePDFMetadata reads the metadata of a new upload in the background so
+// the upload request does not wait for the parser.
+func (s *Server) queuePDFMetadata(entry *submissionEntryRecord, group *submissionGroupRecord) {
+	go s.recordPDFMetadata(entry, group)
+}
+
+// recordPDFMetadata stores the page count, title and author of a PDF entry.
+// Unreadable documents are stored with zero pages so they are not parsed on
+// every preview request.
+func (s *Server) recordPDFMetadata(entry *submissionEntryRecord, group *submissionGroupRecord) pdfMetadata {
+	var meta pdfMetadata
+	if path, err := s.submissionFilePath(entry, group); err == nil {
+		err = s.withPDFWorker(func() (err error) {
+			meta, err = readPDFMetadata(path)
+			return err
+		})
+		if err != nil {
+			log.Printf("read pdf metadata for submission %d: %v", entry.ID, err)
+		}
+	}
+	if _, err := s.db.Exec(`UPDATE submissions SET pdf_pages = ?, pdf_title = ?, pdf_author = ? WHERE id = ?`, meta.Pages,
+		sql.NullString{String: meta.Title, Valid: meta.Title != ""}, sql.NullString{String: meta.Author, Valid: meta.Author != ""}, entry.ID); err != nil {
+		log.Printf("save pdf metadata: %v", err)
+	}
+	return meta
+}
+
+// removeLegacyPreviews deletes page caches that older versions kept inside
+// the group directories.
+func removeLegacyPreviews(submissionsDir string) {
+	dirs, err := filepath.Glob(filepath.Join(submissionsDir, "*", pdfPreviewDirName))
+	if err != nil {
+		return
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("remove legacy pdf previews: %v", err)
+		}
+	}
+}
+
+func (s *Server) removeGroupPreviews(groupID int64) error {
+	rows, err := s.db.Query(`SELECT id FROM submissions WHERE group_id = ? AND pdf_pages IS NOT NULL`, groupID)
+	if err != nil {
+		return err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		s.removeSubmissionPreviews(id)
+	}
+	return nil
+}
+
+// removeSubmissionPreviews drops cached page images after the file changed.
+func (s *Server) removeSubmissionPreviews(entryID int64) {
+	matches, err := filepath.Glob(filepath.Join(s.previewDir, fmt.Sprintf("%d-*.png", entryID)))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("remove pdf preview: %v", err)
+		}
+	}
+}
+
+// serveSubmissionPreview renders a page of a PDF entry as PNG:
+// /submitted/file/<id>/preview?page=N&size=thumb|page. Rendered pages are
+// cached in the preview directory, outside of the gallery.
+func (s *Server) serveSubmissionPreview(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) {
+	if !entry.isPDF() {
+		http.NotFound(w, r)
+		return
+	}
+	source, err := s.submissionFilePath(entry, group)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	pages := int(entry.PDFPages.Int64)
+	if !entry.PDFPages.Valid {
+		pages = s.recordPDFMetadata(entry, group).Pages
+	}
+	page := 1
+	if raw := r.URL.Query().Get("page"); raw != "" {
+		if page, err = strconv.Atoi(raw); err != nil || page < 1 || (pages > 0 && page > pages) || page > pdfViewerMaxPages {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	size, width := "thumb", pdfThumbWidth
+	if r.URL.Query().Get("size") == "page" {
+		size, width = "page", pdfPageWidth
+	}
+
+	cached := filepath.Join(s.previewDir, fmt.Sprintf("%d-%d-%s.png", entry.ID, page, size))
+	if info, err := os.Stat(cached); err == nil && !info.IsDir() {
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		http.ServeFile(w, r, cached)
+		return
+	}
+
+	var img image.Image
+	err = s.withPDFWorker(func() (err error) {
+		img, err = s.pdfRenderer.RenderPage(source, page, width)
+		return err
+	})
+	if err != nil {
+		if !errors.Is(err, errNoPDFPreview) {
+			log.Printf("render pdf preview for submission %d: %v", entry.ID, err)
+		}
+		http.NotFound(w, r)
+		return
+	}
+	if img.Bounds().Dx() > width {
+		height := img.Bounds().Dy() * width / img.Bounds().Dx()
+		img = scaleImage(img, width, max(height, 1))
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		log.Printf("encode pdf preview: %v", err)
+		http.Error(w, "preview failed", http.StatusInternalServerError)
+		return
+	}
+	if err := EnsureDir(filepath.Dir(cached)); err == nil {
+		if err := os.WriteFile(cached, buf.Bytes(), 0o644); err != nil {
+			log.Printf("cache pdf preview: %v", err)
+		}
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+)
+
+func TestDecodeFlatePDFImageRejectsHugeDimensions(t *testing.T) {
+	var empty bytes.Buffer
+	zw := zlib.NewWriter(&empty)
+	zw.Close()
+
+	// 4294967296 * 4294967296 wraps to 0 when multiplied.
+	dict := []byte("<< /Type /XObject /Subtype /Image /Width 4294967296 /Height 4294967296 /BitsPerComponent 8 /ColorSpace /DeviceGray /Filter /FlateDecode >>")
+	if img := decodeFlatePDFImage(dict, empty.Bytes()); img != nil {
+		t.Fatalf("decodeFlatePDFImage() = %v, want nil", img.Bounds())
+	}
+}
+
+func TestWithPDFWorkerReleasesSlotOnPanic(t *testing.T) {
+	s := &Server{pdfWork: make(chan struct{}, pdfWorkers)}
+	for range pdfWorkers + 1 {
+		if err := s.withPDFWorker(func() error { panic("broken document") }); err == nil {
+			t.Fatal("withPDFWorker() = nil, want error from the panic")
+		}
+	}
+	if n := len(s.pdfWork); n != 0 {
+		t.Fatalf("%d worker slots still taken", n)
+	}
+}
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"image/color"
+	"log"
+	"net/http"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const posterNoteLimit = 300
+
+var posterSizes = map[string][2]float64{
+	"a4": {595.28, 841.89},
+	"a5": {419.53, 595.28},
+}
+
+var (
+	posterInk    = color.NRGBA{R: 15, G: 23, B: 42, A: 255}
+	posterMuted  = color.NRGBA{R: 71, G: 85, B: 105, A: 255}
+	posterAccent = color.NRGBA{R: 59, G: 130, B: 246, A: 255}
+)
+
+// renderSubmissionPoster lays out a single printable page. All sizes are
+// derived from the page width so A4 and A5 share the same composition.
+func renderSubmissionPoster(groupName, link, note, rulesLine string, width, height float64) ([]byte, error) {
+	code, err := qrcode.New(link, qrcode.High)
+	if err != nil {
+		return nil, err
+	}
+
+	page := newPDFPage(width, height)
+	scale := width / posterSizes["a4"][0]
+	margin := 48 * scale
+	center := width / 2
+	textWidth := width - 2*margin
+
+	page.fillRect(0, height-14*scale, width, 14*scale, posterAccent)
+
+	y := height - margin - 24*scale
+	page.textCentered(center, y, 16*scale, false, posterMuted, "Przeslij swoje zdjecia")
+	y -= 40 * scale
+	for _, line := range wrapPDFText(groupName, 34*scale, true, textWidth) {
+		page.textCentered(center, y, 34*scale, true, posterInk, line)
+		y -= 40 * scale
+	}
+
+	qrSide := width * 0.55
+	y -= qrSide - 10*scale
+	drawQRModules(page, code.Bitmap(), center-qrSide/2, y, qrSide, posterInk)
+
+	y -= 28 * scale
+	steps := []string{
+		"1. Zeskanuj kod aparatem telefonu.",
+		"2. Wpisz swoje imie i wybierz plik.",
+		"3. Kliknij Przeslij - gotowe!",
+	}
+	for _, step := range steps {
+		page.textCentered(center, y, 15*scale, true, posterInk, step)
+		y -= 22 * scale
+	}
+
+	if note != "" {
+		y -= 6 * scale
+		for _, line := range wrapPDFText(note, 13*scale, false, textWidth) {
+			page.textCentered(center, y, 13*scale, false, posterInk, line)
+			y -= 18 * scale
+		}
+	}
+
+	footer := margin
+	page.textCentered(center, footer+18*scale, 11*scale, false, posterMuted, rulesLine)
+	for i, line := range wrapPDFText(link, 9*scale, false, textWidth) {
+		page.textCentered(center, footer-float64(i)*12*scale, 9*scale, false, posterMuted, line)
+	}
+	return page.bytes(), nil
+}
+
+func (s *Server) handleSubmissionGroupPoster(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	sizeName := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("size")))
+	if sizeName == "" {
+		sizeName = "a4"
+	}
+	size, ok := posterSizes[sizeName]
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Nieobslugiwany format strony")
+		return
+	}
+	note := truncateRunes(r.URL.Query().Get("note"), posterNoteLimit)
+
+	group, err := s.getSubmissionGroupByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Grupa nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac grupy")
+		return
+	}
+
+	var link string
+	switch group.Visibility {
+	case visibilityPublic:
+		link = group.toView(requestBaseURL(r)).ShareURL
+	case visibilityShared:
+		token, err := s.ensureSubmissionSharedToken(group.ID)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie przygotowac linku")
+			return
+		}
+		if linkID := r.URL.Query().Get("link"); linkID != "" {
+			shareLink, ok := s.shareLinkForQR(w, linkID, shareKindGroup, group.ID)
+			if !ok {
+				return
+			}
+			token = shareLink.Token
+		}
+		link = shareLinkURL(requestBaseURL(r), shareKindGroup, token)
+	default:
+		writeJSONError(w, http.StatusBadRequest, "Grupa prywatna nie ma linku do wydruku")
+		return
+	}
+
+	rules := group.Rules.toView()
+	data, err := renderSubmissionPoster(group.Name, link, note, strings.Join(rules.Summary[:2], " "), size[0], size[1])
+	if err != nil {
+		log.Printf("submission poster: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie wygenerowac plakatu")
+		return
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "plakat")
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"plakat-"+group.Slug+".pdf\"")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("write poster: %v", err)
+	}
+}
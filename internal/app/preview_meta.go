@@ -0,0 +1,107 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// pageMeta holds the Open Graph and Twitter card data used by chat apps and
+// social sites to render link previews.
+type pageMeta struct {
+	Title       string
+	Description string
+	URL         string
+	Image       string
+	ImageAlt    string
+	OEmbedURL   string
+}
+
+func (m *pageMeta) TwitterCard() string {
+	if m.Image != "" {
+		return "summary_large_image"
+	}
+	return "summary"
+}
+
+func absoluteURL(baseURL, path string) string {
+	if path == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+func requestPageURL(r *http.Request) string {
+	return absoluteURL(requestBaseURL(r), r.URL.RequestURI())
+}
+
+func oEmbedDiscoveryURL(r *http.Request, pageURL string) string {
+	return absoluteURL(requestBaseURL(r), "/oembed?format=json&url="+url.QueryEscape(pageURL))
+}
+
+// previewImageCandidate reports whether link preview crawlers can be
+// expected to render the file; most of them skip SVG and AVIF.
+func previewImageCandidate(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+		return true
+	default:
+		return false
+	}
+}
+
+func imageCountLabel(count int) string {
+	switch {
+	case count == 1:
+		return "1 zdjecie"
+	case count%10 >= 2 && count%10 <= 4 && (count%100 < 12 || count%100 > 14):
+		return fmt.Sprintf("%d zdjecia", count)
+	default:
+		return fmt.Sprintf("%d zdjec", count)
+	}
+}
+
+func folderPageMeta(r *http.Request, name string, images []imageInfo) *pageMeta {
+	meta := &pageMeta{
+		Title:       name,
+		Description: "Galeria zdjec - " + imageCountLabel(len(images)) + ".",
+		URL:         requestPageURL(r),
+	}
+	meta.OEmbedURL = oEmbedDiscoveryURL(r, meta.URL)
+	for _, img := range images {
+		if previewImageCandidate(img.Name) {
+			meta.Image = absoluteURL(requestBaseURL(r), img.URL)
+			meta.ImageAlt = img.Name
+			break
+		}
+	}
+	return meta
+}
+
+func submissionGroupPageMeta(r *http.Request, name string, allowUpload bool) *pageMeta {
+	description := "Zdjecia przeslane do grupy " + name + "."
+	if allowUpload {
+		description = "Przeslij swoje zdjecia do grupy " + name + "."
+	}
+	return &pageMeta{
+		Title:       name,
+		Description: description,
+		URL:         requestPageURL(r),
+	}
+}
+
+func imageSharePageMeta(r *http.Request, page imageSharePage) *pageMeta {
+	meta := &pageMeta{
+		Title:       page.Name,
+		Description: "Zdjecie z galerii " + page.FolderName + ".",
+		URL:         requestPageURL(r),
+	}
+	meta.OEmbedURL = oEmbedDiscoveryURL(r, meta.URL)
+	if previewImageCandidate(page.Name) {
+		meta.Image = absoluteURL(requestBaseURL(r), page.ImageURL)
+		meta.ImageAlt = page.Name
+	}
+	return meta
+}
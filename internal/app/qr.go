@@ -0,0 +1,320 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	qrDefaultSize = 256
+	qrMinSize     = 64
+	qrMaxSize     = 4096
+	// qrLogoRatio is the logo width relative to the whole code. High error
+	// correction restores up to 30% of the modules, the logo covers far less.
+	qrLogoRatio = 0.22
+)
+
+var (
+	errQROptions = errors.New("nieprawidlowe parametry kodu QR")
+	errQRNoLogo  = errors.New("brak logo do umieszczenia w kodzie QR")
+)
+
+type qrOptions struct {
+	Size       int
+	Level      qrcode.RecoveryLevel
+	Foreground color.NRGBA
+	Background color.NRGBA
+	Format     string
+	Logo       bool
+}
+
+func parseHexColor(value string, fallback color.NRGBA) (color.NRGBA, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "#")
+	if value == "" {
+		return fallback, nil
+	}
+	if len(value) == 3 {
+		value = string([]byte{value[0], value[0], value[1], value[1], value[2], value[2]})
+	}
+	if len(value) != 6 {
+		return fallback, errQROptions
+	}
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return fallback, errQROptions
+	}
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+func parseQROptions(query url.Values) (qrOptions, error) {
+	opts := qrOptions{
+		Size:       qrDefaultSize,
+		Level:      qrcode.Medium,
+		Foreground: color.NRGBA{A: 255},
+		Background: color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		Format:     "png",
+	}
+
+	if raw := strings.TrimSpace(query.Get("size")); raw != "" {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < qrMinSize || size > qrMaxSize {
+			return opts, errQROptions
+		}
+		opts.Size = size
+	}
+
+	switch strings.ToUpper(strings.TrimSpace(query.Get("level"))) {
+	case "":
+	case "L":
+		opts.Level = qrcode.Low
+	case "M":
+		opts.Level = qrcode.Medium
+	case "Q":
+		opts.Level = qrcode.High
+	case "H":
+		opts.Level = qrcode.Highest
+	default:
+		return opts, errQROptions
+	}
+
+	var err error
+	if opts.Foreground, err = parseHexColor(query.Get("fg"), opts.Foreground); err != nil {
+		return opts, err
+	}
+	if opts.Background, err = parseHexColor(query.Get("bg"), opts.Background); err != nil {
+		return opts, err
+	}
+	if opts.Foreground == opts.Background {
+		return opts, errQROptions
+	}
+
+	switch format := strings.ToLower(strings.TrimSpace(query.Get("format"))); format {
+	case "":
+	case "png", "svg", "pdf":
+		opts.Format = format
+	default:
+		return opts, errQROptions
+	}
+
+	switch strings.ToLower(query.Get("logo")) {
+	case "", "0", "false":
+	case "1", "true":
+		opts.Logo = true
+		opts.Level = qrcode.Highest
+	default:
+		return opts, errQROptions
+	}
+	return opts, nil
+}
+
+// scaleImage resizes by averaging the source pixels covered by each target
+// pixel, which is good enough for shrinking an icon into a QR code.
+func scaleImage(src image.Image, width, height int) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	b := src.Bounds()
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(src.At(sx, sy)).(color.NRGBA)
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{R: uint8(r / a), G: uint8(g / a), B: uint8(bl / a), A: uint8(a / n)})
+		}
+	}
+	return dst
+}
+
+// qrLogoTile returns the logo placed on a square of the background colour,
+// so the modules under it do not bleed through transparent pixels.
+func qrLogoTile(logo image.Image, size int, background color.NRGBA) *image.NRGBA {
+	tile := image.NewNRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(tile, tile.Bounds(), &image.Uniform{C: background}, image.Point{}, draw.Src)
+	padding := size / 10
+	inner := size - 2*padding
+	if inner < 1 {
+		return tile
+	}
+	scaled := scaleImage(logo, inner, inner)
+	draw.Draw(tile, image.Rect(padding, padding, padding+inner, padding+inner), scaled, image.Point{}, draw.Over)
+	return tile
+}
+
+func (s *Server) qrLogo() (image.Image, error) {
+	if strings.TrimSpace(s.favicon) == "" {
+		return nil, errQRNoLogo
+	}
+	logo, err := loadLogoImage(s.favicon)
+	if err != nil {
+		log.Printf("qr logo: %v", err)
+		return nil, errQRNoLogo
+	}
+	return logo, nil
+}
+
+func (s *Server) renderQR(link string, opts qrOptions) ([]byte, string, error) {
+	code, err := qrcode.New(link, opts.Level)
+	if err != nil {
+		return nil, "", err
+	}
+	code.ForegroundColor = opts.Foreground
+	code.BackgroundColor = opts.Background
+
+	var logo image.Image
+	if opts.Logo {
+		if logo, err = s.qrLogo(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	switch opts.Format {
+	case "svg":
+		return renderQRSVG(code.Bitmap(), opts, logo), "image/svg+xml", nil
+	case "pdf":
+		return renderQRPDF(code.Bitmap(), opts, logo), "application/pdf", nil
+	}
+
+	src := code.Image(opts.Size)
+	img := image.NewNRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+	if logo != nil {
+		size := int(float64(img.Bounds().Dx()) * qrLogoRatio)
+		offset := (img.Bounds().Dx() - size) / 2
+		draw.Draw(img, image.Rect(offset, offset, offset+size, offset+size), qrLogoTile(logo, size, opts.Background), image.Point{}, draw.Src)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+func svgColor(c color.NRGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func renderQRSVG(bitmap [][]bool, opts qrOptions, logo image.Image) []byte {
+	modules := len(bitmap)
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n",
+		opts.Size, opts.Size, modules, modules)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`+"\n", modules, modules, svgColor(opts.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, svgColor(opts.Foreground))
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+	buf.WriteString(`"/>` + "\n")
+	if logo != nil {
+		size := float64(modules) * qrLogoRatio
+		offset := (float64(modules) - size) / 2
+		var tile bytes.Buffer
+		png.Encode(&tile, qrLogoTile(logo, 256, opts.Background))
+		fmt.Fprintf(&buf, `<image x="%s" y="%s" width="%s" height="%s" href="data:image/png;base64,%s"/>`+"\n",
+			pdfNumber(offset), pdfNumber(offset), pdfNumber(size), pdfNumber(size), base64.StdEncoding.EncodeToString(tile.Bytes()))
+	}
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// drawQRModules paints the code as vector rectangles inside a square of the
+// given side, with (x, y) as its bottom-left corner.
+func drawQRModules(page *pdfPage, bitmap [][]bool, x, y, side float64, foreground color.Color) {
+	modules := len(bitmap)
+	if modules == 0 {
+		return
+	}
+	unit := side / float64(modules)
+	for row, cells := range bitmap {
+		top := y + side - float64(row+1)*unit
+		for col := 0; col < len(cells); col++ {
+			if !cells[col] {
+				continue
+			}
+			start := col
+			for col < len(cells) && cells[col] {
+				col++
+			}
+			page.fillRect(x+float64(start)*unit, top, float64(col-start)*unit, unit, foreground)
+		}
+	}
+}
+
+func renderQRPDF(bitmap [][]bool, opts qrOptions, logo image.Image) []byte {
+	side := float64(opts.Size)
+	page := newPDFPage(side, side)
+	page.fillRect(0, 0, side, side, opts.Background)
+	drawQRModules(page, bitmap, 0, 0, side, opts.Foreground)
+	if logo != nil {
+		size := side * qrLogoRatio
+		offset := (side - size) / 2
+		page.drawImage(qrLogoTile(logo, 256, opts.Background), offset, offset, size, size)
+	}
+	return page.bytes()
+}
+
+// writeShareQR renders the QR code for a share link using the size, level,
+// fg, bg, format and logo query parameters.
+func (s *Server) writeShareQR(w http.ResponseWriter, r *http.Request, link, basename string) {
+	opts, err := parseQROptions(r.URL.Query())
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe parametry kodu QR")
+		return
+	}
+	data, contentType, err := s.renderQR(link, opts)
+	if err != nil {
+		if errors.Is(err, errQRNoLogo) {
+			writeJSONError(w, http.StatusBadRequest, "Brak logo do umieszczenia w kodzie QR")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie wygenerowac QR")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+basename+"-qr."+opts.Format+"\"")
+	if _, err := w.Write(data); err != nil {
+		log.Printf("write qr: %v", err)
+	}
+}
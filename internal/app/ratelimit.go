@@ -0,0 +1,68 @@
+package app
+
+import (
+	"sync"
+	"time"
+)
+
+type attemptLimiter struct {
+	mu       sync.Mutex
+	max      int
+	window   time.Duration
+	attempts map[string]*attemptWindow
+}
+
+type attemptWindow struct {
+	count   int
+	resetAt time.Time
+}
+
+func newAttemptLimiter(max int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		max:      max,
+		window:   window,
+		attempts: make(map[string]*attemptWindow),
+	}
+}
+
+// blocked reports whether the key used up its attempts and how long it has
+// to wait before trying again.
+func (l *attemptLimiter) blocked(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+	entry, ok := l.attempts[key]
+	if !ok || entry.count < l.max {
+		return false, 0
+	}
+	return true, entry.resetAt.Sub(now)
+}
+
+func (l *attemptLimiter) fail(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	entry, ok := l.attempts[key]
+	if !ok || !now.Before(entry.resetAt) {
+		entry = &attemptWindow{resetAt: now.Add(l.window)}
+		l.attempts[key] = entry
+	}
+	entry.count++
+}
+
+func (l *attemptLimiter) reset(key string) {
+	l.mu.Lock()
+	delete(l.attempts, key)
+	l.mu.Unlock()
+}
+
+func (l *attemptLimiter) prune(now time.Time) {
+	for key, entry := range l.attempts {
+		if !now.Before(entry.resetAt) {
+			delete(l.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package app
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	scanStatusClean    = "clean"
+	scanStatusInfected = "infected"
+	scanStatusFailed   = "error"
+
+	clamdTimeout   = 60 * time.Second
+	clamdChunkSize = 64 << 10
+)
+
+var scanStatusLabels = map[string]string{
+	scanStatusClean:    "Sprawdzony skanerem",
+	scanStatusInfected: "Zablokowany przez skaner",
+	scanStatusFailed:   "Nie sprawdzono (blad skanera)",
+}
+
+type scanResult struct {
+	Infected  bool
+	Signature string
+}
+
+// fileScanner checks an uploaded file for malware before it is stored.
+type fileScanner interface {
+	Scan(r io.Reader) (scanResult, error)
+}
+
+// clamdScanner streams files to a ClamAV daemon with the INSTREAM command.
+type clamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// newClamdScanner accepts tcp://host:port, unix:///path/clamd.sock, a bare
+// host:port or an absolute socket path.
+func newClamdScanner(addr string) (*clamdScanner, error) {
+	addr = strings.TrimSpace(addr)
+	scanner := &clamdScanner{network: "tcp", address: addr, timeout: clamdTimeout}
+	switch {
+	case strings.HasPrefix(addr, "tcp://"):
+		scanner.address = strings.TrimPrefix(addr, "tcp://")
+	case strings.HasPrefix(addr, "unix://"):
+		scanner.network, scanner.address = "unix", strings.TrimPrefix(addr, "unix://")
+	case strings.HasPrefix(addr, "unix:"):
+		scanner.network, scanner.address = "unix", strings.TrimPrefix(addr, "unix:")
+	case strings.HasPrefix(addr, "/"):
+		scanner.network = "unix"
+	}
+	if scanner.address == "" {
+		return nil, fmt.Errorf("invalid clamd address %q", addr)
+	}
+	if scanner.network == "tcp" {
+		if _, _, err := net.SplitHostPort(scanner.address); err != nil {
+			return nil, fmt.Errorf("invalid clamd address %q: %w", addr, err)
+		}
+	}
+	return scanner, nil
+}
+
+func (c *clamdScanner) Scan(r io.Reader) (scanResult, error) {
+	conn, err := net.DialTimeout(c.network, c.address, c.timeout)
+	if err != nil {
+		return scanResult{}, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return scanResult{}, err
+	}
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return scanResult{}, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return scanResult{}, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return scanResult{}, readErr
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return scanResult{}, err
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return scanResult{}, err
+	}
+	return parseClamdReply(reply)
+}
+
+// parseClamdReply reads answers like "stream: OK" or
+// "stream: Eicar-Signature FOUND".
+func parseClamdReply(reply string) (scanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream:")
+	reply = strings.TrimSpace(reply)
+	switch {
+	case reply == "OK":
+		return scanResult{}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return scanResult{Infected: true, Signature: strings.TrimSuffix(reply, " FOUND")}, nil
+	default:
+		return scanResult{}, errors.New("clamd: " + reply)
+	}
+}
+
+// scanUpload runs the configured scanner and rewinds the file. It returns an
+// empty status when scanning is turned off.
+func (s *Server) scanUpload(file io.ReadSeeker) (string, string) {
+	if s.scanner == nil {
+		return "", ""
+	}
+	result, err := s.scanner.Scan(file)
+	if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil && err == nil {
+		err = seekErr
+	}
+	if err != nil {
+		log.Printf("scan upload: %v", err)
+		return scanStatusFailed, ""
+	}
+	if result.Infected {
+		return scanStatusInfected, result.Signature
+	}
+	return scanStatusClean, ""
+}
+
+// quarantineUpload keeps an infected upload outside of the gallery so it is
+// never served, and returns the name it was stored under.
+func (s *Server) quarantineUpload(file io.ReadSeeker, filename string) (string, error) {
+	if err := EnsureDir(s.quarantineDir); err != nil {
+		return "", err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	name := time.Now().Format("20060102-150405") + "-" + filename
+	target, err := uniqueFilename(s.quarantineDir, name)
+	if err != nil {
+		return "", err
+	}
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, file); err != nil {
+		dst.Close()
+		os.Remove(target)
+		return "", err
+	}
+	return filepath.Base(target), dst.Close()
+}
+
+func (s *Server) removeQuarantined(name string) {
+	if name == "" || name != filepath.Base(name) {
+		return
+	}
+	if err := os.Remove(filepath.Join(s.quarantineDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("remove quarantined file: %v", err)
+	}
+}
+
+// quarantineSubmission moves an infected upload into quarantine and records
+// it as a rejected entry so admins can see what was blocked.
+func (s *Server) quarantineSubmission(r *http.Request, group *submissionGroupRecord, uploader, token string, header *multipart.FileHeader, file io.ReadSeeker, signature string) {
+	name, err := s.quarantineUpload(file, sanitizeFilename(header.Filename))
+	if err != nil {
+		log.Printf("quarantine submission: %v", err)
+		return
+	}
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if _, err := s.db.Exec(`INSERT INTO submissions (group_id, uploader_name, contributor_token, filename, original_name, mime_type, size_bytes, status,
+		review_note, reviewed_at, scan_status, scan_signature) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP, ?, ?)`,
+		group.ID, uploader, token, name, header.Filename, mimeType, header.Size, submissionStatusRejected,
+		"Plik zablokowany przez skaner antywirusowy", scanStatusInfected, signature); err != nil {
+		log.Printf("quarantine submission: %v", err)
+	}
+	if s.logger != nil {
+		s.logger.Log(r, "wirus")
+	}
+}
@@ -0,0 +1,113 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeClamd accepts one INSTREAM session per connection, collects the
+// streamed bytes and answers with reply.
+func fakeClamd(t *testing.T, reply string) (string, chan []byte) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan []byte, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				command, err := r.ReadString(0)
+				if err != nil || command != "zINSTREAM\x00" {
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+						return
+					}
+					if size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+						return
+					}
+				}
+				received <- data.Bytes()
+				conn.Write([]byte(reply + "\x00"))
+			}()
+		}
+	}()
+	return "tcp://" + ln.Addr().String(), received
+}
+
+func TestClamdScannerReplies(t *testing.T) {
+	tests := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{reply: "stream: OK"},
+		{reply: "stream: Eicar-Test-Signature FOUND", infected: true, signature: "Eicar-Test-Signature"},
+		{reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.reply, func(t *testing.T) {
+			addr, received := fakeClamd(t, tt.reply)
+			scanner, err := newClamdScanner(addr)
+			if err != nil {
+				t.Fatalf("newClamdScanner: %v", err)
+			}
+
+			// Larger than one chunk, so the framing is exercised.
+			payload := strings.Repeat("grafiki", clamdChunkSize/3)
+			result, err := scanner.Scan(strings.NewReader(payload))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Scan() = %+v, want error", result)
+				}
+			} else if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Fatalf("Scan() = %+v, want infected=%v signature=%q", result, tt.infected, tt.signature)
+			}
+			if got := <-received; string(got) != payload {
+				t.Fatalf("clamd received %d bytes, want %d", len(got), len(payload))
+			}
+		})
+	}
+}
+
+func TestNewServerRejectsDirsInsideGallery(t *testing.T) {
+	db := openTestDatabase(t)
+	root := t.TempDir()
+	gallery := filepath.Join(root, "gallery")
+	for name, opts := range map[string]ServerOptions{
+		"quarantine": {Dir: gallery, DB: db, QuarantineDir: filepath.Join(gallery, "quarantine")},
+		"previews":   {Dir: gallery, DB: db, PreviewDir: filepath.Join(gallery, "sub", "previews")},
+	} {
+		if _, err := NewServer(opts); err == nil {
+			t.Errorf("%s inside the gallery: NewServer succeeded", name)
+		}
+	}
+	if _, err := NewServer(ServerOptions{Dir: gallery, DB: db, QuarantineDir: filepath.Join(root, "gallery-quarantine")}); err != nil {
+		t.Errorf("sibling quarantine directory: %v", err)
+	}
+}
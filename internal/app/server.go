@@ -3,12 +3,15 @@ package app
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 type ServerOptions struct {
@@ -19,11 +22,20 @@ type ServerOptions struct {
 	Logger   *RequestLogger
 	DB       *sql.DB
 	Favicon  string
+	// QuarantineDir keeps uploads blocked by the malware scanner. It must be
+	// outside of Dir so they are never served.
+	QuarantineDir string
+	// PreviewDir caches rendered PDF pages. Like QuarantineDir it must be
+	// outside of Dir.
+	PreviewDir string
 }
 
 type imageInfo struct {
-	Name string
-	URL  string
+	Name         string
+	URL          string
+	Author       string
+	OriginalName string
+	Comments     int
 }
 
 type pageData struct {
@@ -43,6 +55,34 @@ type pageData struct {
 	AllowSubmissionUpload     bool
 	SubmissionShareLink       string
 	SubmissionUploadLimit     int
+	SubmissionReceipt         string
+	SubmissionStatus          string
+	SubmissionStatusFilters   []submissionStatusFilter
+	PublishFolders            []folderView
+	SubmissionChanges         []submissionChangeView
+	SubmissionFieldTypes      []submissionFieldType
+	SubmissionInvite          *submissionInviteView
+	SubmissionInvites         []submissionInviteView
+	SubmissionInvitesDone     int
+	SubmissionVoting          bool
+	SubmissionResultsVisible  bool
+	SubmissionResultsHidden   bool
+	SubmissionResults         []submissionResultView
+	JuryScoreOptions          []int
+	SubmissionComments        bool
+	Webhooks                  []webhookView
+	WebhookDeliveries         []webhookDeliveryView
+	WebhookEvents             []webhookEventOption
+	ShareExpired              bool
+	SharePasswordRequired     bool
+	ShareUnlockKind           string
+	ShareUnlockToken          string
+	ShareToken                string
+	ShareAllowView            bool
+	ShareAllowDownload        bool
+	ShareAllowUpload          bool
+	Meta                      *pageMeta
+	Feeds                     []feedLink
 }
 
 type Server struct {
@@ -54,6 +94,20 @@ type Server struct {
 	logger         *RequestLogger
 	db             *sql.DB
 	favicon        string
+	secret         []byte
+	unlockLimiter  *attemptLimiter
+	receiptLimiter *attemptLimiter
+	voteLimiter    *attemptLimiter
+	commentLimiter *attemptLimiter
+	webhookWake    chan struct{}
+	scanner        fileScanner
+	quarantineDir  string
+	previewDir     string
+	pdfRenderer    pdfRenderer
+	pdfWork        chan struct{}
+	proxies        trustedProxies
+	proxyWarning   sync.Once
+	linkLimiter    *attemptLimiter
 }
 
 func NewServer(opts ServerOptions) (*Server, error) {
@@ -61,6 +115,37 @@ func NewServer(opts ServerOptions) (*Server, error) {
 	if err := EnsureDir(submissionsDir); err != nil {
 		return nil, err
 	}
+	secret, err := loadShareSecret(opts.DB)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := parseTrustedProxies(opts.Config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	var scanner fileScanner
+	if opts.Config.Clamd != "" {
+		clamd, err := newClamdScanner(opts.Config.Clamd)
+		if err != nil {
+			return nil, err
+		}
+		scanner = clamd
+	}
+	quarantineDir := opts.QuarantineDir
+	if quarantineDir == "" {
+		quarantineDir = filepath.Join(filepath.Dir(opts.Dir), "quarantine")
+	}
+	previewDir := opts.PreviewDir
+	if previewDir == "" {
+		previewDir = filepath.Join(filepath.Dir(opts.Dir), "previews")
+	}
+	if pathWithin(quarantineDir, opts.Dir) {
+		return nil, fmt.Errorf("quarantine directory %s must be outside of the gallery directory", quarantineDir)
+	}
+	if pathWithin(previewDir, opts.Dir) {
+		return nil, fmt.Errorf("preview directory %s must be outside of the gallery directory", previewDir)
+	}
+	removeLegacyPreviews(submissionsDir)
 	return &Server{
 		dir:            opts.Dir,
 		submissionsDir: submissionsDir,
@@ -70,28 +155,100 @@ func NewServer(opts ServerOptions) (*Server, error) {
 		logger:         opts.Logger,
 		db:             opts.DB,
 		favicon:        opts.Favicon,
+		secret:         secret,
+		unlockLimiter:  newAttemptLimiter(shareUnlockMaxAttempts, shareUnlockWindow),
+		receiptLimiter: newAttemptLimiter(receiptMaxAttempts, receiptWindow),
+		voteLimiter:    newAttemptLimiter(voteMaxAttempts, voteWindow),
+		commentLimiter: newAttemptLimiter(commentMaxAttempts, commentWindow),
+		webhookWake:    make(chan struct{}, 1),
+		scanner:        scanner,
+		quarantineDir:  quarantineDir,
+		previewDir:     previewDir,
+		pdfRenderer:    newPDFRenderer(opts.Config.Pdftoppm),
+		pdfWork:        make(chan struct{}, pdfWorkers),
+		proxies:        proxies,
+		linkLimiter:    newAttemptLimiter(shareLinkMaxFailures, shareUnlockWindow),
 	}, nil
 }
 
 func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.Handle("/", s)
-	mux.Handle("/images/", http.StripPrefix("/images/", http.FileServer(http.Dir(s.dir))))
+	mux.Handle("/images/", s.galleryFiles())
 	mux.HandleFunc("/api/login", s.handleLogin)
 	mux.HandleFunc("/api/logout", s.handleLogout)
 	mux.HandleFunc("/api/upload", s.handleUpload)
 	mux.HandleFunc("/api/delete", s.handleDelete)
 	mux.HandleFunc("/api/images/rename", s.handleRenameImage)
+	mux.HandleFunc("/api/images/share", s.handleImageShareAPI)
+	mux.HandleFunc("/api/images/share/qr", s.handleImageShareQR)
+	mux.HandleFunc("/api/comments", s.handleComments)
+	mux.HandleFunc("/api/comments/", s.handleCommentByID)
 	mux.HandleFunc("/api/folders", s.handleFolders)
 	mux.HandleFunc("/api/folders/", s.handleFolderByID)
 	mux.HandleFunc("/api/submissions/upload", s.handleSubmissionUpload)
 	mux.HandleFunc("/api/submissions/groups", s.handleSubmissionGroups)
 	mux.HandleFunc("/api/submissions/groups/", s.handleSubmissionGroupByID)
+	mux.HandleFunc("/api/submissions/moderation", s.handleSubmissionModeration)
+	mux.HandleFunc("/api/submissions/delete", s.handleSubmissionBulkDelete)
+	mux.HandleFunc("/api/webhooks", s.handleWebhooks)
+	mux.HandleFunc("/api/webhooks/", s.handleWebhookByID)
+	mux.HandleFunc("/api/submissions/publish", s.handleSubmissionPublish)
+	mux.HandleFunc("/api/submissions/entries/", s.handleSubmissionEntry)
+	mux.HandleFunc("/api/submissions/invites/", s.handleSubmissionInvite)
+	mux.HandleFunc("/api/submissions/receipt", s.handleSubmissionReceipt)
+	mux.HandleFunc("/api/shared/unlock", s.handleShareUnlock)
+	mux.HandleFunc("/api/shared/events", s.handleShareEvent)
+	mux.HandleFunc("/api/share-links/", s.handleShareLinkByID)
 	mux.HandleFunc("/shared/", s.handleSharedFolder)
+	mux.HandleFunc("/i/", s.handleImageSharePage)
+	mux.HandleFunc("/embed/", s.handleEmbed)
+	mux.HandleFunc("/oembed", s.handleOEmbed)
+	mux.HandleFunc("/feed.atom", s.handleSiteFeed)
+	mux.HandleFunc("/feed.rss", s.handleSiteFeed)
+	mux.HandleFunc("/feeds/", s.handleFolderFeed)
 	mux.HandleFunc("/submitted/", s.handleSubmittedRoutes)
 	mux.HandleFunc("/submitted/file/", s.handleSubmissionFile)
 	mux.HandleFunc("/favicon.ico", s.handleFavicon)
 }
 
+// galleryFiles serves the gallery directory but refuses the submissions
+// directory and dot-files, so submissions are only readable through
+// /submitted/file/<id> with its moderation and access checks.
+func (s *Server) galleryFiles() http.Handler {
+	files := http.StripPrefix("/images/", http.FileServer(http.Dir(s.dir)))
+	submitted := filepath.Base(s.submissionsDir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rel := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/images/")), "/")
+		for i, part := range strings.Split(rel, "/") {
+			if strings.HasPrefix(part, ".") || (i == 0 && strings.EqualFold(part, submitted)) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		// Files of shared and private folders are only served through their
+		// share links or to the admin.
+		if dir, _, ok := strings.Cut(rel, "/"); ok {
+			folder, err := s.getFolderByPath(dir)
+			if err != nil || !s.canAccessFolder(folder, s.sessions.authenticated(w, r)) {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		sandboxSVG(w, rel)
+		files.ServeHTTP(w, r)
+	})
+}
+
+// sandboxSVG keeps an SVG from running script on this origin when it is
+// opened directly.
+func sandboxSVG(w http.ResponseWriter, name string) {
+	if strings.EqualFold(path.Ext(name), ".svg") {
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.Header().Set("Content-Disposition", "attachment")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+	}
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		w.Header().Set("Allow", http.MethodGet)
@@ -151,6 +308,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	var activeFolder *folderView
 	var images []imageInfo
+	var meta *pageMeta
+	feeds := feedLinks(baseURL, nil)
 
 	if folderSlug != "" {
 		rec, err := s.getFolderBySlug(folderSlug)
@@ -177,6 +336,9 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "failed to load images", http.StatusInternalServerError)
 			return
 		}
+		s.applyImageCommentCounts(rec, images, loggedIn)
+		meta = folderPageMeta(r, rec.Name, images)
+		feeds = feedLinks(baseURL, rec)
 	}
 
 	data := pageData{
@@ -188,6 +350,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		AllowFolderManagement: loggedIn,
 		View:                  "gallery",
 		SubmissionUploadLimit: int(submissionUploadMaxSize >> 20),
+		Meta:                  meta,
+		Feeds:                 feeds,
 	}
 
 	s.renderPage(w, data)
@@ -210,7 +374,26 @@ func (s *Server) renderSubmittedDashboard(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	folders, err := s.listFolders(true)
+	if err != nil {
+		log.Printf("list folders: %v", err)
+		http.Error(w, "failed to load folders", http.StatusInternalServerError)
+		return
+	}
+	publishFolders := make([]folderView, 0, len(folders))
+	for _, folder := range folders {
+		publishFolders = append(publishFolders, folder.toView(baseURL))
+	}
+
+	pending, err := s.pendingSubmissionCounts()
+	if err != nil {
+		log.Printf("pending submissions: %v", err)
+		http.Error(w, "failed to load groups", http.StatusInternalServerError)
+		return
+	}
+
 	groupSlug := strings.TrimSpace(r.URL.Query().Get("group"))
+	status := parseSubmissionStatus(r.URL.Query().Get("status"))
 	var activeRecord *submissionGroupRecord
 	groupViews := make([]submissionGroupView, 0, len(groups))
 	for i := range groups {
@@ -222,11 +405,19 @@ func (s *Server) renderSubmittedDashboard(w http.ResponseWriter, r *http.Request
 				activeRecord = &groups[i]
 			}
 		}
-		groupViews = append(groupViews, rec.toView(baseURL))
+		view := rec.toView(baseURL)
+		view.PendingCount = pending[rec.ID]
+		groupViews = append(groupViews, view)
 	}
 
 	var activeView *submissionGroupView
 	var entries []submissionEntryView
+	var filters []submissionStatusFilter
+	var changes []submissionChangeView
+	var hooks []webhookView
+	var deliveries []webhookDeliveryView
+	var invites []submissionInviteView
+	var invite *submissionInviteView
 	shareLink := ""
 	allowUpload := false
 
@@ -241,13 +432,43 @@ func (s *Server) renderSubmittedDashboard(w http.ResponseWriter, r *http.Request
 		view := activeRecord.toView(baseURL)
 		activeView = &view
 		shareLink = view.ShareURL
-		allowUpload = loggedIn || activeRecord.Visibility != visibilityPrivate
-		entries, err = s.submissionEntriesForGroup(activeRecord, viewerToken, loggedIn)
+		if rec := s.submissionInviteFor(activeRecord.ID, viewerToken); rec != nil {
+			inviteView := rec.toView(baseURL)
+			invite = &inviteView
+		}
+		allowUpload = loggedIn || (activeRecord.Visibility != visibilityPrivate && (!activeRecord.InviteOnly || invite != nil))
+		view.PendingCount = pending[activeRecord.ID]
+		entries, err = s.submissionEntriesForGroup(activeRecord, viewerToken, loggedIn, status)
 		if err != nil {
 			log.Printf("list submissions: %v", err)
 			http.Error(w, "failed to load submissions", http.StatusInternalServerError)
 			return
 		}
+		filters, err = s.submissionStatusFilters(activeRecord.ID)
+		if err != nil {
+			log.Printf("submission filters: %v", err)
+			http.Error(w, "failed to load submissions", http.StatusInternalServerError)
+			return
+		}
+		changes, err = s.submissionChanges(activeRecord.ID)
+		if err != nil {
+			log.Printf("submission changes: %v", err)
+			http.Error(w, "failed to load submissions", http.StatusInternalServerError)
+			return
+		}
+		if loggedIn {
+			if hooks, err = s.webhooksForGroup(activeRecord.ID); err == nil {
+				deliveries, err = s.webhookDeliveries(activeRecord.ID)
+			}
+			if err == nil {
+				invites, err = s.submissionInvites(activeRecord.ID, baseURL)
+			}
+			if err != nil {
+				log.Printf("webhooks: %v", err)
+				http.Error(w, "failed to load submissions", http.StatusInternalServerError)
+				return
+			}
+		}
 	}
 
 	data := pageData{
@@ -262,8 +483,27 @@ func (s *Server) renderSubmittedDashboard(w http.ResponseWriter, r *http.Request
 		AllowSubmissionUpload:     allowUpload,
 		SubmissionShareLink:       shareLink,
 		SubmissionUploadLimit:     int(submissionUploadMaxSize >> 20),
+		SubmissionStatus:          status,
+		SubmissionStatusFilters:   filters,
+		PublishFolders:            publishFolders,
+		SubmissionChanges:         changes,
+		SubmissionInvite:          invite,
 		AllowFolderManagement:     loggedIn,
 	}
+	if loggedIn {
+		data.SubmissionFieldTypes = submissionFieldTypes
+		data.Webhooks = hooks
+		data.WebhookDeliveries = deliveries
+		data.WebhookEvents = webhookEvents
+		data.SubmissionInvites = invites
+		for _, invite := range invites {
+			if invite.Files > 0 {
+				data.SubmissionInvitesDone++
+			}
+		}
+	}
+	s.applySubmissionVoting(w, &data, activeRecord, viewerToken, loggedIn)
+	s.applySubmissionComments(&data, activeRecord, loggedIn)
 
 	s.renderPage(w, data)
 }
@@ -303,7 +543,21 @@ func (s *Server) imagesForFolder(rec *folderRecord) ([]imageInfo, error) {
 	if err := EnsureDir(dir); err != nil {
 		return nil, err
 	}
-	return listImages(dir, urlPrefix)
+	images, err := listImages(dir, urlPrefix)
+	if err != nil || rec == nil || len(images) == 0 {
+		return images, err
+	}
+	attributions, err := s.folderImageAttributions(rec.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range images {
+		if attr, ok := attributions[images[i].Name]; ok {
+			images[i].Author = attr.Author
+			images[i].OriginalName = attr.OriginalName
+		}
+	}
+	return images, nil
 }
 
 func (s *Server) handleFavicon(w http.ResponseWriter, r *http.Request) {
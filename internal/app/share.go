@@ -0,0 +1,128 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var errShareLimitInvalid = errors.New("nieprawidlowe ograniczenia linku")
+
+type shareLimits struct {
+	ExpiresAt sql.NullTime
+	MaxViews  sql.NullInt64
+}
+
+type shareStatus struct {
+	ShareExpiresAt string `json:"sharedExpiresAt,omitempty"`
+	ShareMaxViews  int64  `json:"sharedMaxViews,omitempty"`
+	ShareExpired   bool   `json:"shareExpired"`
+	ShareRemaining string `json:"shareRemaining,omitempty"`
+}
+
+// expired reports whether a link with the given view count is no longer
+// usable. Callers checking a new visit pass the count including that visit.
+func (l shareLimits) expired(now time.Time, views int) bool {
+	if l.ExpiresAt.Valid && !now.Before(l.ExpiresAt.Time) {
+		return true
+	}
+	if l.MaxViews.Valid && l.MaxViews.Int64 > 0 && int64(views) > l.MaxViews.Int64 {
+		return true
+	}
+	return false
+}
+
+func (l shareLimits) status(now time.Time, views int) shareStatus {
+	status := shareStatus{
+		ShareExpired: l.expired(now, views+1),
+	}
+	if l.ExpiresAt.Valid {
+		status.ShareExpiresAt = l.ExpiresAt.Time.UTC().Format(time.RFC3339)
+	}
+	if l.MaxViews.Valid && l.MaxViews.Int64 > 0 {
+		status.ShareMaxViews = l.MaxViews.Int64
+	}
+
+	var parts []string
+	if l.ExpiresAt.Valid {
+		left := l.ExpiresAt.Time.Sub(now)
+		if left <= 0 {
+			parts = append(parts, "czas minal "+l.ExpiresAt.Time.Local().Format("02.01.2006 15:04"))
+		} else {
+			parts = append(parts, fmt.Sprintf("wygasa %s (za %s)", l.ExpiresAt.Time.Local().Format("02.01.2006 15:04"), durationLabel(left)))
+		}
+	}
+	if status.ShareMaxViews > 0 {
+		left := status.ShareMaxViews - int64(views)
+		if left < 0 {
+			left = 0
+		}
+		parts = append(parts, fmt.Sprintf("pozostalo wejsc: %d z %d", left, status.ShareMaxViews))
+	}
+	status.ShareRemaining = strings.Join(parts, ", ")
+	return status
+}
+
+func durationLabel(d time.Duration) string {
+	if d < time.Minute {
+		return "mniej niz minute"
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%d d %d h", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%d h %d min", hours, minutes)
+	default:
+		return fmt.Sprintf("%d min", minutes)
+	}
+}
+
+// applyShareLimitsRequest merges optional API fields into the current limits.
+// A nil field leaves the value unchanged, an empty date or zero clears it.
+func applyShareLimitsRequest(current shareLimits, expiresAt *string, maxViews *int64) (shareLimits, error) {
+	next := current
+	if expiresAt != nil {
+		raw := strings.TrimSpace(*expiresAt)
+		if raw == "" {
+			next.ExpiresAt = sql.NullTime{}
+		} else {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return current, errShareLimitInvalid
+			}
+			next.ExpiresAt = sql.NullTime{Time: parsed.UTC(), Valid: true}
+		}
+	}
+	if maxViews != nil {
+		switch {
+		case *maxViews < 0:
+			return current, errShareLimitInvalid
+		case *maxViews == 0:
+			next.MaxViews = sql.NullInt64{}
+		default:
+			next.MaxViews = sql.NullInt64{Int64: *maxViews, Valid: true}
+		}
+	}
+	return next, nil
+}
+
+func (s *Server) renderShareExpired(w http.ResponseWriter, r *http.Request, view string) {
+	if s.logger != nil {
+		s.logger.Log(r, "linkwygasl")
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusGone)
+	s.renderPage(w, pageData{
+		View:                 view,
+		BaseURL:              requestBaseURL(r),
+		SharedMode:           view == "gallery",
+		SubmissionSharedMode: view == "submitted",
+		ShareExpired:         true,
+	})
+}
@@ -0,0 +1,328 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	shareVisitDedupWindow    = 30 * time.Minute
+	shareAnalyticsDays       = 30
+	shareAnalyticsTopImages  = 10
+	shareAnalyticsRecent     = 20
+	shareVisitUserAgentLimit = 300
+	shareVisitReferrerLimit  = 500
+
+	shareEventOpen     = "open"
+	shareEventDownload = "download"
+
+	sqliteTimeLayout = "2006-01-02 15:04:05"
+)
+
+type shareVisit struct {
+	Kind        string
+	TargetID    int64
+	LinkID      sql.NullInt64
+	VisitorHash string
+	UserAgent   string
+	Referrer    string
+	Unique      bool
+}
+
+func (v *shareVisit) newViews() int {
+	if v.Unique {
+		return 1
+	}
+	return 0
+}
+
+// visitorHash identifies a visitor without keeping the raw IP address.
+func (s *Server) visitorHash(r *http.Request) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("visitor|" + clientIP(r)))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+func truncateRunes(value string, limit int) string {
+	value = strings.TrimSpace(value)
+	runes := []rune(value)
+	if len(runes) > limit {
+		return string(runes[:limit])
+	}
+	return value
+}
+
+func accessLinkID(access *shareAccess) sql.NullInt64 {
+	if access == nil || access.Link == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: access.Link.ID, Valid: true}
+}
+
+// newShareVisit prepares a visit entry. Repeated visits from the same visitor
+// and link within shareVisitDedupWindow are logged but not counted as unique.
+func (s *Server) newShareVisit(r *http.Request, kind string, targetID int64, access *shareAccess) (*shareVisit, error) {
+	visit := &shareVisit{
+		Kind:        kind,
+		TargetID:    targetID,
+		LinkID:      accessLinkID(access),
+		VisitorHash: s.visitorHash(r),
+		UserAgent:   truncateRunes(r.UserAgent(), shareVisitUserAgentLimit),
+		Referrer:    truncateRunes(r.Referer(), shareVisitReferrerLimit),
+	}
+
+	since := time.Now().UTC().Add(-shareVisitDedupWindow).Format(sqliteTimeLayout)
+	var found int
+	err := s.db.QueryRow(`SELECT 1 FROM share_visits
+		WHERE target_type = ? AND target_id = ? AND link_id IS ? AND visitor_hash = ? AND created_at >= ?
+		LIMIT 1`, kind, targetID, visit.LinkID, visit.VisitorHash, since).Scan(&found)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		visit.Unique = true
+	case err != nil:
+		return nil, err
+	}
+	return visit, nil
+}
+
+func (s *Server) recordShareVisit(visit *shareVisit, access *shareAccess) error {
+	_, err := s.db.Exec(`INSERT INTO share_visits (target_type, target_id, link_id, visitor_hash, user_agent, referrer, is_unique)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		visit.Kind, visit.TargetID, visit.LinkID, visit.VisitorHash, visit.UserAgent, visit.Referrer, visit.Unique)
+	if err != nil {
+		return err
+	}
+	if !visit.Unique {
+		return nil
+	}
+	return s.recordShareView(visit.Kind, visit.TargetID, access)
+}
+
+func (s *Server) deleteShareAnalytics(kind string, targetID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM share_visits WHERE target_type = ? AND target_id = ?`, kind, targetID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM share_image_events WHERE target_type = ? AND target_id = ?`, kind, targetID)
+	return err
+}
+
+func (s *Server) handleShareEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	var req struct {
+		Token  string `json:"token"`
+		Image  string `json:"image"`
+		Action string `json:"action"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<10)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	if req.Action != shareEventOpen && req.Action != shareEventDownload {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe zdarzenie")
+		return
+	}
+
+	folder, access, err := s.resolveFolderShare(strings.TrimSpace(req.Token))
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "Link nie istnieje")
+		return
+	}
+	if access.blocked(time.Now(), 0) || !s.folderShareUnlocked(r, folder) {
+		writeJSONError(w, http.StatusForbidden, "Link nie jest aktywny")
+		return
+	}
+	if !access.Permissions.View || (req.Action == shareEventDownload && !access.Permissions.Download) {
+		writeJSONError(w, http.StatusForbidden, "Brak uprawnien")
+		return
+	}
+
+	name := filepath.Base(strings.TrimSpace(req.Image))
+	if name != sanitizeFilename(name) || !isImageFile(name) {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy plik")
+		return
+	}
+	dir := s.dir
+	if folder.Path != "" {
+		dir = filepath.Join(s.dir, folder.Path)
+	}
+	if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.IsDir() {
+		writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+		return
+	}
+
+	_, err = s.db.Exec(`INSERT INTO share_image_events (target_type, target_id, link_id, visitor_hash, image, action)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		shareKindFolder, folder.ID, accessLinkID(access), s.visitorHash(r), name, req.Action)
+	if err != nil {
+		log.Printf("share event: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zdarzenia")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type shareAnalyticsDay struct {
+	Date   string `json:"date"`
+	Visits int    `json:"visits"`
+	Unique int    `json:"unique"`
+}
+
+type shareAnalyticsImage struct {
+	Name      string `json:"name"`
+	Opens     int    `json:"opens"`
+	Downloads int    `json:"downloads"`
+}
+
+type shareAnalyticsVisit struct {
+	Time      string `json:"time"`
+	Link      string `json:"link"`
+	UserAgent string `json:"userAgent"`
+	Referrer  string `json:"referrer"`
+	Unique    bool   `json:"unique"`
+}
+
+type shareAnalytics struct {
+	TotalVisits    int                   `json:"totalVisits"`
+	UniqueVisits   int                   `json:"uniqueVisits"`
+	UniqueVisitors int                   `json:"uniqueVisitors"`
+	Days           []shareAnalyticsDay   `json:"days"`
+	TopImages      []shareAnalyticsImage `json:"topImages"`
+	Recent         []shareAnalyticsVisit `json:"recent"`
+}
+
+func (s *Server) shareAnalyticsFor(kind string, targetID int64) (*shareAnalytics, error) {
+	result := &shareAnalytics{
+		Days:      []shareAnalyticsDay{},
+		TopImages: []shareAnalyticsImage{},
+		Recent:    []shareAnalyticsVisit{},
+	}
+
+	err := s.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(is_unique), 0), COUNT(DISTINCT visitor_hash)
+		FROM share_visits WHERE target_type = ? AND target_id = ?`, kind, targetID).
+		Scan(&result.TotalVisits, &result.UniqueVisits, &result.UniqueVisitors)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now()
+	start := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, today.Location()).AddDate(0, 0, -(shareAnalyticsDays - 1))
+	rows, err := s.db.Query(`SELECT date(created_at, 'localtime') AS day, COUNT(*), COALESCE(SUM(is_unique), 0)
+		FROM share_visits
+		WHERE target_type = ? AND target_id = ? AND created_at >= ?
+		GROUP BY day`, kind, targetID, start.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]shareAnalyticsDay)
+	for rows.Next() {
+		var day shareAnalyticsDay
+		if err := rows.Scan(&day.Date, &day.Visits, &day.Unique); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		counts[day.Date] = day
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := 0; i < shareAnalyticsDays; i++ {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		day := counts[date]
+		day.Date = date
+		result.Days = append(result.Days, day)
+	}
+
+	rows, err = s.db.Query(`SELECT image,
+			SUM(CASE WHEN action = ? THEN 1 ELSE 0 END) AS opens,
+			SUM(CASE WHEN action = ? THEN 1 ELSE 0 END) AS downloads
+		FROM share_image_events
+		WHERE target_type = ? AND target_id = ?
+		GROUP BY image
+		ORDER BY opens + downloads DESC, image
+		LIMIT ?`, shareEventOpen, shareEventDownload, kind, targetID, shareAnalyticsTopImages)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var image shareAnalyticsImage
+		if err := rows.Scan(&image.Name, &image.Opens, &image.Downloads); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		result.TopImages = append(result.TopImages, image)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.Query(`SELECT v.created_at, COALESCE(l.label, ''), v.user_agent, v.referrer, v.is_unique
+		FROM share_visits v
+		LEFT JOIN share_links l ON l.id = v.link_id
+		WHERE v.target_type = ? AND v.target_id = ?
+		ORDER BY v.created_at DESC, v.id DESC
+		LIMIT ?`, kind, targetID, shareAnalyticsRecent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			visit     shareAnalyticsVisit
+			createdAt time.Time
+		)
+		if err := rows.Scan(&createdAt, &visit.Link, &visit.UserAgent, &visit.Referrer, &visit.Unique); err != nil {
+			return nil, err
+		}
+		visit.Time = createdAt.Local().Format("02.01.2006 15:04")
+		if visit.Link == "" {
+			visit.Link = "glowny link"
+		}
+		result.Recent = append(result.Recent, visit)
+	}
+	return result, rows.Err()
+}
+
+func (s *Server) handleFolderAnalytics(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+	if _, err := s.getFolderByID(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Folder nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac folderu")
+		return
+	}
+
+	analytics, err := s.shareAnalyticsFor(shareKindFolder, id)
+	if err != nil {
+		log.Printf("share analytics: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac statystyk")
+		return
+	}
+	writeJSON(w, http.StatusOK, analytics)
+}
@@ -0,0 +1,456 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const shareLinkColumns = `id, target_type, target_id, label, token, views, expires_at, max_views, enabled, allow_view, allow_download, allow_upload, created_at`
+
+var (
+	errShareLinkLabel       = errors.New("nazwa linku jest wymagana")
+	errShareLinkPermissions = errors.New("link musi miec co najmniej jedno uprawnienie")
+)
+
+type sharePermissions struct {
+	View     bool
+	Download bool
+	Upload   bool
+}
+
+type shareLinkRecord struct {
+	ID          int64
+	TargetType  string
+	TargetID    int64
+	Label       string
+	Token       string
+	Views       int
+	Limits      shareLimits
+	Enabled     bool
+	Permissions sharePermissions
+	CreatedAt   time.Time
+}
+
+type shareLinkView struct {
+	ID            int64  `json:"id"`
+	Label         string `json:"label"`
+	Token         string `json:"token"`
+	URL           string `json:"url"`
+	Views         int    `json:"views"`
+	Enabled       bool   `json:"enabled"`
+	AllowView     bool   `json:"allowView"`
+	AllowDownload bool   `json:"allowDownload"`
+	AllowUpload   bool   `json:"allowUpload"`
+	CreatedAt     string `json:"createdAt"`
+	shareStatus
+}
+
+// shareAccess describes the link a visitor used to reach a shared folder or
+// submission group. Link is nil for the target's primary shared_token.
+type shareAccess struct {
+	Token       string
+	Link        *shareLinkRecord
+	Views       int
+	Limits      shareLimits
+	Permissions sharePermissions
+}
+
+func (a *shareAccess) blocked(now time.Time, newVisits int) bool {
+	if a.Link != nil && !a.Link.Enabled {
+		return true
+	}
+	return a.Limits.expired(now, a.Views+newVisits)
+}
+
+func shareLinkURL(baseURL, kind, token string) string {
+	base := strings.TrimSuffix(baseURL, "/")
+	if kind == shareKindGroup {
+		return base + "/submitted/shared/" + token
+	}
+	return base + "/shared/" + token
+}
+
+func (l shareLinkRecord) toView(baseURL string) shareLinkView {
+	return shareLinkView{
+		ID:            l.ID,
+		Label:         l.Label,
+		Token:         l.Token,
+		URL:           shareLinkURL(baseURL, l.TargetType, l.Token),
+		Views:         l.Views,
+		Enabled:       l.Enabled,
+		AllowView:     l.Permissions.View,
+		AllowDownload: l.Permissions.Download,
+		AllowUpload:   l.Permissions.Upload,
+		CreatedAt:     l.CreatedAt.Local().Format("02.01.2006 15:04"),
+		shareStatus:   l.Limits.status(time.Now(), l.Views),
+	}
+}
+
+func scanShareLink(row rowScanner) (*shareLinkRecord, error) {
+	var rec shareLinkRecord
+	err := row.Scan(&rec.ID, &rec.TargetType, &rec.TargetID, &rec.Label, &rec.Token, &rec.Views,
+		&rec.Limits.ExpiresAt, &rec.Limits.MaxViews, &rec.Enabled,
+		&rec.Permissions.View, &rec.Permissions.Download, &rec.Permissions.Upload, &rec.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *Server) listShareLinks(kind string, targetID int64) ([]shareLinkRecord, error) {
+	rows, err := s.db.Query(`SELECT `+shareLinkColumns+` FROM share_links WHERE target_type = ? AND target_id = ? ORDER BY created_at, id`, kind, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []shareLinkRecord
+	for rows.Next() {
+		rec, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *rec)
+	}
+	return links, rows.Err()
+}
+
+func (s *Server) getShareLinkByID(id int64) (*shareLinkRecord, error) {
+	return scanShareLink(s.db.QueryRow(`SELECT `+shareLinkColumns+` FROM share_links WHERE id = ?`, id))
+}
+
+func (s *Server) getShareLinkByToken(kind, token string) (*shareLinkRecord, error) {
+	return scanShareLink(s.db.QueryRow(`SELECT `+shareLinkColumns+` FROM share_links WHERE target_type = ? AND token = ?`, kind, token))
+}
+
+func (s *Server) createShareLink(kind string, targetID int64, rec shareLinkRecord) (*shareLinkRecord, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(`INSERT INTO share_links (target_type, target_id, label, token, expires_at, max_views, enabled, allow_view, allow_download, allow_upload)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		kind, targetID, rec.Label, token, rec.Limits.ExpiresAt, rec.Limits.MaxViews, rec.Enabled,
+		rec.Permissions.View, rec.Permissions.Download, rec.Permissions.Upload)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.getShareLinkByID(id)
+}
+
+func (s *Server) updateShareLink(rec *shareLinkRecord) (*shareLinkRecord, error) {
+	_, err := s.db.Exec(`UPDATE share_links SET label = ?, expires_at = ?, max_views = ?, enabled = ?, allow_view = ?, allow_download = ?, allow_upload = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		rec.Label, rec.Limits.ExpiresAt, rec.Limits.MaxViews, rec.Enabled,
+		rec.Permissions.View, rec.Permissions.Download, rec.Permissions.Upload, rec.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.getShareLinkByID(rec.ID)
+}
+
+func (s *Server) regenerateShareLinkToken(id int64) (*shareLinkRecord, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE share_links SET token = ?, views = 0, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, token, id); err != nil {
+		return nil, err
+	}
+	return s.getShareLinkByID(id)
+}
+
+func (s *Server) deleteShareLinks(kind string, targetID int64) error {
+	_, err := s.db.Exec(`DELETE FROM share_links WHERE target_type = ? AND target_id = ?`, kind, targetID)
+	return err
+}
+
+// resolveFolderShare finds the shared folder behind a /shared/<token> URL,
+// accepting both the folder's primary token and its named links.
+func (s *Server) resolveFolderShare(token string) (*folderRecord, *shareAccess, error) {
+	folder, err := s.getFolderByToken(token)
+	if err == nil {
+		if folder.Visibility != visibilityShared {
+			return nil, nil, sql.ErrNoRows
+		}
+		return folder, &shareAccess{
+			Token:       token,
+			Views:       folder.SharedViews,
+			Limits:      folder.ShareLimits,
+			Permissions: sharePermissions{View: true, Download: true},
+		}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, err
+	}
+
+	link, err := s.getShareLinkByToken(shareKindFolder, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	folder, err = s.getFolderByID(link.TargetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if folder.Visibility != visibilityShared {
+		return nil, nil, sql.ErrNoRows
+	}
+	return folder, &shareAccess{
+		Token:       token,
+		Link:        link,
+		Views:       link.Views,
+		Limits:      link.Limits,
+		Permissions: link.Permissions,
+	}, nil
+}
+
+func (s *Server) resolveSubmissionShare(token string) (*submissionGroupRecord, *shareAccess, error) {
+	group, err := s.getSubmissionGroupByToken(token)
+	if err == nil {
+		if group.Visibility != visibilityShared {
+			return nil, nil, sql.ErrNoRows
+		}
+		return group, &shareAccess{
+			Token:       token,
+			Views:       group.SharedViews,
+			Limits:      group.ShareLimits,
+			Permissions: sharePermissions{View: true, Download: true, Upload: true},
+		}, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, err
+	}
+
+	link, err := s.getShareLinkByToken(shareKindGroup, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	group, err = s.getSubmissionGroupByID(link.TargetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if group.Visibility != visibilityShared {
+		return nil, nil, sql.ErrNoRows
+	}
+	return group, &shareAccess{
+		Token:       token,
+		Link:        link,
+		Views:       link.Views,
+		Limits:      link.Limits,
+		Permissions: link.Permissions,
+	}, nil
+}
+
+func (s *Server) recordShareView(kind string, targetID int64, access *shareAccess) error {
+	if access.Link != nil {
+		_, err := s.db.Exec(`UPDATE share_links SET views = views + 1 WHERE id = ?`, access.Link.ID)
+		return err
+	}
+	switch kind {
+	case shareKindGroup:
+		return s.incrementSubmissionSharedViews(targetID)
+	case shareKindImage:
+		_, err := s.db.Exec(`UPDATE image_shares SET views = views + 1 WHERE id = ?`, targetID)
+		return err
+	default:
+		return s.incrementSharedViews(targetID)
+	}
+}
+
+type shareLinkRequest struct {
+	Label         *string `json:"label"`
+	Enabled       *bool   `json:"enabled"`
+	ExpiresAt     *string `json:"expiresAt"`
+	MaxViews      *int64  `json:"maxViews"`
+	AllowView     *bool   `json:"allowView"`
+	AllowDownload *bool   `json:"allowDownload"`
+	AllowUpload   *bool   `json:"allowUpload"`
+	Regenerate    bool    `json:"regenerate"`
+}
+
+func (req shareLinkRequest) apply(rec shareLinkRecord) (shareLinkRecord, error) {
+	if req.Label != nil {
+		rec.Label = strings.TrimSpace(*req.Label)
+	}
+	if rec.Label == "" {
+		return rec, errShareLinkLabel
+	}
+	if req.Enabled != nil {
+		rec.Enabled = *req.Enabled
+	}
+	if req.AllowView != nil {
+		rec.Permissions.View = *req.AllowView
+	}
+	if req.AllowDownload != nil {
+		rec.Permissions.Download = *req.AllowDownload
+	}
+	if req.AllowUpload != nil {
+		rec.Permissions.Upload = *req.AllowUpload
+	}
+	if !rec.Permissions.View && !rec.Permissions.Upload {
+		return rec, errShareLinkPermissions
+	}
+	limits, err := applyShareLimitsRequest(rec.Limits, req.ExpiresAt, req.MaxViews)
+	if err != nil {
+		return rec, err
+	}
+	rec.Limits = limits
+	return rec, nil
+}
+
+func (s *Server) handleTargetShareLinks(w http.ResponseWriter, r *http.Request, kind string, targetID int64) {
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	var err error
+	if kind == shareKindGroup {
+		_, err = s.getSubmissionGroupByID(targetID)
+	} else {
+		_, err = s.getFolderByID(targetID)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Nie znaleziono celu linku")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac linkow")
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	switch r.Method {
+	case http.MethodGet:
+		links, err := s.listShareLinks(kind, targetID)
+		if err != nil {
+			log.Printf("list share links: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac linkow")
+			return
+		}
+		views := make([]shareLinkView, 0, len(links))
+		for _, link := range links {
+			views = append(views, link.toView(baseURL))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"links": views})
+	case http.MethodPost:
+		var req shareLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+			return
+		}
+		defaults := shareLinkRecord{
+			Enabled:     true,
+			Permissions: sharePermissions{View: true, Download: true, Upload: kind == shareKindGroup},
+		}
+		rec, err := req.apply(defaults)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		link, err := s.createShareLink(kind, targetID, rec)
+		if err != nil {
+			log.Printf("create share link: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie utworzyc linku")
+			return
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "nowylink")
+		}
+		writeJSON(w, http.StatusCreated, link.toView(baseURL))
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+	}
+}
+
+func (s *Server) handleShareLinkByID(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/share-links/"), "/")
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy link")
+		return
+	}
+
+	link, err := s.getShareLinkByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Link nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac linku")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, link.toView(requestBaseURL(r)))
+	case http.MethodPatch:
+		var req shareLinkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+			return
+		}
+		rec, err := req.apply(*link)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		link, err = s.updateShareLink(&rec)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac linku")
+			return
+		}
+		if req.Regenerate {
+			link, err = s.regenerateShareLinkToken(link.ID)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie odswiezyc linku")
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, link.toView(requestBaseURL(r)))
+	case http.MethodDelete:
+		if _, err := s.db.Exec(`DELETE FROM share_links WHERE id = ?`, id); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac linku")
+			return
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "usunlink")
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	default:
+		w.Header().Set("Allow", "GET, PATCH, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+	}
+}
+
+// shareLinkForQR loads a named link selected with the ?link= parameter of a
+// QR endpoint and checks that it belongs to the target.
+func (s *Server) shareLinkForQR(w http.ResponseWriter, rawID, kind string, targetID int64) (*shareLinkRecord, bool) {
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy link")
+		return nil, false
+	}
+	link, err := s.getShareLinkByID(id)
+	if err != nil || link.TargetType != kind || link.TargetID != targetID {
+		writeJSONError(w, http.StatusNotFound, "Link nie istnieje")
+		return nil, false
+	}
+	return link, true
+}
@@ -0,0 +1,265 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	shareKindFolder = "folder"
+	shareKindGroup  = "group"
+
+	sharePasswordIterations = 120_000
+	sharePasswordMinLength  = 4
+	shareAccessTTL          = 12 * time.Hour
+	shareUnlockMaxAttempts  = 5
+	shareLinkMaxFailures    = 50
+	shareUnlockWindow       = 15 * time.Minute
+)
+
+var errSharePasswordTooShort = fmt.Errorf("haslo musi miec co najmniej %d znaki", sharePasswordMinLength)
+
+func hashSharePassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, sharePasswordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s",
+		sharePasswordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func verifySharePassword(encoded, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(expected))
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(key, expected) == 1
+}
+
+// sharePasswordUpdate turns the optional API field into the value stored in
+// the *_password_hash column. An empty password removes the protection.
+func sharePasswordUpdate(password string) (sql.NullString, error) {
+	password = strings.TrimSpace(password)
+	if password == "" {
+		return sql.NullString{}, nil
+	}
+	if len([]rune(password)) < sharePasswordMinLength {
+		return sql.NullString{}, errSharePasswordTooShort
+	}
+	hash, err := hashSharePassword(password)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: hash, Valid: true}, nil
+}
+
+func shareAccessCookieName(kind string, id int64) string {
+	return fmt.Sprintf("share_%s_%d", kind, id)
+}
+
+// shareAccessSignature binds the cookie to the current link token and
+// password hash, so regenerating either one revokes existing access.
+func (s *Server) shareAccessSignature(kind string, id int64, token, passwordHash string, expires int64) string {
+	mac := hmac.New(sha256.New, s.secret)
+	fmt.Fprintf(mac, "%s|%d|%s|%s|%d", kind, id, token, passwordHash, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) grantShareAccess(w http.ResponseWriter, kind string, id int64, token, passwordHash string) {
+	expires := time.Now().Add(shareAccessTTL)
+	signature := s.shareAccessSignature(kind, id, token, passwordHash, expires.Unix())
+	http.SetCookie(w, &http.Cookie{
+		Name:     shareAccessCookieName(kind, id),
+		Value:    strconv.FormatInt(expires.Unix(), 10) + "." + signature,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  expires,
+		MaxAge:   int(shareAccessTTL.Seconds()),
+	})
+}
+
+func (s *Server) hasShareAccess(r *http.Request, kind string, id int64, token, passwordHash string) bool {
+	cookie, err := r.Cookie(shareAccessCookieName(kind, id))
+	if err != nil {
+		return false
+	}
+	rawExpires, signature, ok := strings.Cut(cookie.Value, ".")
+	if !ok {
+		return false
+	}
+	expires, err := strconv.ParseInt(rawExpires, 10, 64)
+	if err != nil || time.Now().Unix() >= expires {
+		return false
+	}
+	expected := s.shareAccessSignature(kind, id, token, passwordHash, expires)
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+func (s *Server) folderShareUnlocked(r *http.Request, folder *folderRecord) bool {
+	if !folder.SharedPasswordHash.Valid {
+		return true
+	}
+	return s.hasShareAccess(r, shareKindFolder, folder.ID, folder.SharedToken.String, folder.SharedPasswordHash.String)
+}
+
+func (s *Server) submissionShareUnlocked(r *http.Request, group *submissionGroupRecord) bool {
+	if !group.SharedPasswordHash.Valid {
+		return true
+	}
+	return s.hasShareAccess(r, shareKindGroup, group.ID, group.SharedToken.String, group.SharedPasswordHash.String)
+}
+
+func (s *Server) renderSharePasswordPrompt(w http.ResponseWriter, r *http.Request, view, kind, token string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusUnauthorized)
+	s.renderPage(w, pageData{
+		View:                  view,
+		BaseURL:               requestBaseURL(r),
+		SharedMode:            view == "gallery",
+		SubmissionSharedMode:  view == "submitted",
+		SharePasswordRequired: true,
+		ShareUnlockKind:       kind,
+		ShareUnlockToken:      token,
+	})
+}
+
+func (s *Server) handleShareUnlock(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	var req struct {
+		Kind     string `json:"kind"`
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+
+	var (
+		id           int64
+		token        string
+		passwordHash sql.NullString
+		access       *shareAccess
+	)
+	switch req.Kind {
+	case shareKindFolder:
+		folder, folderAccess, err := s.resolveFolderShare(strings.TrimSpace(req.Token))
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Link nie istnieje")
+			return
+		}
+		id, token, passwordHash, access = folder.ID, folder.SharedToken.String, folder.SharedPasswordHash, folderAccess
+	case shareKindGroup:
+		group, groupAccess, err := s.resolveSubmissionShare(strings.TrimSpace(req.Token))
+		if err != nil {
+			writeJSONError(w, http.StatusNotFound, "Link nie istnieje")
+			return
+		}
+		id, token, passwordHash, access = group.ID, group.SharedToken.String, group.SharedPasswordHash, groupAccess
+	default:
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+
+	// Expired links are refused before the password is looked at, so they
+	// cannot be used to guess it.
+	if access.blocked(time.Now(), 0) {
+		writeJSONError(w, http.StatusGone, "Ten link wygasl")
+		return
+	}
+
+	if !passwordHash.Valid {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+
+	limiterKey := fmt.Sprintf("%s|%s|%d", clientIP(r), req.Kind, id)
+	linkKey := fmt.Sprintf("%s|%d", req.Kind, id)
+	blocked, wait := s.unlockLimiter.blocked(limiterKey)
+	if linkBlocked, linkWait := s.linkLimiter.blocked(linkKey); linkBlocked && linkWait > wait {
+		blocked, wait = true, linkWait
+	}
+	if blocked {
+		minutes := int(wait.Minutes()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Zbyt wiele nieudanych prob. Sprobuj ponownie za %d min.", minutes))
+		return
+	}
+
+	if !verifySharePassword(passwordHash.String, req.Password) {
+		s.unlockLimiter.fail(limiterKey)
+		s.linkLimiter.fail(linkKey)
+		if s.logger != nil {
+			s.logger.Log(r, "zlehaslo")
+		}
+		writeJSONError(w, http.StatusUnauthorized, "Nieprawidlowe haslo")
+		return
+	}
+
+	s.unlockLimiter.reset(limiterKey)
+	s.grantShareAccess(w, req.Kind, id, token, passwordHash.String)
+	if s.logger != nil {
+		s.logger.Log(r, "odblokuj")
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func loadShareSecret(db *sql.DB) ([]byte, error) {
+	value, err := loadOrCreateSetting(db, "share_secret", func() (string, error) {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		return hex.EncodeToString(buf), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	secret, err := hex.DecodeString(value)
+	if err != nil || len(secret) == 0 {
+		return nil, errors.New("share secret: invalid value in settings table")
+	}
+	return secret, nil
+}
@@ -1,8 +1,11 @@
 package app
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -57,6 +60,39 @@ func isImageFile(name string) bool {
 	}
 }
 
+// rasterImageTypes are the formats visitors may upload to a gallery folder.
+// SVG is left out because it can carry script.
+var rasterImageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+	".avif": "image/avif",
+}
+
+// isRasterImage checks that the content of the file matches its raster
+// image extension and rewinds it.
+func isRasterImage(file io.ReadSeeker, filename string) bool {
+	want, ok := rasterImageTypes[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return false
+	}
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return false
+	}
+	head = head[:n]
+	// http.DetectContentType does not know AVIF; look for its ftyp brand.
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" &&
+		(bytes.Equal(head[8:12], []byte("avif")) || bytes.Equal(head[8:12], []byte("avis"))) {
+		return want == "image/avif"
+	}
+	return http.DetectContentType(head) == want
+}
+
 func isSubmissionFile(name string) bool {
 	if isImageFile(name) {
 		return true
@@ -87,6 +123,23 @@ func uniqueFilename(dir, name string) (string, error) {
 	}
 }
 
+// pathWithin reports whether path is root itself or lies below it.
+func pathWithin(path, root string) bool {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absRoot, absPath)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
 func sanitizeFilename(name string) string {
 	name = filepath.Base(name)
 	name = strings.TrimSpace(name)
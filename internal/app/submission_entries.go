@@ -0,0 +1,481 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	submissionChangeRename   = "rename"
+	submissionChangeReplace  = "replace"
+	submissionChangeWithdraw = "withdraw"
+	submissionChangeRetitle  = "retitle"
+	submissionChangeDelete   = "delete"
+
+	submissionActorContributor = "contributor"
+	submissionActorAdmin       = "admin"
+
+	submissionChangesLimit = 50
+)
+
+var submissionChangeLabels = map[string]string{
+	submissionChangeRename:   "Zmiana imienia",
+	submissionChangeReplace:  "Zastapienie pliku",
+	submissionChangeWithdraw: "Wycofanie zgloszenia",
+	submissionChangeRetitle:  "Zmiana nazwy pliku",
+	submissionChangeDelete:   "Usuniecie zgloszenia",
+}
+
+type submissionChangeView struct {
+	ID           int64  `json:"id"`
+	SubmissionID int64  `json:"submissionId"`
+	Action       string `json:"action"`
+	ActionLabel  string `json:"actionLabel"`
+	Detail       string `json:"detail"`
+	UploaderName string `json:"uploaderName"`
+	Actor        string `json:"actor"`
+	CreatedAt    string `json:"createdAt"`
+}
+
+func (s *Server) recordSubmissionChange(entry *submissionEntryRecord, actor, action, detail string) {
+	if _, err := s.db.Exec(`INSERT INTO submission_changes (group_id, submission_id, action, detail, uploader_name, actor) VALUES (?, ?, ?, ?, ?, ?)`,
+		entry.GroupID, entry.ID, action, detail, entry.UploaderName, actor); err != nil {
+		log.Printf("record submission change: %v", err)
+	}
+}
+
+func (s *Server) submissionChanges(groupID int64) ([]submissionChangeView, error) {
+	rows, err := s.db.Query(`SELECT id, submission_id, action, detail, uploader_name, actor, created_at FROM submission_changes
+		WHERE group_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`, groupID, submissionChangesLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []submissionChangeView{}
+	for rows.Next() {
+		var change submissionChangeView
+		var createdAt time.Time
+		if err := rows.Scan(&change.ID, &change.SubmissionID, &change.Action, &change.Detail, &change.UploaderName, &change.Actor, &createdAt); err != nil {
+			return nil, err
+		}
+		change.ActionLabel = submissionChangeLabels[change.Action]
+		change.CreatedAt = createdAt.Local().Format("02.01.2006 15:04")
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// contributorEditError returns why the visitor may not change the entry, or
+// an empty string when the entry is theirs and the group is still open.
+func (s *Server) contributorEditError(r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) (int, string) {
+	token := submissionViewerTokenFromRequest(r)
+	if token == "" || token != entry.ContributorToken {
+		return http.StatusNotFound, "Plik nie istnieje"
+	}
+	invited := s.submissionInviteFor(group.ID, token) != nil
+	if group.Visibility == visibilityPrivate && !invited {
+		return http.StatusNotFound, "Plik nie istnieje"
+	}
+	if group.Visibility == visibilityShared && !invited && !s.submissionShareUnlocked(r, group) {
+		return http.StatusUnauthorized, "Link wymaga hasla"
+	}
+	if message := group.Window.uploadError(time.Now()); message != "" {
+		return http.StatusForbidden, message
+	}
+	if entry.PublishedImage.Valid {
+		return http.StatusConflict, "Plik zostal juz opublikowany w galerii"
+	}
+	return 0, ""
+}
+
+// handleSubmissionEntry lets contributors rename, replace or withdraw their
+// own entries: PATCH and DELETE /api/submissions/entries/<id> and
+// POST /api/submissions/entries/<id>/replace. Logged in admins may rename or
+// delete any entry.
+func (s *Server) handleSubmissionEntry(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/submissions/entries/"), "/"), "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 2 && (parts[1] == "vote" || parts[1] == "score") {
+		s.handleSubmissionJudging(w, r, id, parts[1])
+		return
+	}
+	if len(parts) == 2 && parts[1] != "replace" {
+		http.NotFound(w, r)
+		return
+	}
+
+	replace := len(parts) == 2
+	allowed := "PATCH, DELETE"
+	if replace {
+		allowed = http.MethodPost
+	}
+	if (replace && r.Method != http.MethodPost) || (!replace && r.Method != http.MethodPatch && r.Method != http.MethodDelete) {
+		w.Header().Set("Allow", allowed)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	entry, group, err := s.getSubmissionEntry(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+			return
+		}
+		log.Printf("submission entry: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac pliku")
+		return
+	}
+	if !replace && s.sessions.authenticated(w, r) {
+		if r.Method == http.MethodPatch {
+			s.updateSubmissionEntry(w, r, entry)
+			return
+		}
+		if err := s.deleteSubmissionEntry(entry, group); err != nil {
+			log.Printf("delete submission: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac pliku")
+			return
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "usunzgl")
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	if status, message := s.contributorEditError(r, entry, group); message != "" {
+		writeJSONError(w, status, message)
+		return
+	}
+
+	switch {
+	case replace:
+		s.replaceSubmissionEntry(w, r, entry, group)
+	case r.Method == http.MethodPatch:
+		if s.submissionInviteFor(group.ID, entry.ContributorToken) != nil {
+			writeJSONError(w, http.StatusForbidden, "Imie z zaproszenia moze zmienic tylko organizator")
+			return
+		}
+		s.renameSubmissionEntry(w, r, entry)
+	default:
+		s.withdrawSubmissionEntry(w, r, entry, group)
+	}
+}
+
+func (s *Server) renameSubmissionEntry(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 200 {
+		writeJSONError(w, http.StatusBadRequest, "Podaj swoje imie")
+		return
+	}
+	if name == entry.UploaderName {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "name": name})
+		return
+	}
+
+	if _, err := s.db.Exec(`UPDATE submissions SET uploader_name = ? WHERE id = ?`, name, entry.ID); err != nil {
+		log.Printf("rename submission: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zmian")
+		return
+	}
+	s.recordSubmissionChange(entry, submissionActorContributor, submissionChangeRename, entry.UploaderName+" -> "+name)
+
+	if s.logger != nil {
+		s.logger.Log(r, "edytuj")
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "name": name})
+}
+
+func (s *Server) withdrawSubmissionEntry(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) {
+	path, pathErr := s.submissionFilePath(entry, group)
+	if _, err := s.db.Exec(`DELETE FROM submissions WHERE id = ?`, entry.ID); err != nil {
+		log.Printf("withdraw submission: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie wycofac zgloszenia")
+		return
+	}
+	if pathErr == nil {
+		if err := os.Remove(path); err != nil {
+			log.Printf("remove withdrawn submission: %v", err)
+		}
+	}
+	s.removeSubmissionPreviews(entry.ID)
+	s.deleteSubmissionVotes(entry.ID)
+	s.deleteSubmissionComments(entry.ID)
+	s.recordSubmissionChange(entry, submissionActorContributor, submissionChangeWithdraw, entry.OriginalName)
+
+	if s.logger != nil {
+		s.logger.Log(r, "wycofaj")
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// updateSubmissionEntry lets an admin change the uploader name or the file
+// name shown for an entry. The stored file keeps its name.
+func (s *Server) updateSubmissionEntry(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord) {
+	var req struct {
+		Name         *string `json:"name"`
+		OriginalName *string `json:"originalName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+
+	name := entry.UploaderName
+	if req.Name != nil {
+		name = strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 200 {
+			writeJSONError(w, http.StatusBadRequest, "Podaj imie autora")
+			return
+		}
+	}
+	original := entry.OriginalName
+	if req.OriginalName != nil {
+		original = submissionDisplayName(*req.OriginalName, entry.OriginalName)
+		if original == "" {
+			writeJSONError(w, http.StatusBadRequest, "Podaj nazwe pliku")
+			return
+		}
+	}
+
+	if _, err := s.db.Exec(`UPDATE submissions SET uploader_name = ?, original_name = ? WHERE id = ?`, name, original, entry.ID); err != nil {
+		log.Printf("update submission: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zmian")
+		return
+	}
+	if name != entry.UploaderName {
+		s.recordSubmissionChange(entry, submissionActorAdmin, submissionChangeRename, entry.UploaderName+" -> "+name)
+	}
+	if original != entry.OriginalName {
+		s.recordSubmissionChange(entry, submissionActorAdmin, submissionChangeRetitle, entry.OriginalName+" -> "+original)
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "edytuj")
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "name": name, "originalName": original})
+}
+
+// submissionDisplayName cleans a file name typed by an admin. Characters that
+// would break the Content-Disposition header are dropped and the extension of
+// the previous name is kept.
+func submissionDisplayName(raw, previous string) string {
+	name := strings.Map(func(r rune) rune {
+		if r < 32 || r == '"' || r == '/' || r == '\\' {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 200 {
+		return ""
+	}
+	if ext := filepath.Ext(previous); ext != "" && !strings.EqualFold(filepath.Ext(name), ext) {
+		name += ext
+	}
+	return name
+}
+
+// deleteSubmissionEntry removes an entry and its file from the group
+// directory. Images already moved into the gallery stay there.
+func (s *Server) deleteSubmissionEntry(entry *submissionEntryRecord, group *submissionGroupRecord) error {
+	if _, err := s.db.Exec(`DELETE FROM submissions WHERE id = ?`, entry.ID); err != nil {
+		return err
+	}
+	if entry.ScanStatus.String == scanStatusInfected {
+		s.removeQuarantined(entry.FileName)
+	} else if path, err := s.submissionStoredPath(entry, group); err == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("remove deleted submission: %v", err)
+		}
+	}
+	s.removeSubmissionPreviews(entry.ID)
+	s.deleteSubmissionVotes(entry.ID)
+	s.deleteSubmissionComments(entry.ID)
+	s.recordSubmissionChange(entry, submissionActorAdmin, submissionChangeDelete, entry.OriginalName)
+	return nil
+}
+
+// handleSubmissionBulkDelete removes the selected entries:
+// POST /api/submissions/delete with {"ids": [...]}.
+func (s *Server) handleSubmissionBulkDelete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Nie wybrano zadnych plikow")
+		return
+	}
+	if len(req.IDs) > moderationMaxBatch {
+		writeJSONError(w, http.StatusBadRequest, "Za duzo plikow naraz")
+		return
+	}
+
+	deleted := 0
+	for _, id := range req.IDs {
+		entry, group, err := s.getSubmissionEntry(id)
+		if err != nil {
+			if !errors.Is(err, sql.ErrNoRows) {
+				log.Printf("submission entry %d: %v", id, err)
+			}
+			continue
+		}
+		if err := s.deleteSubmissionEntry(entry, group); err != nil {
+			log.Printf("delete submission %d: %v", id, err)
+			continue
+		}
+		deleted++
+	}
+
+	if s.logger != nil && deleted > 0 {
+		s.logger.Log(r, "usunzgl")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "deleted": deleted})
+}
+
+func (s *Server) replaceSubmissionEntry(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) {
+	r.Body = http.MaxBytesReader(w, r.Body, group.Rules.maxFileSize())
+	if err := r.ParseMultipartForm(submissionUploadMaxSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "Plik jest za duzy")
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, "Nie udalo sie odczytac pliku")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Brak pliku w formularzu")
+		return
+	}
+	defer file.Close()
+
+	filename, message := submissionUploadName(header)
+	if message == "" {
+		message, err = s.checkSubmissionRules(group, entry.ContributorToken, filename, header.Size, file, entry.ID)
+		if err != nil {
+			log.Printf("submission rules: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic pliku")
+			return
+		}
+	}
+	if message != "" {
+		writeJSONError(w, http.StatusBadRequest, message)
+		return
+	}
+
+	scanStatus, signature := s.scanUpload(file)
+	if scanStatus == scanStatusInfected {
+		if name, err := s.quarantineUpload(file, filename); err != nil {
+			log.Printf("quarantine replacement: %v", err)
+		} else {
+			log.Printf("replacement for submission %d quarantined as %s (%s)", entry.ID, name, signature)
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "wirus")
+		}
+		writeJSONError(w, http.StatusUnprocessableEntity, "Plik zostal zablokowany przez skaner antywirusowy")
+		return
+	}
+
+	oldPath, oldPathErr := s.submissionFilePath(entry, group)
+	stored, written, err := s.storeSubmissionFile(group, filename, file)
+	if err != nil {
+		log.Printf("store submission: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac pliku")
+		return
+	}
+
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+	if _, err := s.db.Exec(`UPDATE submissions SET filename = ?, original_name = ?, mime_type = ?, size_bytes = ?, status = ?,
+		review_note = NULL, reviewed_at = NULL, scan_status = ?, scan_signature = NULL WHERE id = ?`,
+		stored, header.Filename, mimeType, written, submissionStatusPending, sql.NullString{String: scanStatus, Valid: scanStatus != ""}, entry.ID); err != nil {
+		log.Printf("replace submission: %v", err)
+		os.Remove(filepath.Join(s.submissionGroupDir(group), stored))
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac pliku")
+		return
+	}
+	if oldPathErr == nil {
+		if err := os.Remove(oldPath); err != nil {
+			log.Printf("remove replaced submission: %v", err)
+		}
+	}
+	s.removeSubmissionPreviews(entry.ID)
+	s.deleteSubmissionVotes(entry.ID)
+	if _, err := s.db.Exec(`UPDATE submissions SET pdf_pages = NULL, pdf_title = NULL, pdf_author = NULL WHERE id = ?`, entry.ID); err != nil {
+		log.Printf("clear pdf metadata: %v", err)
+	}
+	if updated, _, err := s.getSubmissionEntry(entry.ID); err == nil && updated.isPDF() {
+		s.queuePDFMetadata(updated, group)
+	}
+	s.recordSubmissionChange(entry, submissionActorContributor, submissionChangeReplace, entry.OriginalName+" -> "+header.Filename)
+
+	if s.logger != nil {
+		s.logger.Log(r, "zastap")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":     "ok",
+		"id":         entry.ID,
+		"moderation": submissionStatusPending,
+	})
+}
+
+func (s *Server) handleSubmissionGroupChanges(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if _, err := s.getSubmissionGroupByID(id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Grupa nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac grupy")
+		return
+	}
+	changes, err := s.submissionChanges(id)
+	if err != nil {
+		log.Printf("submission changes: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac historii zmian")
+		return
+	}
+	writeJSON(w, http.StatusOK, changes)
+}
@@ -0,0 +1,279 @@
+package app
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// archiveTransliterate keeps Polish names readable in archive entry names.
+// The remaining letters are covered by pdfTransliterate.
+var archiveTransliterate = strings.NewReplacer("ó", "o", "Ó", "O")
+
+type submissionExportEntry struct {
+	ID           int64             `json:"id"`
+	UploaderName string            `json:"uploaderName"`
+	OriginalName string            `json:"originalName"`
+	SizeBytes    int64             `json:"sizeBytes"`
+	MimeType     string            `json:"mimeType"`
+	UploadedAt   string            `json:"uploadedAt"`
+	Status       string            `json:"status"`
+	ReviewNote   string            `json:"reviewNote,omitempty"`
+	Fields       map[string]string `json:"fields"`
+}
+
+func exportSubmissionEntry(group *submissionGroupRecord, rec *submissionEntryRecord) submissionExportEntry {
+	entry := submissionExportEntry{
+		ID:           rec.ID,
+		UploaderName: rec.UploaderName,
+		OriginalName: rec.OriginalName,
+		SizeBytes:    rec.SizeBytes,
+		MimeType:     rec.MimeType.String,
+		UploadedAt:   rec.CreatedAt.UTC().Format(time.RFC3339),
+		Status:       rec.Status,
+		ReviewNote:   rec.ReviewNote.String,
+		Fields:       map[string]string{},
+	}
+	for _, value := range submissionFieldValues(group.Fields, rec.FieldValues) {
+		entry.Fields[value.Key] = value.Value
+	}
+	return entry
+}
+
+// submissionExportRecords returns the entries of a group, optionally limited
+// to one status or to the given ids.
+func (s *Server) submissionExportRecords(group *submissionGroupRecord, status string, ids []int64) ([]*submissionEntryRecord, error) {
+	query := `SELECT ` + submissionEntryColumns + ` FROM submissions WHERE group_id = ?`
+	args := []any{group.ID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	if len(ids) > 0 {
+		query += ` AND id IN (?` + strings.Repeat(`, ?`, len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	query += ` ORDER BY created_at ASC, id ASC`
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []*submissionEntryRecord
+	for rows.Next() {
+		rec, err := scanSubmissionEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
+
+// submissionExportFilter reads the status and ids query parameters shared by
+// the export and archive endpoints.
+func submissionExportFilter(r *http.Request) (string, []int64, string) {
+	query := r.URL.Query()
+	status := parseSubmissionStatus(query.Get("status"))
+
+	var ids []int64
+	for _, raw := range strings.Split(query.Get("ids"), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return "", nil, "Nieprawidlowe ID pliku"
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) > moderationMaxBatch {
+		return "", nil, "Za duzo plikow naraz"
+	}
+	return status, ids, ""
+}
+
+func (s *Server) submissionExportGroup(w http.ResponseWriter, r *http.Request, id int64) (*submissionGroupRecord, []*submissionEntryRecord, bool) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return nil, nil, false
+	}
+	group, err := s.getSubmissionGroupByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Grupa nie istnieje")
+			return nil, nil, false
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac grupy")
+		return nil, nil, false
+	}
+	status, ids, message := submissionExportFilter(r)
+	if message != "" {
+		writeJSONError(w, http.StatusBadRequest, message)
+		return nil, nil, false
+	}
+	records, err := s.submissionExportRecords(group, status, ids)
+	if err != nil {
+		log.Printf("submission export: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac plikow")
+		return nil, nil, false
+	}
+	return group, records, true
+}
+
+// handleSubmissionGroupExport serves the entries of a group as CSV or JSON:
+// GET /api/submissions/groups/<id>/export?format=csv|json&status=...
+func (s *Server) handleSubmissionGroupExport(w http.ResponseWriter, r *http.Request, id int64) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy format eksportu")
+		return
+	}
+
+	group, records, ok := s.submissionExportGroup(w, r, id)
+	if !ok {
+		return
+	}
+
+	entries := make([]submissionExportEntry, 0, len(records))
+	for _, rec := range records {
+		entries = append(entries, exportSubmissionEntry(group, rec))
+	}
+
+	filename := "zgloszenia-" + group.Slug + "." + format
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+	if format == "json" {
+		writeJSON(w, http.StatusOK, entries)
+		return
+	}
+
+	labels := make([]string, 0, len(group.Fields))
+	for _, field := range group.Fields {
+		labels = append(labels, field.Label)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	// The byte order mark makes spreadsheet programs read the file as UTF-8.
+	io.WriteString(w, "\ufeff")
+	out := csv.NewWriter(w)
+	header := []string{"id", "autor", "nazwa pliku", "rozmiar (B)", "typ MIME", "przeslano", "status", "notatka"}
+	out.Write(csvRecord(append(header, labels...)))
+	for _, entry := range entries {
+		row := []string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.UploaderName,
+			entry.OriginalName,
+			strconv.FormatInt(entry.SizeBytes, 10),
+			entry.MimeType,
+			entry.UploadedAt,
+			entry.Status,
+			entry.ReviewNote,
+		}
+		for _, field := range group.Fields {
+			row = append(row, entry.Fields[field.Key])
+		}
+		out.Write(csvRecord(row))
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("submission export: %v", err)
+	}
+}
+
+// csvRecord neutralises cells that spreadsheet programs would run as
+// formulas by prefixing them with an apostrophe.
+func csvRecord(row []string) []string {
+	for i, cell := range row {
+		if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+			row[i] = "'" + cell
+		}
+	}
+	return row
+}
+
+// handleSubmissionGroupArchive streams the files of a group as a ZIP archive:
+// GET /api/submissions/groups/<id>/archive?status=...&ids=1,2
+// Each file is prefixed with the name of its uploader.
+func (s *Server) handleSubmissionGroupArchive(w http.ResponseWriter, r *http.Request, id int64) {
+	group, records, ok := s.submissionExportGroup(w, r, id)
+	if !ok {
+		return
+	}
+	if len(records) == 0 {
+		writeJSONError(w, http.StatusNotFound, "Brak plikow do pobrania")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"zgloszenia-"+group.Slug+".zip\"")
+
+	archive := zip.NewWriter(w)
+	used := map[string]bool{}
+	for _, rec := range records {
+		path, err := s.submissionFilePath(rec, group)
+		if err != nil {
+			log.Printf("submission archive %d: %v", rec.ID, err)
+			continue
+		}
+
+		author := sanitizeFilename(archiveTransliterate.Replace(pdfTransliterate.Replace(rec.UploaderName)))
+		if author == "" {
+			author = "anonim"
+		}
+		name := author + "_" + rec.FileName
+		if used[name] {
+			name = author + "_" + strconv.FormatInt(rec.ID, 10) + "_" + rec.FileName
+		}
+		used[name] = true
+
+		if err := addFileToZip(archive, path, name, rec.CreatedAt); err != nil {
+			log.Printf("submission archive %d: %v", rec.ID, err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("submission archive: %v", err)
+		return
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "archiwum")
+	}
+}
+
+func addFileToZip(archive *zip.Writer, path, name string, modified time.Time) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified}
+	if isImageFile(name) {
+		// Compressed image formats gain nothing from deflate.
+		header.Method = zip.Store
+	}
+	dst, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, file)
+	return err
+}
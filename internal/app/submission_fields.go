@@ -0,0 +1,246 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	fieldTypeText     = "text"
+	fieldTypeEmail    = "email"
+	fieldTypeNumber   = "number"
+	fieldTypeSelect   = "select"
+	fieldTypeCheckbox = "checkbox"
+
+	submissionFieldsMax     = 20
+	submissionFieldLabelMax = 100
+	submissionFieldValueMax = 500
+	submissionFieldOptions  = 50
+	submissionFieldPrefix   = "field_"
+)
+
+type submissionFieldType struct {
+	Key   string
+	Label string
+}
+
+var submissionFieldTypes = []submissionFieldType{
+	{fieldTypeText, "Tekst"},
+	{fieldTypeEmail, "E-mail"},
+	{fieldTypeNumber, "Liczba"},
+	{fieldTypeSelect, "Lista wyboru"},
+	{fieldTypeCheckbox, "Zgoda (pole wyboru)"},
+}
+
+// submissionField is an extra question asked in the upload form. Fields are
+// stored as JSON on the group and answers as JSON on each submission.
+type submissionField struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"`
+}
+
+type submissionFieldValue struct {
+	Key   string
+	Label string
+	Value string
+}
+
+func (f submissionField) InputName() string {
+	return submissionFieldPrefix + f.Key
+}
+
+func (f submissionField) OptionsText() string {
+	return strings.Join(f.Options, ", ")
+}
+
+func parseSubmissionFields(raw sql.NullString) []submissionField {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	var fields []submissionField
+	if err := json.Unmarshal([]byte(raw.String), &fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+func validFieldType(kind string) bool {
+	for _, t := range submissionFieldTypes {
+		if t.Key == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeSubmissionFields validates field definitions sent by the admin.
+// Existing keys are kept so answers stay linked when a label changes; new
+// fields get a key derived from the label.
+func normalizeSubmissionFields(fields []submissionField) ([]submissionField, error) {
+	if len(fields) > submissionFieldsMax {
+		return nil, fmt.Errorf("mozna dodac najwyzej %d pol", submissionFieldsMax)
+	}
+
+	used := map[string]bool{}
+	result := make([]submissionField, 0, len(fields))
+	for i, field := range fields {
+		field.Label = strings.TrimSpace(field.Label)
+		if field.Label == "" || len(field.Label) > submissionFieldLabelMax {
+			return nil, fmt.Errorf("pole %d musi miec etykiete (do %d znakow)", i+1, submissionFieldLabelMax)
+		}
+		field.Type = strings.ToLower(strings.TrimSpace(field.Type))
+		if !validFieldType(field.Type) {
+			return nil, fmt.Errorf("nieprawidlowy typ pola %q", field.Label)
+		}
+
+		if field.Type == fieldTypeSelect {
+			var options []string
+			for _, option := range field.Options {
+				if option = strings.TrimSpace(option); option != "" {
+					options = append(options, option)
+				}
+			}
+			if len(options) == 0 || len(options) > submissionFieldOptions {
+				return nil, fmt.Errorf("lista %q musi miec od 1 do %d opcji", field.Label, submissionFieldOptions)
+			}
+			field.Options = options
+		} else {
+			field.Options = nil
+		}
+
+		key := sanitizeFilename(field.Key)
+		if key == "" {
+			key = sanitizeFilename(field.Label)
+		}
+		if key == "" {
+			key = fmt.Sprintf("pole-%d", i+1)
+		}
+		base := key
+		for n := 2; used[key]; n++ {
+			key = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[key] = true
+		field.Key = key
+
+		result = append(result, field)
+	}
+	return result, nil
+}
+
+func (s *Server) updateSubmissionGroupFields(id int64, fields []submissionField) (*submissionGroupRecord, error) {
+	value := sql.NullString{}
+	if len(fields) > 0 {
+		encoded, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		value = sql.NullString{String: string(encoded), Valid: true}
+	}
+	if _, err := s.db.Exec(`UPDATE submission_groups SET form_fields = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, value, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
+// readSubmissionFields checks the answers in an upload form and returns them
+// keyed by field, or a message for the contributor.
+func readSubmissionFields(fields []submissionField, form url.Values) (map[string]string, string) {
+	values := map[string]string{}
+	for _, field := range fields {
+		raw := strings.TrimSpace(form.Get(field.InputName()))
+		if field.Type == fieldTypeCheckbox {
+			if raw == "" {
+				if field.Required {
+					return nil, "Zaznacz pole: " + field.Label
+				}
+				continue
+			}
+			values[field.Key] = "tak"
+			continue
+		}
+
+		if raw == "" {
+			if field.Required {
+				return nil, "Uzupelnij pole: " + field.Label
+			}
+			continue
+		}
+		if len(raw) > submissionFieldValueMax {
+			return nil, "Wartosc pola " + field.Label + " jest za dluga"
+		}
+
+		switch field.Type {
+		case fieldTypeEmail:
+			addr, err := mail.ParseAddress(raw)
+			if err != nil || addr.Address != raw {
+				return nil, "Podaj poprawny adres e-mail w polu: " + field.Label
+			}
+		case fieldTypeNumber:
+			if _, err := strconv.ParseFloat(strings.Replace(raw, ",", ".", 1), 64); err != nil {
+				return nil, "Podaj liczbe w polu: " + field.Label
+			}
+		case fieldTypeSelect:
+			valid := false
+			for _, option := range field.Options {
+				if option == raw {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, "Wybierz opcje z listy w polu: " + field.Label
+			}
+		}
+		values[field.Key] = raw
+	}
+	return values, ""
+}
+
+func encodeSubmissionFieldValues(values map[string]string) (sql.NullString, error) {
+	if len(values) == 0 {
+		return sql.NullString{}, nil
+	}
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(encoded), Valid: true}, nil
+}
+
+// submissionFieldValues pairs stored answers with the current field labels.
+// Answers to fields removed since upload are listed last under their key.
+func submissionFieldValues(fields []submissionField, raw sql.NullString) []submissionFieldValue {
+	if !raw.Valid || raw.String == "" {
+		return nil
+	}
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(raw.String), &values); err != nil {
+		return nil
+	}
+
+	var result []submissionFieldValue
+	for _, field := range fields {
+		if value, ok := values[field.Key]; ok {
+			result = append(result, submissionFieldValue{Key: field.Key, Label: field.Label, Value: value})
+			delete(values, field.Key)
+		}
+	}
+	leftover := make([]string, 0, len(values))
+	for key := range values {
+		leftover = append(leftover, key)
+	}
+	sort.Strings(leftover)
+	for _, key := range leftover {
+		result = append(result, submissionFieldValue{Key: key, Label: key, Value: values[key]})
+	}
+	return result
+}
@@ -0,0 +1,455 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	submissionInviteColumns  = `id, group_id, name, code, contributor_token, created_at`
+	submissionInviteMaxBatch = 200
+	submissionInviteMaxName  = 200
+)
+
+var errSubmissionInviteName = errors.New("osoba o tym imieniu jest juz na liscie")
+
+// submissionInviteRecord is a named person invited to an invite-only group.
+// Their code doubles as a receipt code and their contributor token is the
+// identity every upload from the invite link is attributed to.
+type submissionInviteRecord struct {
+	ID               int64
+	GroupID          int64
+	Name             string
+	Code             string
+	ContributorToken string
+	CreatedAt        time.Time
+}
+
+type submissionInviteView struct {
+	ID         int64  `json:"id"`
+	Name       string `json:"name"`
+	Code       string `json:"code"`
+	URL        string `json:"url"`
+	Files      int    `json:"files"`
+	LastUpload string `json:"lastUpload,omitempty"`
+}
+
+func submissionInviteURL(baseURL, code string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/submitted/invite/" + code
+}
+
+func (i submissionInviteRecord) toView(baseURL string) submissionInviteView {
+	return submissionInviteView{
+		ID:   i.ID,
+		Name: i.Name,
+		Code: i.Code,
+		URL:  submissionInviteURL(baseURL, i.Code),
+	}
+}
+
+func scanSubmissionInvite(row rowScanner) (*submissionInviteRecord, error) {
+	var rec submissionInviteRecord
+	if err := row.Scan(&rec.ID, &rec.GroupID, &rec.Name, &rec.Code, &rec.ContributorToken, &rec.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func (s *Server) getSubmissionInviteByID(id int64) (*submissionInviteRecord, error) {
+	return scanSubmissionInvite(s.db.QueryRow(`SELECT `+submissionInviteColumns+` FROM submission_invites WHERE id = ?`, id))
+}
+
+func (s *Server) getSubmissionInviteByCode(code string) (*submissionInviteRecord, error) {
+	return scanSubmissionInvite(s.db.QueryRow(`SELECT `+submissionInviteColumns+` FROM submission_invites WHERE code = ?`, code))
+}
+
+// submissionInviteFor returns the invite behind a contributor token in the
+// group, or nil when the visitor was not invited.
+func (s *Server) submissionInviteFor(groupID int64, contributorToken string) *submissionInviteRecord {
+	if contributorToken == "" {
+		return nil
+	}
+	invite, err := scanSubmissionInvite(s.db.QueryRow(`SELECT `+submissionInviteColumns+` FROM submission_invites WHERE group_id = ? AND contributor_token = ?`,
+		groupID, contributorToken))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("submission invite: %v", err)
+		}
+		return nil
+	}
+	return invite
+}
+
+// submissionInvites lists the invitees of a group with the number of files
+// each of them has sent.
+func (s *Server) submissionInvites(groupID int64, baseURL string) ([]submissionInviteView, error) {
+	rows, err := s.db.Query(`SELECT `+submissionInviteColumns+` FROM submission_invites WHERE group_id = ? ORDER BY name COLLATE NOCASE, id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	var invites []submissionInviteView
+	tokens := map[string]int{}
+	for rows.Next() {
+		rec, err := scanSubmissionInvite(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tokens[rec.ContributorToken] = len(invites)
+		invites = append(invites, rec.toView(baseURL))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(invites) == 0 {
+		return invites, nil
+	}
+
+	rows, err = s.db.Query(`SELECT contributor_token, created_at FROM submissions WHERE group_id = ? ORDER BY created_at`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var token string
+		var createdAt time.Time
+		if err := rows.Scan(&token, &createdAt); err != nil {
+			return nil, err
+		}
+		if i, ok := tokens[token]; ok {
+			invites[i].Files++
+			invites[i].LastUpload = createdAt.Local().Format("02.01.2006 15:04")
+		}
+	}
+	return invites, rows.Err()
+}
+
+func (s *Server) submissionInviteNameTaken(groupID int64, name string, excludeID int64) (bool, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM submission_invites WHERE group_id = ? AND name = ? COLLATE NOCASE AND id != ?`,
+		groupID, name, excludeID).Scan(&count)
+	return count > 0, err
+}
+
+// createSubmissionInvite adds an invitee and registers their code as a
+// receipt, so the code typed on another device restores their identity.
+func (s *Server) createSubmissionInvite(groupID int64, name string) (*submissionInviteRecord, error) {
+	taken, err := s.submissionInviteNameTaken(groupID, name, 0)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, errSubmissionInviteName
+	}
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	code, err := s.submissionReceipt(groupID, token)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.db.Exec(`INSERT INTO submission_invites (group_id, name, code, contributor_token) VALUES (?, ?, ?, ?)`,
+		groupID, name, code, token)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return s.getSubmissionInviteByID(id)
+}
+
+// regenerateSubmissionInviteCode replaces a leaked code. The invitee keeps
+// their files but needs the new link.
+func (s *Server) regenerateSubmissionInviteCode(invite *submissionInviteRecord) (*submissionInviteRecord, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	code, err := s.submissionReceipt(invite.GroupID, token)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE submissions SET contributor_token = ? WHERE group_id = ? AND contributor_token = ?`,
+		token, invite.GroupID, invite.ContributorToken); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE submission_invites SET code = ?, contributor_token = ? WHERE id = ?`, code, token, invite.ID); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_receipts WHERE code = ?`, invite.Code); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionInviteByID(invite.ID)
+}
+
+// renameSubmissionInvite also renames the files already sent by the invitee.
+func (s *Server) renameSubmissionInvite(invite *submissionInviteRecord, name string) (*submissionInviteRecord, error) {
+	taken, err := s.submissionInviteNameTaken(invite.GroupID, name, invite.ID)
+	if err != nil {
+		return nil, err
+	}
+	if taken {
+		return nil, errSubmissionInviteName
+	}
+	if _, err := s.db.Exec(`UPDATE submission_invites SET name = ? WHERE id = ?`, name, invite.ID); err != nil {
+		return nil, err
+	}
+	if _, err := s.db.Exec(`UPDATE submissions SET uploader_name = ? WHERE group_id = ? AND contributor_token = ?`,
+		name, invite.GroupID, invite.ContributorToken); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionInviteByID(invite.ID)
+}
+
+// deleteSubmissionInvite revokes the link. Files already sent stay in the
+// group.
+func (s *Server) deleteSubmissionInvite(invite *submissionInviteRecord) error {
+	if _, err := s.db.Exec(`DELETE FROM submission_invites WHERE id = ?`, invite.ID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM submission_receipts WHERE code = ?`, invite.Code)
+	return err
+}
+
+func (s *Server) updateSubmissionGroupInviteOnly(id int64, inviteOnly bool) (*submissionGroupRecord, error) {
+	if _, err := s.db.Exec(`UPDATE submission_groups SET invite_only = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, inviteOnly, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
+func submissionInviteName(raw string) string {
+	name := strings.Join(strings.Fields(raw), " ")
+	if len(name) > submissionInviteMaxName {
+		return ""
+	}
+	return name
+}
+
+// handleSubmissionGroupInvites lists the invitees of a group or adds new
+// ones: GET and POST /api/submissions/groups/<id>/invites with
+// {"names": [...]}.
+func (s *Server) handleSubmissionGroupInvites(w http.ResponseWriter, r *http.Request, id int64) {
+	group, err := s.getSubmissionGroupByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Grupa nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac grupy")
+		return
+	}
+
+	baseURL := requestBaseURL(r)
+	switch r.Method {
+	case http.MethodGet:
+		invites, err := s.submissionInvites(group.ID, baseURL)
+		if err != nil {
+			log.Printf("list submission invites: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac zaproszen")
+			return
+		}
+		if invites == nil {
+			invites = []submissionInviteView{}
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"invites": invites})
+	case http.MethodPost:
+		var req struct {
+			Names []string `json:"names"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+			return
+		}
+		var names []string
+		seen := map[string]bool{}
+		for _, raw := range req.Names {
+			name := submissionInviteName(raw)
+			if name == "" {
+				if strings.TrimSpace(raw) != "" {
+					writeJSONError(w, http.StatusBadRequest, "Imie jest za dlugie")
+					return
+				}
+				continue
+			}
+			if key := strings.ToLower(name); !seen[key] {
+				seen[key] = true
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			writeJSONError(w, http.StatusBadRequest, "Podaj co najmniej jedno imie")
+			return
+		}
+		if len(names) > submissionInviteMaxBatch {
+			writeJSONError(w, http.StatusBadRequest, "Za duzo osob naraz")
+			return
+		}
+
+		created := make([]submissionInviteView, 0, len(names))
+		var skipped []string
+		for _, name := range names {
+			invite, err := s.createSubmissionInvite(group.ID, name)
+			if errors.Is(err, errSubmissionInviteName) {
+				skipped = append(skipped, name)
+				continue
+			}
+			if err != nil {
+				log.Printf("create submission invite: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie utworzyc zaproszenia")
+				return
+			}
+			created = append(created, invite.toView(baseURL))
+		}
+		if s.logger != nil && len(created) > 0 {
+			s.logger.Log(r, "zaproszenia")
+		}
+		writeJSON(w, http.StatusCreated, map[string]any{"invites": created, "skipped": skipped})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+	}
+}
+
+// handleSubmissionInvite renames, regenerates or revokes one invite:
+// PATCH and DELETE /api/submissions/invites/<id>.
+func (s *Server) handleSubmissionInvite(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/submissions/invites/"), "/")
+	id, err := strconv.ParseInt(path, 10, 64)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe zaproszenie")
+		return
+	}
+	invite, err := s.getSubmissionInviteByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Zaproszenie nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac zaproszenia")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		var req struct {
+			Name       *string `json:"name"`
+			Regenerate bool    `json:"regenerate"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+			return
+		}
+		if req.Name != nil {
+			name := submissionInviteName(*req.Name)
+			if name == "" {
+				writeJSONError(w, http.StatusBadRequest, "Podaj imie")
+				return
+			}
+			if invite, err = s.renameSubmissionInvite(invite, name); err != nil {
+				if errors.Is(err, errSubmissionInviteName) {
+					writeJSONError(w, http.StatusConflict, err.Error())
+					return
+				}
+				log.Printf("rename submission invite: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zaproszenia")
+				return
+			}
+		}
+		if req.Regenerate {
+			if invite, err = s.regenerateSubmissionInviteCode(invite); err != nil {
+				log.Printf("regenerate submission invite: %v", err)
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie odswiezyc kodu")
+				return
+			}
+		}
+		writeJSON(w, http.StatusOK, invite.toView(requestBaseURL(r)))
+	case http.MethodDelete:
+		if err := s.deleteSubmissionInvite(invite); err != nil {
+			log.Printf("delete submission invite: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac zaproszenia")
+			return
+		}
+		if s.logger != nil {
+			s.logger.Log(r, "usunzapr")
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	default:
+		w.Header().Set("Allow", "PATCH, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+	}
+}
+
+// renderSubmissionInvite opens a group through a personal invite link. The
+// visitor takes over the invitee identity, like after entering a receipt
+// code, and uploads under the invited name. A logged-in admin only previews
+// the page and keeps their own identity.
+func (s *Server) renderSubmissionInvite(w http.ResponseWriter, r *http.Request, rawCode string) {
+	code := normalizeReceiptCode(rawCode)
+	if code == "" {
+		http.NotFound(w, r)
+		return
+	}
+	invite, err := s.getSubmissionInviteByCode(code)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	group, err := s.getSubmissionGroupByID(invite.GroupID)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	loggedIn := s.sessions.authenticated(w, r)
+	if current := submissionViewerTokenFromRequest(r); !loggedIn && current != invite.ContributorToken {
+		if current != "" {
+			if err := s.mergeSubmissionViewer(current, invite.ContributorToken); err != nil {
+				log.Printf("merge contributor: %v", err)
+				http.Error(w, "failed to open invite", http.StatusInternalServerError)
+				return
+			}
+		}
+		setSubmissionViewerCookie(w, invite.ContributorToken)
+	}
+	baseURL := requestBaseURL(r)
+
+	entries, err := s.submissionEntriesForGroup(group, invite.ContributorToken, false, "")
+	if err != nil {
+		log.Printf("list submissions: %v", err)
+		http.Error(w, "failed to load submissions", http.StatusInternalServerError)
+		return
+	}
+
+	view := group.toView(baseURL)
+	inviteView := invite.toView(baseURL)
+	data := pageData{
+		View:                  "submitted",
+		BaseURL:               baseURL,
+		ActiveSubmissionGroup: &view,
+		SubmissionEntries:     entries,
+		AllowSubmissionUpload: loggedIn || group.Window.uploadError(time.Now()) == "",
+		SubmissionUploadLimit: int(submissionUploadMaxSize >> 20),
+		SubmissionReceipt:     invite.Code,
+		SubmissionInvite:      &inviteView,
+		Meta:                  submissionGroupPageMeta(r, group.Name, false),
+	}
+	s.applySubmissionVoting(w, &data, group, invite.ContributorToken, loggedIn)
+	s.applySubmissionComments(&data, group, loggedIn)
+	s.renderPage(w, data)
+}
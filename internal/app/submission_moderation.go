@@ -0,0 +1,192 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+)
+
+const (
+	submissionStatusPending  = "pending"
+	submissionStatusApproved = "approved"
+	submissionStatusRejected = "rejected"
+
+	moderationMaxBatch = 500
+)
+
+var submissionStatusLabels = map[string]string{
+	submissionStatusPending:  "Oczekuje",
+	submissionStatusApproved: "Zaakceptowane",
+	submissionStatusRejected: "Odrzucone",
+}
+
+type submissionStatusFilter struct {
+	Key   string
+	Label string
+	Count int
+}
+
+// parseSubmissionStatus returns the status for a query value, or an empty
+// string for anything that is not a known status.
+func parseSubmissionStatus(raw string) string {
+	status := strings.ToLower(strings.TrimSpace(raw))
+	if _, ok := submissionStatusLabels[status]; ok {
+		return status
+	}
+	return ""
+}
+
+// submissionStatusFilters lists the moderation queue tabs for a group with
+// the number of entries in each status.
+func (s *Server) submissionStatusFilters(groupID int64) ([]submissionStatusFilter, error) {
+	rows, err := s.db.Query(`SELECT status, COUNT(*) FROM submissions WHERE group_id = ? GROUP BY status`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{}
+	total := 0
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		counts[status] = count
+		total += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return []submissionStatusFilter{
+		{Key: "", Label: "Wszystkie", Count: total},
+		{Key: submissionStatusPending, Label: "Oczekujace", Count: counts[submissionStatusPending]},
+		{Key: submissionStatusApproved, Label: "Zaakceptowane", Count: counts[submissionStatusApproved]},
+		{Key: submissionStatusRejected, Label: "Odrzucone", Count: counts[submissionStatusRejected]},
+	}, nil
+}
+
+func (s *Server) pendingSubmissionCounts() (map[int64]int, error) {
+	rows, err := s.db.Query(`SELECT group_id, COUNT(*) FROM submissions WHERE status = ? GROUP BY group_id`, submissionStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[int64]int{}
+	for rows.Next() {
+		var groupID int64
+		var count int
+		if err := rows.Scan(&groupID, &count); err != nil {
+			return nil, err
+		}
+		counts[groupID] = count
+	}
+	return counts, rows.Err()
+}
+
+// moderateSubmissions sets the status of the given entries. A nil note keeps
+// the current reviewer note, an empty one clears it.
+func (s *Server) moderateSubmissions(ids []int64, status string, note *string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	query := `UPDATE submissions SET status = ?, reviewed_at = CURRENT_TIMESTAMP`
+	args := []any{status}
+	if note != nil {
+		query += `, review_note = ?`
+		trimmed := strings.TrimSpace(*note)
+		args = append(args, sql.NullString{String: trimmed, Valid: trimmed != ""})
+	}
+	query += ` WHERE id IN (` + placeholders + `)`
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	result, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+type moderationRequest struct {
+	IDs    []int64 `json:"ids"`
+	Status string  `json:"status"`
+	Note   *string `json:"note"`
+}
+
+// handleSubmissionModeration approves or rejects one or more entries.
+func (s *Server) handleSubmissionModeration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	var req moderationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	status := parseSubmissionStatus(req.Status)
+	if status == "" {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy status")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Nie wybrano zadnych plikow")
+		return
+	}
+	if len(req.IDs) > moderationMaxBatch {
+		writeJSONError(w, http.StatusBadRequest, "Za duzo plikow naraz")
+		return
+	}
+	if req.Note != nil && len(*req.Note) > 1000 {
+		writeJSONError(w, http.StatusBadRequest, "Notatka jest za dluga")
+		return
+	}
+
+	var approved []int64
+	if status == submissionStatusApproved {
+		for _, id := range req.IDs {
+			if entry, _, err := s.getSubmissionEntry(id); err == nil && entry.Status != submissionStatusApproved {
+				approved = append(approved, id)
+			}
+		}
+	}
+
+	updated, err := s.moderateSubmissions(req.IDs, status, req.Note)
+	if err != nil {
+		log.Printf("moderate submissions: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac decyzji")
+		return
+	}
+	if updated == 0 {
+		writeJSONError(w, http.StatusNotFound, "Nie znaleziono plikow")
+		return
+	}
+
+	for _, id := range approved {
+		if entry, group, err := s.getSubmissionEntry(id); err == nil {
+			s.queueWebhookEvent(webhookEventSubmissionApproved, group, entry)
+		}
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "moderacja")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  status,
+		"updated": updated,
+	})
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	publishModeCopy = "copy"
+	publishModeMove = "move"
+)
+
+type publishRequest struct {
+	IDs      []int64 `json:"ids"`
+	FolderID int64   `json:"folderId"`
+	Mode     string  `json:"mode"`
+}
+
+type publishSkipped struct {
+	ID     int64  `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// submissionStoredPath returns where an entry is kept in its group directory,
+// refusing names that would point outside of it.
+func (s *Server) submissionStoredPath(entry *submissionEntryRecord, group *submissionGroupRecord) (string, error) {
+	dir := filepath.Clean(s.submissionGroupDir(group))
+	target := filepath.Clean(filepath.Join(dir, entry.FileName))
+	if !strings.HasPrefix(target, dir+string(os.PathSeparator)) {
+		return "", os.ErrNotExist
+	}
+	return target, nil
+}
+
+// submissionFilePath returns the file of an entry. Entries moved into the
+// gallery are served from the folder they were published to.
+func (s *Server) submissionFilePath(entry *submissionEntryRecord, group *submissionGroupRecord) (string, error) {
+	if entry.ScanStatus.String == scanStatusInfected {
+		return "", os.ErrNotExist
+	}
+	target, err := s.submissionStoredPath(entry, group)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(target); err == nil || !entry.PublishedImage.Valid {
+		return target, err
+	}
+
+	folder, err := s.getFolderByID(entry.PublishedFolderID.Int64)
+	if err != nil {
+		return "", os.ErrNotExist
+	}
+	return s.folderImagePath(folder, entry.PublishedImage.String)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// publishSubmission copies or moves an approved image into a gallery folder
+// and returns the name it got there.
+func (s *Server) publishSubmission(id int64, folder *folderRecord, mode string) (string, string, error) {
+	entry, group, err := s.getSubmissionEntry(id)
+	if err != nil {
+		return "", "Plik nie istnieje", nil
+	}
+	if entry.Status != submissionStatusApproved {
+		return "", "Plik nie zostal zaakceptowany", nil
+	}
+	if !isImageFile(entry.FileName) {
+		return "", "Do galerii mozna dodac tylko obrazy", nil
+	}
+	source, err := s.submissionFilePath(entry, group)
+	if err != nil {
+		return "", "Plik nie istnieje na dysku", nil
+	}
+
+	dir := s.dir
+	if folder.Path != "" {
+		dir = filepath.Join(s.dir, folder.Path)
+	}
+	if err := EnsureDir(dir); err != nil {
+		return "", "", err
+	}
+	target, err := uniqueFilename(dir, entry.FileName)
+	if err != nil {
+		return "", "", err
+	}
+
+	if mode == publishModeMove {
+		if entry.PublishedImage.Valid && !strings.HasPrefix(source, filepath.Clean(s.submissionGroupDir(group))+string(os.PathSeparator)) {
+			if err := s.deleteImageAttribution(entry.PublishedFolderID.Int64, entry.PublishedImage.String); err != nil {
+				log.Printf("delete image attribution: %v", err)
+			}
+			if err := s.deleteImageComments(entry.PublishedFolderID.Int64, entry.PublishedImage.String); err != nil {
+				log.Printf("delete image comments: %v", err)
+			}
+		}
+		if err := os.Rename(source, target); err != nil {
+			if err := copyFile(source, target); err != nil {
+				return "", "", err
+			}
+			if err := os.Remove(source); err != nil {
+				log.Printf("remove published submission: %v", err)
+			}
+		}
+	} else if err := copyFile(source, target); err != nil {
+		return "", "", err
+	}
+
+	name := filepath.Base(target)
+	if err := s.saveImageAttribution(folder.ID, name, imageAttribution{
+		Author:       entry.UploaderName,
+		OriginalName: entry.OriginalName,
+		SubmissionID: sql.NullInt64{Int64: entry.ID, Valid: true},
+	}); err != nil {
+		return "", "", err
+	}
+	if _, err := s.db.Exec(`UPDATE submissions SET published_folder_id = ?, published_image = ?, published_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		folder.ID, name, entry.ID); err != nil {
+		return "", "", err
+	}
+	return name, "", nil
+}
+
+// renamePublishedSubmission follows a gallery rename, so the entry keeps
+// pointing at its published file.
+func (s *Server) renamePublishedSubmission(folderID int64, oldName, newName string) error {
+	_, err := s.db.Exec(`UPDATE submissions SET published_image = ? WHERE published_folder_id = ? AND published_image = ?`,
+		newName, folderID, oldName)
+	return err
+}
+
+// unpublishSubmissions marks entries as no longer in the gallery after their
+// image, or the whole folder when image is empty, was deleted.
+func (s *Server) unpublishSubmissions(folderID int64, image string) error {
+	query := `UPDATE submissions SET published_folder_id = NULL, published_image = NULL, published_at = NULL WHERE published_folder_id = ?`
+	args := []any{folderID}
+	if image != "" {
+		query += ` AND published_image = ?`
+		args = append(args, image)
+	}
+	_, err := s.db.Exec(query, args...)
+	return err
+}
+
+// handleSubmissionPublish adds selected submissions to a gallery folder.
+func (s *Server) handleSubmissionPublish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	var req publishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	if len(req.IDs) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "Nie wybrano zadnych plikow")
+		return
+	}
+	if len(req.IDs) > moderationMaxBatch {
+		writeJSONError(w, http.StatusBadRequest, "Za duzo plikow naraz")
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(req.Mode))
+	if mode == "" {
+		mode = publishModeCopy
+	}
+	if mode != publishModeCopy && mode != publishModeMove {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy tryb publikacji")
+		return
+	}
+
+	folder, err := s.getFolderByID(req.FolderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusBadRequest, "Folder nie istnieje")
+			return
+		}
+		log.Printf("folder lookup: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic folderu")
+		return
+	}
+
+	published := []string{}
+	skipped := []publishSkipped{}
+	for _, id := range req.IDs {
+		name, reason, err := s.publishSubmission(id, folder, mode)
+		if err != nil {
+			log.Printf("publish submission %d: %v", id, err)
+			reason = "Nie udalo sie skopiowac pliku"
+		}
+		if reason != "" {
+			skipped = append(skipped, publishSkipped{ID: id, Reason: reason})
+			continue
+		}
+		published = append(published, name)
+	}
+
+	if s.logger != nil && len(published) > 0 {
+		s.logger.Log(r, "publikuj")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":    "ok",
+		"folder":    folder.Slug,
+		"published": published,
+		"skipped":   skipped,
+	})
+}
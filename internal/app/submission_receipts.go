@@ -0,0 +1,192 @@
+package app
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	receiptAlphabet    = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	receiptGroups      = 3
+	receiptGroupLength = 4
+
+	receiptMaxAttempts = 10
+	receiptWindow      = 15 * time.Minute
+)
+
+// newReceiptCode returns a code like K7PM-3XQA-TR9D. The alphabet leaves out
+// characters that are easy to confuse when copied by hand.
+func newReceiptCode() (string, error) {
+	max := big.NewInt(int64(len(receiptAlphabet)))
+	var builder strings.Builder
+	for i := 0; i < receiptGroups*receiptGroupLength; i++ {
+		if i > 0 && i%receiptGroupLength == 0 {
+			builder.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteByte(receiptAlphabet[n.Int64()])
+	}
+	return builder.String(), nil
+}
+
+// normalizeReceiptCode accepts codes typed in lower case or without dashes.
+func normalizeReceiptCode(raw string) string {
+	var chars []byte
+	for _, r := range strings.ToUpper(raw) {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			chars = append(chars, byte(r))
+		}
+	}
+	if len(chars) != receiptGroups*receiptGroupLength {
+		return ""
+	}
+	parts := make([]string, 0, receiptGroups)
+	for i := 0; i < len(chars); i += receiptGroupLength {
+		parts = append(parts, string(chars[i:i+receiptGroupLength]))
+	}
+	return strings.Join(parts, "-")
+}
+
+// submissionReceipt returns the code of a contributor in a group, creating
+// it on first use.
+func (s *Server) submissionReceipt(groupID int64, contributorToken string) (string, error) {
+	var code string
+	err := s.db.QueryRow(`SELECT code FROM submission_receipts WHERE group_id = ? AND contributor_token = ?`, groupID, contributorToken).Scan(&code)
+	if err == nil {
+		return code, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		if code, err = newReceiptCode(); err != nil {
+			return "", err
+		}
+		result, err := s.db.Exec(`INSERT OR IGNORE INTO submission_receipts (code, group_id, contributor_token) VALUES (?, ?, ?)`,
+			code, groupID, contributorToken)
+		if err != nil {
+			return "", err
+		}
+		if n, _ := result.RowsAffected(); n == 1 {
+			return code, nil
+		}
+	}
+	return "", errors.New("receipt: could not generate a unique code")
+}
+
+// existingSubmissionReceipt returns the code shown to a returning
+// contributor, or an empty string when they have not uploaded yet.
+func (s *Server) existingSubmissionReceipt(groupID int64, contributorToken string) string {
+	if contributorToken == "" {
+		return ""
+	}
+	var code string
+	err := s.db.QueryRow(`SELECT code FROM submission_receipts WHERE group_id = ? AND contributor_token = ?`, groupID, contributorToken).Scan(&code)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("submission receipt: %v", err)
+	}
+	return code
+}
+
+// handleSubmissionReceipt restores the contributor identity from a receipt
+// code. Everything the current device owns, in any group, is moved to the
+// restored identity, because the viewer cookie is shared by all groups.
+func (s *Server) handleSubmissionReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+
+	limiterKey := "receipt|" + clientIP(r)
+	if blocked, wait := s.receiptLimiter.blocked(limiterKey); blocked {
+		minutes := int(wait.Minutes()) + 1
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Zbyt wiele nieudanych prob. Sprobuj ponownie za %d min.", minutes))
+		return
+	}
+
+	var token string
+	code := normalizeReceiptCode(req.Code)
+	err := sql.ErrNoRows
+	if code != "" {
+		err = s.db.QueryRow(`SELECT contributor_token FROM submission_receipts WHERE code = ?`, code).Scan(&token)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		s.receiptLimiter.fail(limiterKey)
+		if s.logger != nil {
+			s.logger.Log(r, "zlykod")
+		}
+		writeJSONError(w, http.StatusNotFound, "Nieprawidlowy kod potwierdzenia")
+		return
+	}
+	if err != nil {
+		log.Printf("submission receipt: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic kodu")
+		return
+	}
+	s.receiptLimiter.reset(limiterKey)
+
+	if current := submissionViewerTokenFromRequest(r); current != "" && current != token {
+		if err := s.mergeSubmissionViewer(current, token); err != nil {
+			log.Printf("merge contributor: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie przywrocic zgloszen")
+			return
+		}
+	}
+	setSubmissionViewerCookie(w, token)
+
+	if s.logger != nil {
+		s.logger.Log(r, "kod")
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// mergeSubmissionViewer moves entries, receipts, invites and votes of one
+// viewer token to another. Where both tokens have a receipt or a vote in the
+// same group, the one of the target token wins.
+func (s *Server) mergeSubmissionViewer(from, to string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []string{
+		`UPDATE submissions SET contributor_token = ? WHERE contributor_token = ?`,
+		`UPDATE submission_invites SET contributor_token = ? WHERE contributor_token = ?`,
+		`DELETE FROM submission_receipts WHERE contributor_token = ?2
+			AND group_id IN (SELECT group_id FROM submission_receipts WHERE contributor_token = ?1)`,
+		`UPDATE submission_receipts SET contributor_token = ? WHERE contributor_token = ?`,
+		`DELETE FROM submission_votes WHERE viewer_token = ?2
+			AND group_id IN (SELECT group_id FROM submission_votes WHERE viewer_token = ?1)`,
+		`UPDATE submission_votes SET viewer_token = ? WHERE viewer_token = ?`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement, to, from); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
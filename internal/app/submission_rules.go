@@ -0,0 +1,296 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+var errSubmissionRulesInvalid = errors.New("nieprawidlowe zasady przesylania")
+
+var dimensionCheckedExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+type submissionFileType struct {
+	Key        string
+	Label      string
+	Extensions []string
+}
+
+var submissionFileTypes = []submissionFileType{
+	{"jpg", "JPEG", []string{".jpg", ".jpeg"}},
+	{"png", "PNG", []string{".png"}},
+	{"gif", "GIF", []string{".gif"}},
+	{"webp", "WEBP", []string{".webp"}},
+	{"avif", "AVIF", []string{".avif"}},
+	{"bmp", "BMP", []string{".bmp"}},
+	{"svg", "SVG", []string{".svg"}},
+	{"pdf", "PDF", []string{".pdf"}},
+}
+
+// submissionRules are the per-group upload limits. Zero values fall back to
+// the global defaults: any supported type, submissionUploadMaxSize per file
+// and no quotas.
+type submissionRules struct {
+	MaxFileSize            sql.NullInt64
+	AllowedTypes           sql.NullString
+	MaxFilesPerContributor sql.NullInt64
+	MaxGroupSize           sql.NullInt64
+	MinImageWidth          sql.NullInt64
+	MinImageHeight         sql.NullInt64
+}
+
+type submissionTypeOption struct {
+	Key     string `json:"key"`
+	Label   string `json:"label"`
+	Checked bool   `json:"checked"`
+}
+
+type submissionRulesView struct {
+	MaxFileMB              int64                  `json:"maxFileMB"`
+	AllowedTypes           []string               `json:"allowedTypes"`
+	MaxFilesPerContributor int64                  `json:"maxFilesPerContributor"`
+	MaxGroupMB             int64                  `json:"maxGroupMB"`
+	MinImageWidth          int64                  `json:"minImageWidth"`
+	MinImageHeight         int64                  `json:"minImageHeight"`
+	MaxFileBytes           int64                  `json:"-"`
+	TypeOptions            []submissionTypeOption `json:"-"`
+	Accept                 string                 `json:"-"`
+	Summary                []string               `json:"-"`
+}
+
+func (r submissionRules) maxFileSize() int64 {
+	if r.MaxFileSize.Valid && r.MaxFileSize.Int64 > 0 {
+		return r.MaxFileSize.Int64
+	}
+	return submissionUploadMaxSize
+}
+
+// submissionBodyLimit returns the request body cap for an upload to the
+// group with the given slug, or the default cap when it is unknown.
+func (s *Server) submissionBodyLimit(slug string) int64 {
+	if slug == "" {
+		return submissionUploadMaxSize
+	}
+	group, err := s.getSubmissionGroupBySlug(slug)
+	if err != nil {
+		return submissionUploadMaxSize
+	}
+	return group.Rules.maxFileSize()
+}
+
+// typeKeys returns the allowed type keys, or nil when every supported type
+// is accepted.
+func (r submissionRules) typeKeys() []string {
+	if !r.AllowedTypes.Valid || strings.TrimSpace(r.AllowedTypes.String) == "" {
+		return nil
+	}
+	return strings.Split(r.AllowedTypes.String, ",")
+}
+
+func (r submissionRules) allowedTypes() []submissionFileType {
+	keys := r.typeKeys()
+	if keys == nil {
+		return submissionFileTypes
+	}
+	var types []submissionFileType
+	for _, t := range submissionFileTypes {
+		for _, key := range keys {
+			if t.Key == key {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	return types
+}
+
+func (r submissionRules) allowsFile(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, t := range r.allowedTypes() {
+		for _, allowed := range t.Extensions {
+			if ext == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (r submissionRules) typesLabel() string {
+	types := r.allowedTypes()
+	if len(types) == len(submissionFileTypes) {
+		return "obrazy i PDF"
+	}
+	labels := make([]string, 0, len(types))
+	for _, t := range types {
+		labels = append(labels, t.Label)
+	}
+	return strings.Join(labels, ", ")
+}
+
+func (r submissionRules) hasMinDimensions() bool {
+	return (r.MinImageWidth.Valid && r.MinImageWidth.Int64 > 0) || (r.MinImageHeight.Valid && r.MinImageHeight.Int64 > 0)
+}
+
+func (r submissionRules) toView() submissionRulesView {
+	view := submissionRulesView{
+		MaxFileMB:              r.maxFileSize() >> 20,
+		MaxFileBytes:           r.maxFileSize(),
+		AllowedTypes:           r.typeKeys(),
+		MaxFilesPerContributor: r.MaxFilesPerContributor.Int64,
+		MaxGroupMB:             r.MaxGroupSize.Int64 >> 20,
+		MinImageWidth:          r.MinImageWidth.Int64,
+		MinImageHeight:         r.MinImageHeight.Int64,
+	}
+	if view.AllowedTypes == nil {
+		view.AllowedTypes = []string{}
+	}
+
+	allowed := r.allowedTypes()
+	var accept []string
+	for _, t := range submissionFileTypes {
+		checked := false
+		for _, a := range allowed {
+			if a.Key == t.Key {
+				checked = true
+				accept = append(accept, t.Extensions...)
+				break
+			}
+		}
+		view.TypeOptions = append(view.TypeOptions, submissionTypeOption{Key: t.Key, Label: t.Label, Checked: checked})
+	}
+	view.Accept = strings.Join(accept, ",")
+
+	view.Summary = []string{
+		fmt.Sprintf("Maksymalny rozmiar pliku: %d MB.", view.MaxFileMB),
+		"Dozwolone typy: " + r.typesLabel() + ".",
+	}
+	if view.MaxFilesPerContributor > 0 {
+		view.Summary = append(view.Summary, fmt.Sprintf("Limit plikow na osobe: %d.", view.MaxFilesPerContributor))
+	}
+	if view.MaxGroupMB > 0 {
+		view.Summary = append(view.Summary, fmt.Sprintf("Laczny limit grupy: %d MB.", view.MaxGroupMB))
+	}
+	if r.hasMinDimensions() {
+		view.Summary = append(view.Summary, fmt.Sprintf("Minimalne wymiary obrazu JPEG, PNG i GIF: %d x %d px.", view.MinImageWidth, view.MinImageHeight))
+	}
+	return view
+}
+
+type submissionRulesRequest struct {
+	MaxFileMB              int64    `json:"maxFileMB"`
+	AllowedTypes           []string `json:"allowedTypes"`
+	MaxFilesPerContributor int64    `json:"maxFilesPerContributor"`
+	MaxGroupMB             int64    `json:"maxGroupMB"`
+	MinImageWidth          int64    `json:"minImageWidth"`
+	MinImageHeight         int64    `json:"minImageHeight"`
+}
+
+func positiveOrNull(value int64) sql.NullInt64 {
+	if value <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: value, Valid: true}
+}
+
+func (req submissionRulesRequest) rules() (submissionRules, error) {
+	if req.MaxFileMB < 0 || req.MaxFileMB > submissionUploadHardLimit>>20 || req.MaxFilesPerContributor < 0 ||
+		req.MaxGroupMB < 0 || req.MaxGroupMB > submissionGroupHardLimit>>20 || req.MinImageWidth < 0 || req.MinImageHeight < 0 {
+		return submissionRules{}, errSubmissionRulesInvalid
+	}
+
+	var keys []string
+	for _, t := range submissionFileTypes {
+		for _, key := range req.AllowedTypes {
+			if strings.EqualFold(strings.TrimSpace(key), t.Key) {
+				keys = append(keys, t.Key)
+				break
+			}
+		}
+	}
+	if len(keys) != len(req.AllowedTypes) {
+		return submissionRules{}, errSubmissionRulesInvalid
+	}
+	allowedTypes := sql.NullString{}
+	if len(keys) > 0 && len(keys) < len(submissionFileTypes) {
+		allowedTypes = sql.NullString{String: strings.Join(keys, ","), Valid: true}
+	}
+
+	return submissionRules{
+		MaxFileSize:            positiveOrNull(req.MaxFileMB << 20),
+		AllowedTypes:           allowedTypes,
+		MaxFilesPerContributor: positiveOrNull(req.MaxFilesPerContributor),
+		MaxGroupSize:           positiveOrNull(req.MaxGroupMB << 20),
+		MinImageWidth:          positiveOrNull(req.MinImageWidth),
+		MinImageHeight:         positiveOrNull(req.MinImageHeight),
+	}, nil
+}
+
+func (s *Server) updateSubmissionGroupRules(id int64, rules submissionRules) (*submissionGroupRecord, error) {
+	_, err := s.db.Exec(`UPDATE submission_groups SET max_file_size = ?, allowed_types = ?, max_files_per_contributor = ?, max_group_size = ?,
+		min_image_width = ?, min_image_height = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		rules.MaxFileSize, rules.AllowedTypes, rules.MaxFilesPerContributor, rules.MaxGroupSize, rules.MinImageWidth, rules.MinImageHeight, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
+// checkSubmissionRules validates an upload against the group rules and
+// returns the message for the contributor, or an empty string when the file
+// is accepted. The file is rewound after reading its image header. A non-zero
+// replaceID leaves out the entry being replaced from the quotas.
+func (s *Server) checkSubmissionRules(group *submissionGroupRecord, contributorToken, filename string, size int64, file io.ReadSeeker, replaceID int64) (string, error) {
+	rules := group.Rules
+	if !rules.allowsFile(filename) {
+		return "Dozwolone typy plikow: " + rules.typesLabel(), nil
+	}
+	if size > rules.maxFileSize() {
+		return fmt.Sprintf("Plik jest za duzy. Limit to %d MB", rules.maxFileSize()>>20), nil
+	}
+
+	if rules.MaxFilesPerContributor.Valid {
+		var count int64
+		if err := s.db.QueryRow(`SELECT COUNT(*) FROM submissions WHERE group_id = ? AND contributor_token = ? AND status != ? AND id != ?`,
+			group.ID, contributorToken, submissionStatusRejected, replaceID).Scan(&count); err != nil {
+			return "", err
+		}
+		if count >= rules.MaxFilesPerContributor.Int64 {
+			return fmt.Sprintf("Osiagnieto limit %d plikow na osobe", rules.MaxFilesPerContributor.Int64), nil
+		}
+	}
+	if rules.MaxGroupSize.Valid {
+		var total int64
+		if err := s.db.QueryRow(`SELECT COALESCE(SUM(size_bytes), 0) FROM submissions WHERE group_id = ? AND status != ? AND id != ?`,
+			group.ID, submissionStatusRejected, replaceID).Scan(&total); err != nil {
+			return "", err
+		}
+		if total+size > rules.MaxGroupSize.Int64 {
+			return "Grupa osiagnela laczny limit rozmiaru plikow", nil
+		}
+	}
+
+	// Only formats with a registered decoder are measured; WEBP, BMP and
+	// the like pass without a dimension check.
+	if rules.hasMinDimensions() && dimensionCheckedExtensions[strings.ToLower(filepath.Ext(filename))] {
+		cfg, _, err := image.DecodeConfig(file)
+		if _, seekErr := file.Seek(0, io.SeekStart); seekErr != nil {
+			return "", seekErr
+		}
+		if err != nil {
+			return "Nie udalo sie odczytac wymiarow obrazu. Uzyj formatu JPEG, PNG lub GIF", nil
+		}
+		if int64(cfg.Width) < rules.MinImageWidth.Int64 || int64(cfg.Height) < rules.MinImageHeight.Int64 {
+			return fmt.Sprintf("Obraz jest za maly (%d x %d px). Minimum to %d x %d px",
+				cfg.Width, cfg.Height, rules.MinImageWidth.Int64, rules.MinImageHeight.Int64), nil
+		}
+	}
+	return "", nil
+}
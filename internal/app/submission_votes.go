@@ -0,0 +1,459 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	voteMaxAttempts = 30
+	voteWindow      = 10 * time.Minute
+
+	juryScoreMin = 1
+	juryScoreMax = 10
+)
+
+var juryScoreOptions = func() []int {
+	options := make([]int, 0, juryScoreMax-juryScoreMin+1)
+	for score := juryScoreMin; score <= juryScoreMax; score++ {
+		options = append(options, score)
+	}
+	return options
+}()
+
+// submissionVoting holds the judging settings of a group. Visitors get one
+// vote per group which they may move to another entry; jurors score each
+// approved entry.
+type submissionVoting struct {
+	Visitors    bool
+	Jury        bool
+	HideResults bool
+}
+
+func (v submissionVoting) enabled() bool {
+	return v.Visitors || v.Jury
+}
+
+// resultsPublic reports whether visitors may see the standings. Hidden
+// results are revealed once the group stops accepting uploads.
+func (g submissionGroupRecord) resultsPublic(now time.Time) bool {
+	if !g.Voting.enabled() || g.Visibility != visibilityPublic {
+		return false
+	}
+	return !g.Voting.HideResults || g.Window.state(now) == submissionStateClosed
+}
+
+type submissionResultView struct {
+	Rank       int     `json:"rank"`
+	ID         int64   `json:"id"`
+	Author     string  `json:"author"`
+	Original   string  `json:"originalName"`
+	URL        string  `json:"url"`
+	Votes      int     `json:"votes"`
+	Scores     int     `json:"scores"`
+	Average    float64 `json:"average"`
+	ScoreLabel string  `json:"scoreLabel,omitempty"`
+}
+
+func (s *Server) updateSubmissionGroupVoting(id int64, voting submissionVoting) (*submissionGroupRecord, error) {
+	if _, err := s.db.Exec(`UPDATE submission_groups SET visitor_voting = ?, jury_scoring = ?, hide_results = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		voting.Visitors, voting.Jury, voting.HideResults, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
+// submissionResults ranks the approved entries of a group. Jury averages
+// decide first when the jury is enabled and visitor votes break ties.
+func (s *Server) submissionResults(group *submissionGroupRecord) ([]submissionResultView, error) {
+	rows, err := s.db.Query(`SELECT s.id, s.uploader_name, s.original_name,
+		(SELECT COUNT(*) FROM submission_votes v WHERE v.entry_id = s.id),
+		(SELECT COUNT(*) FROM submission_scores j WHERE j.entry_id = s.id),
+		(SELECT AVG(j.score) FROM submission_scores j WHERE j.entry_id = s.id)
+		FROM submissions s WHERE s.group_id = ? AND s.status = ?`, group.ID, submissionStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []submissionResultView
+	for rows.Next() {
+		var result submissionResultView
+		var average sql.NullFloat64
+		if err := rows.Scan(&result.ID, &result.Author, &result.Original, &result.Votes, &result.Scores, &average); err != nil {
+			return nil, err
+		}
+		result.URL = fmt.Sprintf("/submitted/file/%d", result.ID)
+		if average.Valid {
+			result.Average = average.Float64
+			result.ScoreLabel = strconv.FormatFloat(average.Float64, 'f', 2, 64)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	jury := group.Voting.Jury
+	visitors := group.Voting.Visitors
+	less := func(a, b submissionResultView) bool {
+		if jury && a.Average != b.Average {
+			return a.Average > b.Average
+		}
+		if visitors && a.Votes != b.Votes {
+			return a.Votes > b.Votes
+		}
+		return false
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if less(results[i], results[j]) {
+			return true
+		}
+		if less(results[j], results[i]) {
+			return false
+		}
+		return results[i].ID < results[j].ID
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+		if i > 0 && !less(results[i-1], results[i]) {
+			results[i].Rank = results[i-1].Rank
+		}
+	}
+	return results, nil
+}
+
+// applySubmissionVoting adds vote counts, the visitor's own vote and the
+// juror scores to a group page. Viewing the page also hands out the signed
+// voter cookie that the vote endpoint requires.
+func (s *Server) applySubmissionVoting(w http.ResponseWriter, data *pageData, group *submissionGroupRecord, viewerToken string, loggedIn bool) {
+	if group == nil || !group.Voting.enabled() {
+		return
+	}
+	data.SubmissionVoting = group.Voting.Visitors && group.Visibility == visibilityPublic
+	if data.SubmissionVoting && viewerToken != "" {
+		s.setSubmissionVoterCookie(w, viewerToken)
+	}
+	data.SubmissionResultsVisible = loggedIn || group.resultsPublic(time.Now())
+	data.SubmissionResultsHidden = !data.SubmissionResultsVisible && group.Visibility == visibilityPublic
+	if loggedIn && group.Voting.Jury {
+		data.JuryScoreOptions = juryScoreOptions
+	}
+
+	votes := map[int64]int{}
+	var ownVote int64
+	rows, err := s.db.Query(`SELECT entry_id, viewer_token FROM submission_votes WHERE group_id = ?`, group.ID)
+	if err != nil {
+		log.Printf("submission votes: %v", err)
+		return
+	}
+	for rows.Next() {
+		var entryID int64
+		var token string
+		if err := rows.Scan(&entryID, &token); err != nil {
+			log.Printf("submission votes: %v", err)
+			break
+		}
+		votes[entryID]++
+		if viewerToken != "" && token == viewerToken {
+			ownVote = entryID
+		}
+	}
+	rows.Close()
+
+	scores := map[int64]int{}
+	if loggedIn && group.Voting.Jury {
+		rows, err := s.db.Query(`SELECT j.entry_id, j.score FROM submission_scores j JOIN submissions s ON s.id = j.entry_id
+			WHERE s.group_id = ? AND j.juror = ?`, group.ID, s.cfg.Username)
+		if err != nil {
+			log.Printf("submission scores: %v", err)
+			return
+		}
+		for rows.Next() {
+			var entryID int64
+			var score int
+			if err := rows.Scan(&entryID, &score); err != nil {
+				log.Printf("submission scores: %v", err)
+				break
+			}
+			scores[entryID] = score
+		}
+		rows.Close()
+	}
+
+	for i := range data.SubmissionEntries {
+		entry := &data.SubmissionEntries[i]
+		entry.Voted = entry.ID == ownVote
+		entry.JuryScore = scores[entry.ID]
+		if data.SubmissionResultsVisible {
+			entry.Votes = votes[entry.ID]
+		}
+	}
+
+	if data.SubmissionResultsVisible {
+		results, err := s.submissionResults(group)
+		if err != nil {
+			log.Printf("submission results: %v", err)
+			return
+		}
+		data.SubmissionResults = results
+	}
+}
+
+func (s *Server) deleteSubmissionVotes(entryID int64) {
+	if _, err := s.db.Exec(`DELETE FROM submission_votes WHERE entry_id = ?`, entryID); err != nil {
+		log.Printf("delete submission votes: %v", err)
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_scores WHERE entry_id = ?`, entryID); err != nil {
+		log.Printf("delete submission scores: %v", err)
+	}
+}
+
+// voterSignature ties the voter cookie to a viewer token, so only tokens that
+// came with a page view can vote.
+func (s *Server) voterSignature(viewerToken string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("voter|" + viewerToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) setSubmissionVoterCookie(w http.ResponseWriter, viewerToken string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     submissionVoterCookie,
+		Value:    s.voterSignature(viewerToken),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Expires:  time.Now().Add(30 * 24 * time.Hour),
+		MaxAge:   int((30 * 24 * time.Hour).Seconds()),
+	})
+}
+
+// submissionVoterToken returns the viewer token if it carries a valid voter
+// signature, or "" otherwise.
+func (s *Server) submissionVoterToken(r *http.Request) string {
+	token := submissionViewerTokenFromRequest(r)
+	if token == "" {
+		return ""
+	}
+	cookie, err := r.Cookie(submissionVoterCookie)
+	if err != nil || !hmac.Equal([]byte(cookie.Value), []byte(s.voterSignature(token))) {
+		return ""
+	}
+	return token
+}
+
+// handleSubmissionVote casts or withdraws the visitor's vote:
+// POST and DELETE /api/submissions/entries/<id>/vote.
+func (s *Server) handleSubmissionVote(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) {
+	if r.Method != http.MethodPost && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "POST, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if !group.Voting.Visitors || group.Visibility != visibilityPublic {
+		writeJSONError(w, http.StatusForbidden, "Glosowanie nie jest wlaczone w tej grupie")
+		return
+	}
+	if entry.Status != submissionStatusApproved {
+		writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+		return
+	}
+
+	limiterKey := "vote|" + clientIP(r)
+	if blocked, wait := s.voteLimiter.blocked(limiterKey); blocked {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("Zbyt wiele glosow. Sprobuj ponownie za %d min.", int(wait.Minutes())+1))
+		return
+	}
+
+	viewerToken := s.submissionVoterToken(r)
+	if viewerToken == "" {
+		writeJSONError(w, http.StatusForbidden, "Odswiez strone, aby zaglosowac")
+		return
+	}
+	if viewerToken == entry.ContributorToken {
+		writeJSONError(w, http.StatusForbidden, "Nie mozna glosowac na wlasne zgloszenie")
+		return
+	}
+	s.voteLimiter.fail(limiterKey)
+
+	// One vote per address in a group as well, since a new viewer token is
+	// only a page view away.
+	clientHash := s.visitorHash(r)
+	var err error
+	if r.Method == http.MethodDelete {
+		_, err = s.db.Exec(`DELETE FROM submission_votes WHERE group_id = ? AND viewer_token = ? AND entry_id = ?`, group.ID, viewerToken, entry.ID)
+	} else {
+		var others int
+		err = s.db.QueryRow(`SELECT COUNT(*) FROM submission_votes WHERE group_id = ? AND client_hash = ? AND viewer_token <> ?`,
+			group.ID, clientHash, viewerToken).Scan(&others)
+		if err == nil && others > 0 {
+			writeJSONError(w, http.StatusConflict, "Z tego adresu oddano juz glos w tej grupie")
+			return
+		}
+		if err == nil {
+			_, err = s.db.Exec(`INSERT INTO submission_votes (group_id, entry_id, viewer_token, client_hash) VALUES (?, ?, ?, ?)
+				ON CONFLICT(group_id, viewer_token) DO UPDATE SET entry_id = excluded.entry_id, client_hash = excluded.client_hash, created_at = CURRENT_TIMESTAMP`,
+				group.ID, entry.ID, viewerToken, clientHash)
+		}
+	}
+	if err != nil {
+		log.Printf("submission vote: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac glosu")
+		return
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "glos")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "voted": r.Method == http.MethodPost})
+}
+
+// handleSubmissionScore stores the juror score of an entry:
+// POST /api/submissions/entries/<id>/score with {"score": 1-10}. A score of 0
+// removes it.
+func (s *Server) handleSubmissionScore(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+	if !group.Voting.Jury {
+		writeJSONError(w, http.StatusForbidden, "Ocena jury nie jest wlaczona w tej grupie")
+		return
+	}
+	if entry.Status != submissionStatusApproved {
+		writeJSONError(w, http.StatusConflict, "Oceniac mozna tylko zaakceptowane pliki")
+		return
+	}
+
+	var req struct {
+		Score int `json:"score"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+		return
+	}
+	var err error
+	switch {
+	case req.Score == 0:
+		_, err = s.db.Exec(`DELETE FROM submission_scores WHERE entry_id = ? AND juror = ?`, entry.ID, s.cfg.Username)
+	case req.Score < juryScoreMin || req.Score > juryScoreMax:
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Ocena musi byc liczba od %d do %d", juryScoreMin, juryScoreMax))
+		return
+	default:
+		_, err = s.db.Exec(`INSERT INTO submission_scores (entry_id, juror, score) VALUES (?, ?, ?)
+			ON CONFLICT(entry_id, juror) DO UPDATE SET score = excluded.score, updated_at = CURRENT_TIMESTAMP`,
+			entry.ID, s.cfg.Username, req.Score)
+	}
+	if err != nil {
+		log.Printf("submission score: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac oceny")
+		return
+	}
+
+	if s.logger != nil {
+		s.logger.Log(r, "ocena")
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "score": req.Score})
+}
+
+// handleSubmissionGroupResults returns the standings of a group:
+// GET /api/submissions/groups/<id>/results?format=json|csv.
+func (s *Server) handleSubmissionGroupResults(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		format = "json"
+	}
+	if format != "csv" && format != "json" {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowy format eksportu")
+		return
+	}
+	group, err := s.getSubmissionGroupByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Grupa nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac grupy")
+		return
+	}
+	results, err := s.submissionResults(group)
+	if err != nil {
+		log.Printf("submission results: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac wynikow")
+		return
+	}
+	if results == nil {
+		results = []submissionResultView{}
+	}
+
+	if format == "json" {
+		writeJSON(w, http.StatusOK, map[string]any{"results": results})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", "attachment; filename=\"wyniki-"+group.Slug+".csv\"")
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	io.WriteString(w, "\ufeff")
+	out := csv.NewWriter(w)
+	out.Write([]string{"miejsce", "id", "autor", "nazwa pliku", "glosy", "oceny jury", "srednia jury"})
+	for _, result := range results {
+		out.Write(csvRecord([]string{
+			strconv.Itoa(result.Rank),
+			strconv.FormatInt(result.ID, 10),
+			result.Author,
+			result.Original,
+			strconv.Itoa(result.Votes),
+			strconv.Itoa(result.Scores),
+			result.ScoreLabel,
+		}))
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("submission results: %v", err)
+	}
+}
+
+// handleSubmissionJudging routes /api/submissions/entries/<id>/vote and
+// /score.
+func (s *Server) handleSubmissionJudging(w http.ResponseWriter, r *http.Request, id int64, action string) {
+	entry, group, err := s.getSubmissionEntry(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Plik nie istnieje")
+			return
+		}
+		log.Printf("submission entry: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac pliku")
+		return
+	}
+	if action == "score" {
+		s.handleSubmissionScore(w, r, entry, group)
+		return
+	}
+	s.handleSubmissionVote(w, r, entry, group)
+}
@@ -0,0 +1,121 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	submissionStateUpcoming = "upcoming"
+	submissionStateOpen     = "open"
+	submissionStateClosed   = "closed"
+)
+
+var errSubmissionWindowInvalid = errors.New("nieprawidlowe daty przyjmowania zgloszen")
+
+// submissionWindow limits when a group accepts uploads. Both ends are
+// optional; a group without dates is always open.
+type submissionWindow struct {
+	OpensAt  sql.NullTime
+	ClosesAt sql.NullTime
+}
+
+type submissionWindowStatus struct {
+	SubmissionOpensAt        string `json:"opensAt,omitempty"`
+	SubmissionClosesAt       string `json:"closesAt,omitempty"`
+	SubmissionState          string `json:"submissionState"`
+	SubmissionMessage        string `json:"submissionMessage,omitempty"`
+	SubmissionCountdown      string `json:"submissionCountdown,omitempty"`
+	SubmissionCountdownLabel string `json:"submissionCountdownLabel,omitempty"`
+}
+
+func (w submissionWindow) state(now time.Time) string {
+	if w.OpensAt.Valid && now.Before(w.OpensAt.Time) {
+		return submissionStateUpcoming
+	}
+	if w.ClosesAt.Valid && !now.Before(w.ClosesAt.Time) {
+		return submissionStateClosed
+	}
+	return submissionStateOpen
+}
+
+func (w submissionWindow) status(now time.Time) submissionWindowStatus {
+	status := submissionWindowStatus{SubmissionState: w.state(now)}
+	if w.OpensAt.Valid {
+		status.SubmissionOpensAt = w.OpensAt.Time.UTC().Format(time.RFC3339)
+	}
+	if w.ClosesAt.Valid {
+		status.SubmissionClosesAt = w.ClosesAt.Time.UTC().Format(time.RFC3339)
+	}
+
+	switch status.SubmissionState {
+	case submissionStateUpcoming:
+		status.SubmissionMessage = "Przyjmowanie zgloszen rozpocznie sie " + w.OpensAt.Time.Local().Format("02.01.2006 15:04") + "."
+		status.SubmissionCountdown = status.SubmissionOpensAt
+		status.SubmissionCountdownLabel = "Do otwarcia"
+	case submissionStateClosed:
+		status.SubmissionMessage = "Przyjmowanie zgloszen zakonczylo sie " + w.ClosesAt.Time.Local().Format("02.01.2006 15:04") + "."
+	default:
+		if w.ClosesAt.Valid {
+			status.SubmissionMessage = "Zgloszenia przyjmujemy do " + w.ClosesAt.Time.Local().Format("02.01.2006 15:04") + "."
+			status.SubmissionCountdown = status.SubmissionClosesAt
+			status.SubmissionCountdownLabel = "Do zamkniecia"
+		}
+	}
+	return status
+}
+
+// uploadError returns the message shown to a visitor uploading outside the
+// window, or an empty string when uploads are accepted.
+func (w submissionWindow) uploadError(now time.Time) string {
+	switch w.state(now) {
+	case submissionStateUpcoming:
+		return "Przyjmowanie zgloszen jeszcze sie nie rozpoczelo"
+	case submissionStateClosed:
+		return "Przyjmowanie zgloszen zostalo zakonczone"
+	default:
+		return ""
+	}
+}
+
+func parseWindowTime(raw *string, current sql.NullTime) (sql.NullTime, error) {
+	if raw == nil {
+		return current, nil
+	}
+	value := strings.TrimSpace(*raw)
+	if value == "" {
+		return sql.NullTime{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return current, errSubmissionWindowInvalid
+	}
+	return sql.NullTime{Time: parsed.UTC(), Valid: true}, nil
+}
+
+// applySubmissionWindowRequest merges optional API fields into the current
+// window. A nil field leaves the date unchanged, an empty one clears it.
+func applySubmissionWindowRequest(current submissionWindow, opensAt, closesAt *string) (submissionWindow, error) {
+	var next submissionWindow
+	var err error
+	if next.OpensAt, err = parseWindowTime(opensAt, current.OpensAt); err != nil {
+		return current, err
+	}
+	if next.ClosesAt, err = parseWindowTime(closesAt, current.ClosesAt); err != nil {
+		return current, err
+	}
+	if next.OpensAt.Valid && next.ClosesAt.Valid && !next.ClosesAt.Time.After(next.OpensAt.Time) {
+		return current, errors.New("data zamkniecia musi byc pozniejsza niz data otwarcia")
+	}
+	return next, nil
+}
+
+func (s *Server) updateSubmissionGroupWindow(id int64, window submissionWindow) (*submissionGroupRecord, error) {
+	if _, err := s.db.Exec(`UPDATE submission_groups SET opens_at = ?, closes_at = ?, close_notified_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		window.OpensAt, window.ClosesAt, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
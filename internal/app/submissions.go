@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
@@ -13,6 +14,10 @@ import (
 	"github.com/dustin/go-humanize"
 )
 
+const submissionGroupColumns = `id, name, slug, path, visibility, shared_token, shared_views, shared_expires_at, shared_max_views, shared_password_hash, opens_at, closes_at,
+	max_file_size, allowed_types, max_files_per_contributor, max_group_size, min_image_width, min_image_height, form_fields, invite_only,
+	visitor_voting, jury_scoring, hide_results, comments_open`
+
 type submissionGroupRecord struct {
 	ID          int64
 	Name        string
@@ -21,6 +26,15 @@ type submissionGroupRecord struct {
 	Visibility  string
 	SharedToken sql.NullString
 	SharedViews int
+	ShareLimits shareLimits
+
+	SharedPasswordHash sql.NullString
+	Window             submissionWindow
+	Rules              submissionRules
+	Fields             []submissionField
+	InviteOnly         bool
+	Voting             submissionVoting
+	CommentsOpen       bool
 }
 
 type submissionGroupView struct {
@@ -31,6 +45,17 @@ type submissionGroupView struct {
 	SharedToken string `json:"sharedToken,omitempty"`
 	SharedViews int    `json:"sharedViews"`
 	ShareURL    string `json:"shareUrl,omitempty"`
+	shareStatus
+	PasswordProtected bool `json:"passwordProtected"`
+	submissionWindowStatus
+	Rules         submissionRulesView `json:"rules"`
+	Fields        []submissionField   `json:"fields"`
+	PendingCount  int                 `json:"pendingCount,omitempty"`
+	InviteOnly    bool                `json:"inviteOnly"`
+	VisitorVoting bool                `json:"visitorVoting"`
+	JuryScoring   bool                `json:"juryScoring"`
+	HideResults   bool                `json:"hideResults"`
+	CommentsOpen  bool                `json:"commentsOpen"`
 }
 
 type submissionEntryRecord struct {
@@ -43,6 +68,18 @@ type submissionEntryRecord struct {
 	SizeBytes        int64
 	CreatedAt        time.Time
 	ContributorToken string
+	Status           string
+	ReviewNote       sql.NullString
+	ReviewedAt       sql.NullTime
+
+	PublishedFolderID sql.NullInt64
+	PublishedImage    sql.NullString
+	FieldValues       sql.NullString
+	ScanStatus        sql.NullString
+	ScanSignature     sql.NullString
+	PDFPages          sql.NullInt64
+	PDFTitle          sql.NullString
+	PDFAuthor         sql.NullString
 }
 
 type submissionEntryView struct {
@@ -57,6 +94,24 @@ type submissionEntryView struct {
 	UploadedAt  string
 	IsImage     bool
 	IsPDF       bool
+	Status      string
+	StatusLabel string
+	ReviewNote  string
+	ReviewedAt  string
+	Own         bool
+	Published   bool
+	Fields      []submissionFieldValue
+	ScanStatus  string
+	ScanLabel   string
+	PDFPages    int
+	PDFTitle    string
+	PDFAuthor   string
+	PreviewURL  string
+	ViewerPages int
+	Votes       int
+	Voted       bool
+	JuryScore   int
+	Comments    int
 }
 
 func (g submissionGroupRecord) toView(baseURL string) submissionGroupView {
@@ -66,6 +121,20 @@ func (g submissionGroupRecord) toView(baseURL string) submissionGroupView {
 		Slug:        g.Slug,
 		Visibility:  g.Visibility,
 		SharedViews: g.SharedViews,
+		shareStatus: g.ShareLimits.status(time.Now(), g.SharedViews),
+
+		PasswordProtected:      g.SharedPasswordHash.Valid,
+		submissionWindowStatus: g.Window.status(time.Now()),
+		Rules:                  g.Rules.toView(),
+		Fields:                 g.Fields,
+		InviteOnly:             g.InviteOnly,
+		VisitorVoting:          g.Voting.Visitors,
+		JuryScoring:            g.Voting.Jury,
+		HideResults:            g.Voting.HideResults,
+		CommentsOpen:           g.CommentsOpen,
+	}
+	if view.Fields == nil {
+		view.Fields = []submissionField{}
 	}
 	if g.SharedToken.Valid && g.SharedToken.String != "" {
 		view.SharedToken = g.SharedToken.String
@@ -84,6 +153,21 @@ func (g submissionGroupRecord) toView(baseURL string) submissionGroupView {
 	return view
 }
 
+func scanSubmissionGroup(row rowScanner) (*submissionGroupRecord, error) {
+	var rec submissionGroupRecord
+	var fields sql.NullString
+	err := row.Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews,
+		&rec.ShareLimits.ExpiresAt, &rec.ShareLimits.MaxViews, &rec.SharedPasswordHash, &rec.Window.OpensAt, &rec.Window.ClosesAt,
+		&rec.Rules.MaxFileSize, &rec.Rules.AllowedTypes, &rec.Rules.MaxFilesPerContributor, &rec.Rules.MaxGroupSize,
+		&rec.Rules.MinImageWidth, &rec.Rules.MinImageHeight, &fields, &rec.InviteOnly,
+		&rec.Voting.Visitors, &rec.Voting.Jury, &rec.Voting.HideResults, &rec.CommentsOpen)
+	if err != nil {
+		return nil, err
+	}
+	rec.Fields = parseSubmissionFields(fields)
+	return &rec, nil
+}
+
 func (s *Server) submissionsRoot() string {
 	return s.submissionsDir
 }
@@ -142,7 +226,7 @@ func (s *Server) createSubmissionGroup(name string) (*submissionGroupRecord, err
 }
 
 func (s *Server) listSubmissionGroups(loggedIn bool) ([]submissionGroupRecord, error) {
-	query := `SELECT id, name, slug, path, visibility, shared_token, shared_views FROM submission_groups`
+	query := `SELECT ` + submissionGroupColumns + ` FROM submission_groups`
 	var args []any
 	if !loggedIn {
 		query += ` WHERE visibility = ?`
@@ -158,11 +242,11 @@ func (s *Server) listSubmissionGroups(loggedIn bool) ([]submissionGroupRecord, e
 
 	var groups []submissionGroupRecord
 	for rows.Next() {
-		var rec submissionGroupRecord
-		if err := rows.Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews); err != nil {
+		rec, err := scanSubmissionGroup(rows)
+		if err != nil {
 			return nil, err
 		}
-		groups = append(groups, rec)
+		groups = append(groups, *rec)
 	}
 	return groups, rows.Err()
 }
@@ -183,33 +267,15 @@ func (s *Server) submissionGroupSlugTaken(slug string, excludeID int64) (bool, e
 }
 
 func (s *Server) getSubmissionGroupBySlug(slug string) (*submissionGroupRecord, error) {
-	var rec submissionGroupRecord
-	err := s.db.QueryRow(`SELECT id, name, slug, path, visibility, shared_token, shared_views FROM submission_groups WHERE slug = ?`, slug).
-		Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews)
-	if err != nil {
-		return nil, err
-	}
-	return &rec, nil
+	return scanSubmissionGroup(s.db.QueryRow(`SELECT `+submissionGroupColumns+` FROM submission_groups WHERE slug = ?`, slug))
 }
 
 func (s *Server) getSubmissionGroupByID(id int64) (*submissionGroupRecord, error) {
-	var rec submissionGroupRecord
-	err := s.db.QueryRow(`SELECT id, name, slug, path, visibility, shared_token, shared_views FROM submission_groups WHERE id = ?`, id).
-		Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews)
-	if err != nil {
-		return nil, err
-	}
-	return &rec, nil
+	return scanSubmissionGroup(s.db.QueryRow(`SELECT `+submissionGroupColumns+` FROM submission_groups WHERE id = ?`, id))
 }
 
 func (s *Server) getSubmissionGroupByToken(token string) (*submissionGroupRecord, error) {
-	var rec submissionGroupRecord
-	err := s.db.QueryRow(`SELECT id, name, slug, path, visibility, shared_token, shared_views FROM submission_groups WHERE shared_token = ?`, token).
-		Scan(&rec.ID, &rec.Name, &rec.Slug, &rec.Path, &rec.Visibility, &rec.SharedToken, &rec.SharedViews)
-	if err != nil {
-		return nil, err
-	}
-	return &rec, nil
+	return scanSubmissionGroup(s.db.QueryRow(`SELECT `+submissionGroupColumns+` FROM submission_groups WHERE shared_token = ?`, token))
 }
 
 func (s *Server) updateSubmissionGroupVisibility(id int64, visibility string) (*submissionGroupRecord, error) {
@@ -261,6 +327,21 @@ func (s *Server) regenerateSubmissionSharedToken(id int64) (*submissionGroupReco
 	return s.getSubmissionGroupByID(id)
 }
 
+func (s *Server) updateSubmissionGroupShareLimits(id int64, limits shareLimits) (*submissionGroupRecord, error) {
+	if _, err := s.db.Exec(`UPDATE submission_groups SET shared_expires_at = ?, shared_max_views = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`,
+		limits.ExpiresAt, limits.MaxViews, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
+func (s *Server) updateSubmissionGroupSharePassword(id int64, passwordHash sql.NullString) (*submissionGroupRecord, error) {
+	if _, err := s.db.Exec(`UPDATE submission_groups SET shared_password_hash = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, passwordHash, id); err != nil {
+		return nil, err
+	}
+	return s.getSubmissionGroupByID(id)
+}
+
 func (s *Server) deleteSubmissionGroup(id int64) error {
 	group, err := s.getSubmissionGroupByID(id)
 	if err != nil {
@@ -272,6 +353,36 @@ func (s *Server) deleteSubmissionGroup(id int64) error {
 			return err
 		}
 	}
+	if err := s.deleteShareLinks(shareKindGroup, id); err != nil {
+		return err
+	}
+	if err := s.removeGroupPreviews(id); err != nil {
+		return err
+	}
+	if err := s.deleteShareAnalytics(shareKindGroup, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_changes WHERE group_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_receipts WHERE group_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_invites WHERE group_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_scores WHERE entry_id IN (SELECT id FROM submissions WHERE group_id = ?)`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM submission_votes WHERE group_id = ?`, id); err != nil {
+		return err
+	}
+	if _, err := s.db.Exec(`DELETE FROM comments WHERE entry_id IN (SELECT id FROM submissions WHERE group_id = ?)`, id); err != nil {
+		return err
+	}
+	if err := s.deleteGroupWebhooks(id); err != nil {
+		return err
+	}
 	_, err = s.db.Exec(`DELETE FROM submission_groups WHERE id = ?`, id)
 	return err
 }
@@ -341,17 +452,49 @@ func (s *Server) incrementSubmissionSharedViews(id int64) error {
 	return err
 }
 
-func (s *Server) submissionEntriesForGroup(group *submissionGroupRecord, viewerToken string, loggedIn bool) ([]submissionEntryView, error) {
+const submissionEntryColumns = `id, group_id, filename, original_name, uploader_name, mime_type, size_bytes, created_at, contributor_token,
+	status, review_note, reviewed_at, published_folder_id, published_image, field_values, scan_status, scan_signature,
+	pdf_pages, pdf_title, pdf_author`
+
+func scanSubmissionEntry(row rowScanner) (*submissionEntryRecord, error) {
+	var rec submissionEntryRecord
+	err := row.Scan(&rec.ID, &rec.GroupID, &rec.FileName, &rec.OriginalName, &rec.UploaderName, &rec.MimeType, &rec.SizeBytes,
+		&rec.CreatedAt, &rec.ContributorToken, &rec.Status, &rec.ReviewNote, &rec.ReviewedAt,
+		&rec.PublishedFolderID, &rec.PublishedImage, &rec.FieldValues, &rec.ScanStatus, &rec.ScanSignature,
+		&rec.PDFPages, &rec.PDFTitle, &rec.PDFAuthor)
+	if err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// visibleTo reports whether an anonymous visitor may see the entry: public
+// groups show approved entries to everyone and pending or rejected ones only
+// to their contributor.
+func (e submissionEntryRecord) visibleTo(viewerToken string) bool {
+	return e.Status == submissionStatusApproved || (viewerToken != "" && viewerToken == e.ContributorToken)
+}
+
+// submissionEntriesForGroup lists the entries the viewer may see. Admins can
+// narrow the list to one moderation status; an empty status lists all.
+func (s *Server) submissionEntriesForGroup(group *submissionGroupRecord, viewerToken string, loggedIn bool, status string) ([]submissionEntryView, error) {
 	if group == nil {
 		return nil, nil
 	}
-	query := `SELECT id, group_id, filename, original_name, uploader_name, mime_type, size_bytes, created_at, contributor_token
-		FROM submissions WHERE group_id = ?`
+	query := `SELECT ` + submissionEntryColumns + ` FROM submissions WHERE group_id = ?`
 	var args []any
 	args = append(args, group.ID)
-	if !loggedIn && group.Visibility == visibilityShared {
-		query += ` AND contributor_token = ?`
-		args = append(args, viewerToken)
+	if !loggedIn {
+		if group.Visibility != visibilityPublic {
+			query += ` AND contributor_token = ?`
+			args = append(args, viewerToken)
+		} else {
+			query += ` AND (status = ? OR contributor_token = ?)`
+			args = append(args, submissionStatusApproved, viewerToken)
+		}
+	} else if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
 	}
 	query += ` ORDER BY created_at DESC`
 
@@ -363,8 +506,8 @@ func (s *Server) submissionEntriesForGroup(group *submissionGroupRecord, viewerT
 
 	var entries []submissionEntryView
 	for rows.Next() {
-		var rec submissionEntryRecord
-		if err := rows.Scan(&rec.ID, &rec.GroupID, &rec.FileName, &rec.OriginalName, &rec.UploaderName, &rec.MimeType, &rec.SizeBytes, &rec.CreatedAt, &rec.ContributorToken); err != nil {
+		rec, err := scanSubmissionEntry(rows)
+		if err != nil {
 			return nil, err
 		}
 		url := fmt.Sprintf("/submitted/file/%d", rec.ID)
@@ -379,7 +522,30 @@ func (s *Server) submissionEntriesForGroup(group *submissionGroupRecord, viewerT
 			UploadedBy:  rec.UploaderName,
 			UploadedAt:  rec.CreatedAt.Format("02.01.2006 15:04"),
 			IsImage:     strings.HasPrefix(strings.ToLower(rec.MimeType.String), "image/") || isImageFile(rec.OriginalName),
-			IsPDF:       strings.EqualFold(filepath.Ext(rec.OriginalName), ".pdf"),
+			IsPDF:       rec.isPDF(),
+			Status:      rec.Status,
+			StatusLabel: submissionStatusLabels[rec.Status],
+			Own:         viewerToken != "" && rec.ContributorToken == viewerToken,
+			Published:   rec.PublishedImage.Valid,
+		}
+		if entry.IsPDF && rec.ScanStatus.String != scanStatusInfected && (!rec.PDFPages.Valid || rec.PDFPages.Int64 > 0) {
+			entry.PreviewURL = url + "/preview"
+			entry.PDFPages = int(rec.PDFPages.Int64)
+			entry.PDFTitle = rec.PDFTitle.String
+			entry.PDFAuthor = rec.PDFAuthor.String
+			entry.ViewerPages = min(entry.PDFPages, pdfViewerMaxPages)
+		}
+		if loggedIn {
+			entry.Fields = submissionFieldValues(group.Fields, rec.FieldValues)
+			entry.ScanStatus = rec.ScanStatus.String
+			entry.ScanLabel = scanStatusLabels[rec.ScanStatus.String]
+			if rec.ScanSignature.Valid {
+				entry.ScanLabel += ": " + rec.ScanSignature.String
+			}
+			entry.ReviewNote = rec.ReviewNote.String
+			if rec.ReviewedAt.Valid {
+				entry.ReviewedAt = rec.ReviewedAt.Time.Local().Format("02.01.2006 15:04")
+			}
 		}
 		entries = append(entries, entry)
 	}
@@ -387,19 +553,7 @@ func (s *Server) submissionEntriesForGroup(group *submissionGroupRecord, viewerT
 }
 
 func (s *Server) getSubmissionEntry(id int64) (*submissionEntryRecord, *submissionGroupRecord, error) {
-	var rec submissionEntryRecord
-	err := s.db.QueryRow(`SELECT id, group_id, filename, original_name, uploader_name, mime_type, size_bytes, created_at, contributor_token
-		FROM submissions WHERE id = ?`, id).Scan(
-		&rec.ID,
-		&rec.GroupID,
-		&rec.FileName,
-		&rec.OriginalName,
-		&rec.UploaderName,
-		&rec.MimeType,
-		&rec.SizeBytes,
-		&rec.CreatedAt,
-		&rec.ContributorToken,
-	)
+	rec, err := scanSubmissionEntry(s.db.QueryRow(`SELECT `+submissionEntryColumns+` FROM submissions WHERE id = ?`, id))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -407,7 +561,19 @@ func (s *Server) getSubmissionEntry(id int64) (*submissionEntryRecord, *submissi
 	if err != nil {
 		return nil, nil, err
 	}
-	return &rec, group, nil
+	return rec, group, nil
+}
+
+// linkShare adds the share token to the file URLs, so the file handler can
+// apply the permissions of the link the page was opened from.
+func (e *submissionEntryView) linkShare(token string) {
+	base := fmt.Sprintf("/submitted/file/%d", e.ID)
+	query := "?share=" + url.QueryEscape(token)
+	e.URL = base + query
+	e.DownloadURL = base + query + "&download=1"
+	if e.PreviewURL != "" {
+		e.PreviewURL = base + "/preview" + query
+	}
 }
 
 func submissionViewerTokenFromRequest(r *http.Request) string {
@@ -430,14 +596,18 @@ func (s *Server) ensureSubmissionViewerToken(w http.ResponseWriter, r *http.Requ
 	if err != nil {
 		newToken = fmt.Sprintf("%d", time.Now().UnixNano())
 	}
+	setSubmissionViewerCookie(w, newToken)
+	return newToken
+}
+
+func setSubmissionViewerCookie(w http.ResponseWriter, token string) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     submissionViewerCookie,
-		Value:    newToken,
+		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteLaxMode,
 		Expires:  time.Now().Add(30 * 24 * time.Hour),
 		MaxAge:   int((30 * 24 * time.Hour).Seconds()),
 	})
-	return newToken
 }
@@ -6,11 +6,13 @@ import (
 	"errors"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func (s *Server) handleSubmittedRoutes(w http.ResponseWriter, r *http.Request) {
@@ -27,6 +29,11 @@ func (s *Server) handleSubmittedRoutes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if strings.HasPrefix(path, "invite/") {
+		s.renderSubmissionInvite(w, r, strings.Trim(strings.TrimPrefix(path, "invite/"), "/"))
+		return
+	}
+
 	if strings.HasPrefix(path, "shared/") {
 		token := strings.Trim(strings.TrimPrefix(path, "shared/"), "/")
 		if token == "" {
@@ -60,8 +67,9 @@ func (s *Server) renderSubmissionPublic(w http.ResponseWriter, r *http.Request,
 
 	viewerToken := s.ensureSubmissionViewerToken(w, r)
 	baseURL := requestBaseURL(r)
+	invite := s.submissionInviteFor(group.ID, viewerToken)
 
-	entries, err := s.submissionEntriesForGroup(group, viewerToken, loggedIn)
+	entries, err := s.submissionEntriesForGroup(group, viewerToken, loggedIn, "")
 	if err != nil {
 		log.Printf("list submissions: %v", err)
 		http.Error(w, "failed to load submissions", http.StatusInternalServerError)
@@ -77,37 +85,74 @@ func (s *Server) renderSubmissionPublic(w http.ResponseWriter, r *http.Request,
 		SubmissionEntries:         entries,
 		SubmissionSharedMode:      false,
 		AllowSubmissionManagement: loggedIn,
-		AllowSubmissionUpload:     loggedIn || group.Visibility == visibilityPublic,
+		AllowSubmissionUpload:     loggedIn || (group.Visibility == visibilityPublic && group.Window.uploadError(time.Now()) == "" && (!group.InviteOnly || invite != nil)),
 		SubmissionShareLink:       view.ShareURL,
 		SubmissionUploadLimit:     int(submissionUploadMaxSize >> 20),
+		SubmissionReceipt:         s.existingSubmissionReceipt(group.ID, viewerToken),
+		Meta:                      submissionGroupPageMeta(r, group.Name, group.Visibility == visibilityPublic),
+	}
+	if invite != nil {
+		inviteView := invite.toView(baseURL)
+		data.SubmissionInvite = &inviteView
 	}
+	s.applySubmissionVoting(w, &data, group, viewerToken, loggedIn)
+	s.applySubmissionComments(&data, group, loggedIn)
 
 	s.renderPage(w, data)
 }
 
 func (s *Server) renderSubmissionShared(w http.ResponseWriter, r *http.Request, token string) {
-	group, err := s.getSubmissionGroupByToken(token)
-	if err != nil || group.Visibility != visibilityShared {
+	group, access, err := s.resolveSubmissionShare(token)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
+	visit, err := s.newShareVisit(r, shareKindGroup, group.ID, access)
+	if err != nil {
+		log.Printf("share visit: %v", err)
+		http.Error(w, "failed to load share", http.StatusInternalServerError)
+		return
+	}
+
+	if access.blocked(time.Now(), visit.newViews()) {
+		s.renderShareExpired(w, r, "submitted")
+		return
+	}
+
+	if !s.submissionShareUnlocked(r, group) {
+		s.renderSharePasswordPrompt(w, r, "submitted", shareKindGroup, token)
+		return
+	}
+
 	loggedIn := s.sessions.authenticated(w, r)
 	viewerToken := s.ensureSubmissionViewerToken(w, r)
 	baseURL := requestBaseURL(r)
+	invite := s.submissionInviteFor(group.ID, viewerToken)
 
-	if err := s.incrementSubmissionSharedViews(group.ID); err != nil {
+	if err := s.recordShareVisit(visit, access); err != nil {
 		log.Printf("submission shared view: %v", err)
 	}
 
-	entries, err := s.submissionEntriesForGroup(group, viewerToken, loggedIn)
-	if err != nil {
-		log.Printf("list submissions: %v", err)
-		http.Error(w, "failed to load submissions", http.StatusInternalServerError)
-		return
+	var entries []submissionEntryView
+	if access.Permissions.View {
+		entries, err = s.submissionEntriesForGroup(group, viewerToken, loggedIn, "")
+		if err != nil {
+			log.Printf("list submissions: %v", err)
+			http.Error(w, "failed to load submissions", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if !loggedIn {
+		for i := range entries {
+			entries[i].linkShare(access.Token)
+		}
 	}
 
 	view := group.toView(baseURL)
+	view.SharedToken = access.Token
+	view.ShareURL = shareLinkURL(baseURL, shareKindGroup, access.Token)
 	data := pageData{
 		View:                      "submitted",
 		BaseURL:                   baseURL,
@@ -115,9 +160,19 @@ func (s *Server) renderSubmissionShared(w http.ResponseWriter, r *http.Request,
 		SubmissionEntries:         entries,
 		SubmissionSharedMode:      true,
 		AllowSubmissionManagement: loggedIn,
-		AllowSubmissionUpload:     true,
+		AllowSubmissionUpload:     access.Permissions.Upload && (loggedIn || (group.Window.uploadError(time.Now()) == "" && (!group.InviteOnly || invite != nil))),
 		SubmissionShareLink:       view.ShareURL,
 		SubmissionUploadLimit:     int(submissionUploadMaxSize >> 20),
+		SubmissionReceipt:         s.existingSubmissionReceipt(group.ID, viewerToken),
+		ShareToken:                access.Token,
+		ShareAllowView:            access.Permissions.View,
+		ShareAllowDownload:        access.Permissions.Download,
+		ShareAllowUpload:          access.Permissions.Upload,
+		Meta:                      submissionGroupPageMeta(r, group.Name, access.Permissions.Upload),
+	}
+	if invite != nil {
+		inviteView := invite.toView(baseURL)
+		data.SubmissionInvite = &inviteView
 	}
 
 	s.renderPage(w, data)
@@ -133,20 +188,32 @@ func (s *Server) handleSubmissionUpload(w http.ResponseWriter, r *http.Request)
 	viewerToken := s.ensureSubmissionViewerToken(w, r)
 	loggedIn := s.sessions.authenticated(w, r)
 
-	r.Body = http.MaxBytesReader(w, r.Body, submissionUploadMaxSize)
+	// The body is read before the group field is known, so the group's own
+	// file size limit only applies when the upload URL names the group.
+	routedGroup := sanitizeFilename(r.URL.Query().Get("group"))
+	r.Body = http.MaxBytesReader(w, r.Body, s.submissionBodyLimit(routedGroup))
 	if err := r.ParseMultipartForm(submissionUploadMaxSize); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeJSONError(w, http.StatusRequestEntityTooLarge, "Plik jest za duzy")
+			return
+		}
 		writeJSONError(w, http.StatusBadRequest, "Nie udalo sie odczytac pliku")
 		return
 	}
 
-	groupSlug := sanitizeFilename(r.FormValue("group"))
+	groupSlug := sanitizeFilename(r.PostFormValue("group"))
 	uploader := strings.TrimSpace(r.FormValue("name"))
 	token := strings.TrimSpace(r.FormValue("token"))
 
-	if groupSlug == "" || uploader == "" {
+	if groupSlug == "" {
 		writeJSONError(w, http.StatusBadRequest, "Podaj nazwe grupy i swoje imie")
 		return
 	}
+	if routedGroup != "" && routedGroup != groupSlug {
+		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowa grupa")
+		return
+	}
 
 	group, err := s.getSubmissionGroupBySlug(groupSlug)
 	if err != nil {
@@ -158,15 +225,63 @@ func (s *Server) handleSubmissionUpload(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if group.Visibility == visibilityPrivate && !loggedIn {
+	// Invitees upload under their invited name and their link stands in for
+	// the group's own share link. Admins always upload as themselves.
+	var invite *submissionInviteRecord
+	if !loggedIn {
+		invite = s.submissionInviteFor(group.ID, viewerToken)
+	}
+	if invite != nil {
+		uploader = invite.Name
+	}
+	if uploader == "" {
+		writeJSONError(w, http.StatusBadRequest, "Podaj nazwe grupy i swoje imie")
+		return
+	}
+
+	if !loggedIn && invite == nil && group.InviteOnly {
+		writeJSONError(w, http.StatusForbidden, "Ta grupa przyjmuje pliki tylko od zaproszonych osob")
+		return
+	}
+	if group.Visibility == visibilityPrivate && !loggedIn && invite == nil {
 		writeJSONError(w, http.StatusForbidden, "Ta grupa jest prywatna")
 		return
 	}
-	if group.Visibility == visibilityShared && !loggedIn {
-		if !group.SharedToken.Valid || token == "" || token != group.SharedToken.String {
+	if group.Visibility == visibilityShared && !loggedIn && invite == nil {
+		if token == "" {
 			writeJSONError(w, http.StatusForbidden, "Ten link nie jest aktywny")
 			return
 		}
+		linkedGroup, access, err := s.resolveSubmissionShare(token)
+		if err != nil || linkedGroup.ID != group.ID || !access.Permissions.Upload {
+			writeJSONError(w, http.StatusForbidden, "Ten link nie jest aktywny")
+			return
+		}
+		if access.blocked(time.Now(), 0) {
+			writeJSONError(w, http.StatusForbidden, "Ten link wygasl")
+			return
+		}
+		if !s.submissionShareUnlocked(r, group) {
+			writeJSONError(w, http.StatusUnauthorized, "Link wymaga hasla")
+			return
+		}
+	}
+	if !loggedIn {
+		if message := group.Window.uploadError(time.Now()); message != "" {
+			writeJSONError(w, http.StatusForbidden, message)
+			return
+		}
+	}
+
+	answers, message := readSubmissionFields(group.Fields, r.MultipartForm.Value)
+	if message != "" {
+		writeJSONError(w, http.StatusBadRequest, message)
+		return
+	}
+	fieldValues, err := encodeSubmissionFieldValues(answers)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac odpowiedzi")
+		return
 	}
 
 	file, header, err := r.FormFile("file")
@@ -176,50 +291,40 @@ func (s *Server) handleSubmissionUpload(w http.ResponseWriter, r *http.Request)
 	}
 	defer file.Close()
 
-	filename := sanitizeFilename(header.Filename)
-	if filename == "" {
-		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowa nazwa pliku")
+	filename, message := submissionUploadName(header)
+	if message != "" {
+		writeJSONError(w, http.StatusBadRequest, message)
 		return
 	}
-
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if ext == "" {
-		ext = strings.ToLower(filepath.Ext(filename))
-	}
-	if ext == "" {
-		writeJSONError(w, http.StatusBadRequest, "Plik musi miec rozszerzenie")
+	message, err = s.checkSubmissionRules(group, viewerToken, filename, header.Size, file, 0)
+	if err != nil {
+		log.Printf("submission rules: %v", err)
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie sprawdzic pliku")
 		return
 	}
-	if filepath.Ext(filename) == "" {
-		filename += ext
-	}
-
-	if !isSubmissionFile(filename) {
-		writeJSONError(w, http.StatusBadRequest, "Dozwolone sa tylko obrazy lub PDF")
+	if message != "" {
+		writeJSONError(w, http.StatusBadRequest, message)
 		return
 	}
 
-	if err := s.ensureSubmissionDir(group); err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie przygotowac katalogu")
-		return
+	status := submissionStatusPending
+	if loggedIn {
+		status = submissionStatusApproved
 	}
 
-	targetDir := s.submissionGroupDir(group)
-	target, err := uniqueFilename(targetDir, filename)
-	if err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac pliku")
+	scanStatus, signature := s.scanUpload(file)
+	if scanStatus == scanStatusInfected {
+		s.quarantineSubmission(r, group, uploader, viewerToken, header, file, signature)
+		writeJSONError(w, http.StatusUnprocessableEntity, "Plik zostal zablokowany przez skaner antywirusowy")
 		return
 	}
-
-	dst, err := os.Create(target)
-	if err != nil {
-		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac pliku")
-		return
+	if scanStatus == scanStatusFailed {
+		status = submissionStatusPending
 	}
-	defer dst.Close()
 
-	written, err := io.Copy(dst, file)
+	stored, written, err := s.storeSubmissionFile(group, filename, file)
 	if err != nil {
+		log.Printf("store submission: %v", err)
 		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac pliku")
 		return
 	}
@@ -229,24 +334,88 @@ func (s *Server) handleSubmissionUpload(w http.ResponseWriter, r *http.Request)
 		mimeType = "application/octet-stream"
 	}
 
-	result, err := s.db.Exec(`INSERT INTO submissions (group_id, uploader_name, contributor_token, filename, original_name, mime_type, size_bytes) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-		group.ID, uploader, viewerToken, filepath.Base(target), header.Filename, mimeType, written)
+	result, err := s.db.Exec(`INSERT INTO submissions (group_id, uploader_name, contributor_token, filename, original_name, mime_type, size_bytes, status, field_values, scan_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		group.ID, uploader, viewerToken, stored, header.Filename, mimeType, written, status, fieldValues, sql.NullString{String: scanStatus, Valid: scanStatus != ""})
 	if err != nil {
 		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zgłoszenia")
 		return
 	}
 	id, _ := result.LastInsertId()
+	if entry, _, err := s.getSubmissionEntry(id); err == nil {
+		if entry.isPDF() {
+			s.queuePDFMetadata(entry, group)
+		}
+		s.queueWebhookEvent(webhookEventSubmissionCreated, group, entry)
+	}
+
+	receipt, err := s.submissionReceipt(group.ID, viewerToken)
+	if err != nil {
+		log.Printf("submission receipt: %v", err)
+	}
 
 	if s.logger != nil {
 		s.logger.Log(r, "przeslane")
 	}
 
 	writeJSON(w, http.StatusCreated, map[string]any{
-		"status": "ok",
-		"id":     id,
+		"status":     "ok",
+		"id":         id,
+		"moderation": status,
+		"receipt":    receipt,
 	})
 }
 
+// submissionUploadName validates the name of an uploaded file and returns the
+// name to store it under, or a message for the contributor.
+func submissionUploadName(header *multipart.FileHeader) (string, string) {
+	filename := sanitizeFilename(header.Filename)
+	if filename == "" {
+		return "", "Nieprawidlowa nazwa pliku"
+	}
+
+	ext := strings.ToLower(filepath.Ext(header.Filename))
+	if ext == "" {
+		ext = strings.ToLower(filepath.Ext(filename))
+	}
+	if ext == "" {
+		return "", "Plik musi miec rozszerzenie"
+	}
+	if filepath.Ext(filename) == "" {
+		filename += ext
+	}
+
+	if !isSubmissionFile(filename) {
+		return "", "Dozwolone sa tylko obrazy lub PDF"
+	}
+	return filename, ""
+}
+
+// storeSubmissionFile writes an upload into the group directory and returns
+// the stored name and size.
+func (s *Server) storeSubmissionFile(group *submissionGroupRecord, filename string, file io.Reader) (string, int64, error) {
+	if err := s.ensureSubmissionDir(group); err != nil {
+		return "", 0, err
+	}
+	target, err := uniqueFilename(s.submissionGroupDir(group), filename)
+	if err != nil {
+		return "", 0, err
+	}
+	dst, err := os.Create(target)
+	if err != nil {
+		return "", 0, err
+	}
+	written, err := io.Copy(dst, file)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(target)
+		return "", 0, err
+	}
+	return filepath.Base(target), written, nil
+}
+
 func (s *Server) handleSubmissionGroups(w http.ResponseWriter, r *http.Request) {
 	if !s.sessions.authenticated(w, r) {
 		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
@@ -299,12 +468,50 @@ func (s *Server) handleSubmissionGroupByID(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	id, err := strconv.ParseInt(path, 10, 64)
+	parts := strings.Split(path, "/")
+	id, err := strconv.ParseInt(parts[0], 10, 64)
 	if err != nil {
 		writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe ID grupy")
 		return
 	}
 
+	if len(parts) == 2 && parts[1] == "links" {
+		s.handleTargetShareLinks(w, r, shareKindGroup, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "qr" {
+		s.handleSubmissionGroupQR(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "poster" {
+		s.handleSubmissionGroupPoster(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "changes" {
+		s.handleSubmissionGroupChanges(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "export" {
+		s.handleSubmissionGroupExport(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "archive" {
+		s.handleSubmissionGroupArchive(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "invites" {
+		s.handleSubmissionGroupInvites(w, r, id)
+		return
+	}
+	if len(parts) == 2 && parts[1] == "results" {
+		s.handleSubmissionGroupResults(w, r, id)
+		return
+	}
+	if len(parts) > 1 {
+		http.NotFound(w, r)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
 		group, err := s.getSubmissionGroupByID(id)
@@ -319,9 +526,23 @@ func (s *Server) handleSubmissionGroupByID(w http.ResponseWriter, r *http.Reques
 		writeJSON(w, http.StatusOK, group.toView(requestBaseURL(r)))
 	case http.MethodPatch:
 		var req struct {
-			Name           string `json:"name"`
-			Visibility     string `json:"visibility"`
-			RegenerateLink bool   `json:"regenerateLink"`
+			Name            string                  `json:"name"`
+			Visibility      string                  `json:"visibility"`
+			RegenerateLink  bool                    `json:"regenerateLink"`
+			SharedExpiresAt *string                 `json:"sharedExpiresAt"`
+			SharedMaxViews  *int64                  `json:"sharedMaxViews"`
+			SharedPassword  *string                 `json:"sharedPassword"`
+			OpensAt         *string                 `json:"opensAt"`
+			ClosesAt        *string                 `json:"closesAt"`
+			Rules           *submissionRulesRequest `json:"rules"`
+			Fields          *[]submissionField      `json:"fields"`
+			InviteOnly      *bool                   `json:"inviteOnly"`
+			Voting          *struct {
+				Visitors    bool `json:"visitors"`
+				Jury        bool `json:"jury"`
+				HideResults bool `json:"hideResults"`
+			} `json:"voting"`
+			CommentsOpen *bool `json:"commentsOpen"`
 		}
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
@@ -353,6 +574,95 @@ func (s *Server) handleSubmissionGroupByID(w http.ResponseWriter, r *http.Reques
 			}
 		}
 
+		if req.SharedExpiresAt != nil || req.SharedMaxViews != nil {
+			limits, err := applyShareLimitsRequest(group.ShareLimits, req.SharedExpiresAt, req.SharedMaxViews)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			group, err = s.updateSubmissionGroupShareLimits(id, limits)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac ograniczen linku")
+				return
+			}
+		}
+
+		if req.OpensAt != nil || req.ClosesAt != nil {
+			window, err := applySubmissionWindowRequest(group.Window, req.OpensAt, req.ClosesAt)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			group, err = s.updateSubmissionGroupWindow(id, window)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac terminow")
+				return
+			}
+		}
+
+		if req.Rules != nil {
+			rules, err := req.Rules.rules()
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			group, err = s.updateSubmissionGroupRules(id, rules)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zasad przesylania")
+				return
+			}
+		}
+
+		if req.Fields != nil {
+			fields, err := normalizeSubmissionFields(*req.Fields)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			group, err = s.updateSubmissionGroupFields(id, fields)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac pol formularza")
+				return
+			}
+		}
+
+		if req.InviteOnly != nil && *req.InviteOnly != group.InviteOnly {
+			group, err = s.updateSubmissionGroupInviteOnly(id, *req.InviteOnly)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac ustawien zaproszen")
+				return
+			}
+		}
+
+		if req.Voting != nil {
+			group, err = s.updateSubmissionGroupVoting(id, submissionVoting(*req.Voting))
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac ustawien glosowania")
+				return
+			}
+		}
+
+		if req.CommentsOpen != nil {
+			group, err = s.updateSubmissionGroupCommentsOpen(id, *req.CommentsOpen)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac ustawien komentarzy")
+				return
+			}
+		}
+
+		if req.SharedPassword != nil {
+			passwordHash, err := sharePasswordUpdate(*req.SharedPassword)
+			if err != nil {
+				writeJSONError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			group, err = s.updateSubmissionGroupSharePassword(id, passwordHash)
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac hasla")
+				return
+			}
+		}
+
 		if req.RegenerateLink {
 			group, err = s.regenerateSubmissionSharedToken(id)
 			if err != nil {
@@ -383,6 +693,7 @@ func (s *Server) handleSubmissionFile(w http.ResponseWriter, r *http.Request) {
 
 	idStr := strings.TrimPrefix(r.URL.Path, "/submitted/file/")
 	idStr = strings.Trim(idStr, "/")
+	idStr, preview := strings.CutSuffix(idStr, "/preview")
 	if idStr == "" {
 		http.NotFound(w, r)
 		return
@@ -395,42 +706,27 @@ func (s *Server) handleSubmissionFile(w http.ResponseWriter, r *http.Request) {
 	}
 
 	entry, group, err := s.getSubmissionEntry(id)
-	if err != nil {
+	if err != nil || !s.submissionEntryViewable(w, r, entry, group) {
 		http.NotFound(w, r)
 		return
 	}
-
-	loggedIn := s.sessions.authenticated(w, r)
-	viewerToken := submissionViewerTokenFromRequest(r)
-
-	switch group.Visibility {
-	case visibilityPrivate:
-		if !loggedIn {
-			http.NotFound(w, r)
-			return
-		}
-	case visibilityShared:
-		if !loggedIn && viewerToken != entry.ContributorToken {
-			http.NotFound(w, r)
-			return
-		}
+	download := r.URL.Query().Get("download") == "1"
+	if !s.submissionSharePermits(w, r, entry, group, preview, download) {
+		http.Error(w, "download not allowed", http.StatusForbidden)
+		return
 	}
-
-	dir := s.submissionGroupDir(group)
-	target := filepath.Join(dir, entry.FileName)
-	cleanDir := filepath.Clean(dir)
-	cleanTarget := filepath.Clean(target)
-	if cleanTarget != cleanDir && !strings.HasPrefix(cleanTarget, cleanDir+string(os.PathSeparator)) {
-		http.NotFound(w, r)
+	if preview {
+		s.serveSubmissionPreview(w, r, entry, group)
 		return
 	}
 
-	if _, err := os.Stat(target); err != nil {
+	target, err := s.submissionFilePath(entry, group)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	if r.URL.Query().Get("download") == "1" {
+	if download {
 		w.Header().Set("Content-Disposition", "attachment; filename=\""+entry.OriginalName+"\"")
 	} else {
 		w.Header().Set("Content-Disposition", "inline; filename=\""+entry.OriginalName+"\"")
@@ -440,3 +736,81 @@ func (s *Server) handleSubmissionFile(w http.ResponseWriter, r *http.Request) {
 	}
 	http.ServeFile(w, r, target)
 }
+
+// submissionEntryViewable applies the group visibility to a single file.
+func (s *Server) submissionEntryViewable(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord) bool {
+	if s.sessions.authenticated(w, r) {
+		return true
+	}
+	viewerToken := submissionViewerTokenFromRequest(r)
+	if viewerToken == entry.ContributorToken && s.submissionInviteFor(group.ID, viewerToken) != nil {
+		return true
+	}
+	switch group.Visibility {
+	case visibilityPrivate:
+		return false
+	case visibilityPublic:
+		return entry.visibleTo(viewerToken)
+	case visibilityShared:
+		return viewerToken == entry.ContributorToken && s.submissionShareUnlocked(r, group)
+	}
+	return true
+}
+
+// submissionSharePermits applies the permissions of the share link a file of
+// a shared group is opened through. Visitors have to name the link, so a link
+// without downloads cannot be worked around by leaving the token out. Images
+// can still be shown inline, everything else counts as a download.
+func (s *Server) submissionSharePermits(w http.ResponseWriter, r *http.Request, entry *submissionEntryRecord, group *submissionGroupRecord, preview, download bool) bool {
+	if group.Visibility != visibilityShared || s.sessions.authenticated(w, r) {
+		return true
+	}
+	viewerToken := submissionViewerTokenFromRequest(r)
+	if viewerToken == entry.ContributorToken && s.submissionInviteFor(group.ID, viewerToken) != nil {
+		return true
+	}
+	linked, access, err := s.resolveSubmissionShare(strings.TrimSpace(r.URL.Query().Get("share")))
+	if err != nil || linked.ID != group.ID || access.blocked(time.Now(), 0) || !access.Permissions.View {
+		return false
+	}
+	if preview || (!download && isImageFile(entry.OriginalName)) {
+		return true
+	}
+	return access.Permissions.Download
+}
+
+func (s *Server) handleSubmissionGroupQR(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	group, err := s.getSubmissionGroupByID(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			writeJSONError(w, http.StatusNotFound, "Grupa nie istnieje")
+			return
+		}
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac grupy")
+		return
+	}
+	if group.Visibility != visibilityShared {
+		writeJSONError(w, http.StatusBadRequest, "Grupa nie ma udostepnionego linku")
+		return
+	}
+
+	token, err := s.ensureSubmissionSharedToken(group.ID)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie przygotowac linku")
+		return
+	}
+	if linkID := r.URL.Query().Get("link"); linkID != "" {
+		link, ok := s.shareLinkForQR(w, linkID, shareKindGroup, group.ID)
+		if !ok {
+			return
+		}
+		token = link.Token
+	}
+
+	s.writeShareQR(w, r, shareLinkURL(requestBaseURL(r), shareKindGroup, token), "grupa-"+group.Slug)
+}
@@ -1,13 +1,39 @@
 package app
 
+// previewMetaTags renders the link preview metadata of a page with a Meta field.
+const previewMetaTags = `{{with .Meta}}
+  <meta name="description" content="{{.Description}}">
+  <meta property="og:type" content="website">
+  <meta property="og:site_name" content="Galeria zdjec">
+  <meta property="og:locale" content="pl_PL">
+  <meta property="og:title" content="{{.Title}}">
+  <meta property="og:description" content="{{.Description}}">
+  <meta property="og:url" content="{{.URL}}">
+  {{- if .Image}}
+  <meta property="og:image" content="{{.Image}}">
+  <meta property="og:image:alt" content="{{.ImageAlt}}">
+  {{- end}}
+  <meta name="twitter:card" content="{{.TwitterCard}}">
+  <meta name="twitter:title" content="{{.Title}}">
+  <meta name="twitter:description" content="{{.Description}}">
+  {{- if .Image}}
+  <meta name="twitter:image" content="{{.Image}}">
+  <meta name="twitter:image:alt" content="{{.ImageAlt}}">
+  {{- end}}
+  {{- if .OEmbedURL}}
+  <link rel="alternate" type="application/json+oembed" href="{{.OEmbedURL}}" title="{{.Title}}">
+  {{- end}}
+{{end}}`
+
 const PageTemplate = `<!DOCTYPE html>
 <html lang="pl">
 <head>
   <meta charset="UTF-8">
   <meta name="viewport" content="width=device-width, initial-scale=1.0">
-  <title>Galeria zdjec</title>
+  <title>{{if .Meta}}{{.Meta.Title}} - Galeria zdjec{{else}}Galeria zdjec{{end}}</title>
   <link rel="icon" type="image/x-icon" href="/favicon.ico">
-  <style>
+` + previewMetaTags + `{{range .Feeds}}  <link rel="alternate" type="{{.Type}}" title="{{.Title}}" href="{{.URL}}">
+{{end}}  <style>
     :root {
       color-scheme: light dark;
       font-family: 'Inter', 'Segoe UI', system-ui, sans-serif;
@@ -156,7 +182,8 @@ const PageTemplate = `<!DOCTYPE html>
       flex-wrap: wrap;
       align-items: center;
     }
-    .inline-form input[type="text"] {
+    .inline-form input[type="text"],
+    .inline-form input[type="password"] {
       border: 1px solid rgba(15, 23, 42, 0.15);
       border-radius: 999px;
       padding: 0.5rem 1.1rem;
@@ -368,6 +395,379 @@ const PageTemplate = `<!DOCTYPE html>
       border: 1px solid rgba(14, 165, 233, 0.3);
       font-size: 0.92rem;
     }
+    .status-badge {
+      display: inline-flex;
+      padding: 0.1rem 0.6rem;
+      border-radius: 999px;
+      font-size: 0.75rem;
+      font-weight: 600;
+      background: rgba(148, 163, 184, 0.2);
+      color: #475569;
+    }
+    .status-badge.pending {
+      background: rgba(234, 179, 8, 0.18);
+      color: #a16207;
+    }
+    .status-badge.approved {
+      background: rgba(34, 197, 94, 0.15);
+      color: #15803d;
+    }
+    .status-badge.rejected {
+      background: rgba(239, 68, 68, 0.15);
+      color: #b91c1c;
+    }
+    .status-badge.published {
+      background: rgba(99, 102, 241, 0.15);
+      color: #4338ca;
+    }
+    .image-credit {
+      font-size: 0.75rem;
+      color: #64748b;
+    }
+    .comment-count {
+      font-size: 0.75rem;
+      color: #4338ca;
+    }
+    .comments-thread {
+      margin-top: 0.75rem;
+      padding-top: 0.75rem;
+      border-top: 1px solid #e2e8f0;
+    }
+    .comments-thread[hidden],
+    .comments-drawer[hidden] {
+      display: none;
+    }
+    .comments-toggle {
+      position: fixed;
+      top: 1.25rem;
+      right: 1.25rem;
+      z-index: 1101;
+    }
+    .comments-drawer {
+      position: fixed;
+      top: 4rem;
+      right: 1.25rem;
+      bottom: 5rem;
+      width: min(380px, 90vw);
+      overflow-y: auto;
+      z-index: 1101;
+      background: #fff;
+      border-radius: 16px;
+      padding: 1rem;
+      box-shadow: 0 20px 50px rgba(0, 0, 0, 0.45);
+    }
+    .comment {
+      padding: 0.5rem 0;
+      border-bottom: 1px solid #f1f5f9;
+      font-size: 0.9rem;
+    }
+    .comment-hidden {
+      opacity: 0.6;
+    }
+    .comment-header {
+      display: flex;
+      flex-wrap: wrap;
+      align-items: baseline;
+      gap: 0.5rem;
+    }
+    .comment-header small {
+      color: #64748b;
+    }
+    .comment-admin {
+      font-size: 0.7rem;
+      padding: 0.1rem 0.4rem;
+      border-radius: 999px;
+      background: rgba(99, 102, 241, 0.12);
+      color: #4338ca;
+    }
+    .comment-body {
+      margin: 0.25rem 0;
+      white-space: pre-wrap;
+      overflow-wrap: anywhere;
+    }
+    .comment-actions {
+      display: flex;
+      gap: 0.35rem;
+    }
+    .comment-actions button {
+      font-size: 0.75rem;
+      padding: 0.2rem 0.6rem;
+    }
+    .comment-form {
+      display: flex;
+      flex-direction: column;
+      gap: 0.5rem;
+      margin-top: 0.75rem;
+    }
+    .comment-note {
+      font-size: 0.8rem;
+      color: #64748b;
+    }
+    .review-note {
+      font-style: italic;
+    }
+    .moderation-bar {
+      margin-top: 1.5rem;
+      display: flex;
+      flex-direction: column;
+      gap: 0.75rem;
+    }
+    .moderation-filters,
+    .moderation-actions {
+      display: flex;
+      flex-wrap: wrap;
+      align-items: center;
+      gap: 0.5rem;
+    }
+    .moderation-filter {
+      padding: 0.35rem 0.85rem;
+      border-radius: 999px;
+      border: 1px solid rgba(148, 163, 184, 0.4);
+      color: #334155;
+      text-decoration: none;
+      font-size: 0.9rem;
+    }
+    .moderation-filter.active {
+      background: #0f172a;
+      border-color: #0f172a;
+      color: #fff;
+    }
+    .moderation-hint {
+      margin: 0;
+      font-size: 0.8rem;
+      color: #64748b;
+    }
+    .submission-entry.focused {
+      outline: 2px solid #6366f1;
+      outline-offset: 2px;
+    }
+    .moderation-select {
+      align-self: flex-start;
+      width: 1.1rem;
+      height: 1.1rem;
+    }
+    .receipt-panel {
+      margin-top: 1rem;
+      padding: 1rem;
+      border-radius: 16px;
+      background: rgba(99, 102, 241, 0.08);
+      display: flex;
+      flex-direction: column;
+      gap: 0.35rem;
+    }
+    .receipt-panel code {
+      font-size: 1.35rem;
+      font-weight: 700;
+      letter-spacing: 0.12em;
+      color: #312e81;
+    }
+    .receipt-panel small {
+      color: #64748b;
+    }
+    .receipt-claim {
+      margin-top: 1rem;
+      font-size: 0.9rem;
+    }
+    .receipt-claim summary {
+      cursor: pointer;
+      color: #4338ca;
+    }
+    .receipt-claim form {
+      margin-top: 0.6rem;
+      display: flex;
+      flex-wrap: wrap;
+      gap: 0.5rem;
+    }
+    .receipt-claim input {
+      text-transform: uppercase;
+      letter-spacing: 0.08em;
+    }
+    .submission-changes {
+      margin-top: 1.5rem;
+      font-size: 0.9rem;
+      color: #475569;
+    }
+    .submission-changes summary {
+      cursor: pointer;
+      font-weight: 600;
+    }
+    .submission-changes ul {
+      margin: 0.75rem 0 0;
+      padding-left: 1.1rem;
+      display: flex;
+      flex-direction: column;
+      gap: 0.35rem;
+    }
+    .submission-changes li span {
+      color: #94a3b8;
+    }
+    .webhooks-panel {
+      margin-top: 1.5rem;
+      font-size: 0.9rem;
+      color: #475569;
+    }
+    .webhooks-panel summary {
+      cursor: pointer;
+      font-weight: 600;
+    }
+    .webhook-list {
+      list-style: none;
+      padding: 0;
+      display: flex;
+      flex-direction: column;
+      gap: 0.5rem;
+    }
+    .webhook-list li {
+      display: flex;
+      justify-content: space-between;
+      align-items: center;
+      gap: 1rem;
+      flex-wrap: wrap;
+    }
+    .webhook-list li > div:first-child {
+      display: flex;
+      flex-direction: column;
+      gap: 0.2rem;
+      word-break: break-all;
+    }
+    .webhook-actions {
+      display: flex;
+      gap: 0.35rem;
+    }
+    .status-badge.votes {
+      background: rgba(99, 102, 241, 0.12);
+      color: #4338ca;
+    }
+    button.voted {
+      border-color: #4338ca;
+      color: #4338ca;
+    }
+    .jury-score {
+      display: inline-flex;
+      align-items: center;
+      gap: 0.35rem;
+      font-size: 0.85rem;
+      color: #475569;
+    }
+    .results-panel {
+      margin-top: 1.5rem;
+    }
+    .results-header {
+      display: flex;
+      justify-content: space-between;
+      align-items: center;
+      gap: 1rem;
+    }
+    .results-header h3 {
+      margin: 0;
+    }
+    .results-table {
+      width: 100%;
+      border-collapse: collapse;
+      font-size: 0.9rem;
+      margin-top: 0.75rem;
+    }
+    .results-table th,
+    .results-table td {
+      text-align: left;
+      padding: 0.4rem 0.5rem;
+      border-bottom: 1px solid #e2e8f0;
+    }
+    .invite-identity {
+      margin: 0;
+      color: #334155;
+    }
+    .webhook-form {
+      display: flex;
+      flex-direction: column;
+      gap: 0.5rem;
+      margin: 0.75rem 0;
+    }
+    .webhook-deliveries {
+      width: 100%;
+      border-collapse: collapse;
+      font-size: 0.8rem;
+    }
+    .webhook-deliveries th,
+    .webhook-deliveries td {
+      text-align: left;
+      padding: 0.3rem 0.4rem;
+      border-bottom: 1px solid #e2e8f0;
+      word-break: break-word;
+    }
+    .status-badge.scan-clean {
+      background: rgba(34, 197, 94, 0.1);
+      color: #15803d;
+    }
+    .status-badge.scan-error {
+      background: rgba(234, 179, 8, 0.15);
+      color: #a16207;
+    }
+    .status-badge.scan-infected {
+      background: rgba(239, 68, 68, 0.2);
+      color: #991b1b;
+    }
+    .status-badge.delivery-pending {
+      background: rgba(234, 179, 8, 0.15);
+      color: #a16207;
+    }
+    .status-badge.delivery-delivered {
+      background: rgba(34, 197, 94, 0.15);
+      color: #15803d;
+    }
+    .status-badge.delivery-failed {
+      background: rgba(239, 68, 68, 0.15);
+      color: #b91c1c;
+    }
+    .submission-rules {
+      margin: 0;
+      padding-left: 1.1rem;
+      font-size: 0.85rem;
+      color: #64748b;
+    }
+    .submission-fields-editor {
+      display: flex;
+      flex-direction: column;
+      gap: 0.5rem;
+    }
+    .submission-field-row {
+      display: grid;
+      grid-template-columns: 2fr 1fr auto 2fr auto;
+      gap: 0.5rem;
+      align-items: center;
+    }
+    .submission-field-answers {
+      margin: 0.25rem 0;
+      font-size: 0.85rem;
+      color: #475569;
+    }
+    .submission-field-answers dt {
+      font-weight: 600;
+    }
+    .submission-field-answers dd {
+      margin: 0 0 0.25rem;
+    }
+    .submission-types {
+      display: flex;
+      flex-wrap: wrap;
+      gap: 0.5rem 1rem;
+    }
+    .submission-window {
+      display: flex;
+      justify-content: space-between;
+      align-items: center;
+      gap: 1rem;
+      flex-wrap: wrap;
+    }
+    .submission-window.closed {
+      background: rgba(239, 68, 68, 0.08);
+      color: #b91c1c;
+      border-color: rgba(239, 68, 68, 0.3);
+    }
+    .submission-countdown {
+      font-variant-numeric: tabular-nums;
+      font-weight: 600;
+    }
     .upload-panel {
       margin-top: 1.25rem;
       padding: 1rem;
@@ -449,6 +849,44 @@ const PageTemplate = `<!DOCTYPE html>
       font-size: 1.1rem;
       letter-spacing: 0.08em;
     }
+    .pdf-preview {
+      position: relative;
+      width: 100%;
+      height: 100%;
+      display: flex;
+      align-items: center;
+      justify-content: center;
+    }
+    .submission-preview .pdf-preview img {
+      position: absolute;
+      inset: 0;
+      object-position: top;
+      background: #fff;
+    }
+    .pdf-viewer {
+      display: flex;
+      flex-direction: column;
+      gap: 0.75rem;
+      max-height: 80vh;
+      overflow-y: auto;
+      padding: 0.75rem;
+      background: #e2e8f0;
+      border-radius: 12px;
+    }
+    .pdf-viewer[hidden] {
+      display: none;
+    }
+    .pdf-viewer img {
+      width: 100%;
+      background: #fff;
+      box-shadow: 0 1px 4px rgba(15, 23, 42, 0.2);
+    }
+    .pdf-viewer .empty,
+    .pdf-viewer-note {
+      margin: 0;
+      font-size: 0.85rem;
+      color: #475569;
+    }
     .submission-info {
       flex: 1;
       min-width: 220px;
@@ -718,12 +1156,21 @@ const PageTemplate = `<!DOCTYPE html>
       color: #475569;
     }
     .modal input,
+    .modal select,
     .modal textarea {
       border-radius: 12px;
       border: 1px solid rgba(148, 163, 184, 0.5);
       padding: 0.6rem 0.8rem;
       font-size: 0.95rem;
     }
+    .modal input[type="color"] {
+      padding: 0.2rem;
+      height: 2.6rem;
+    }
+    .modal .checkbox-label {
+      flex-direction: row;
+      align-items: center;
+    }
     .modal-actions {
       display: flex;
       gap: 0.75rem;
@@ -777,19 +1224,190 @@ const PageTemplate = `<!DOCTYPE html>
       text-overflow: ellipsis;
       white-space: nowrap;
     }
-    .toast {
-      position: fixed;
-      bottom: 2rem;
-      right: 2rem;
-      background: #111827;
-      color: #fff;
-      padding: 0.85rem 1.25rem;
-      border-radius: 12px;
-      box-shadow: 0 15px 40px rgba(17, 24, 39, 0.35);
-      opacity: 0;
-      transform: translateY(15px);
-      pointer-events: none;
-      transition: opacity 0.2s ease, transform 0.2s ease;
+    .embed-details {
+      display: grid;
+      gap: 0.6rem;
+    }
+    .embed-details code {
+      white-space: normal;
+      word-break: break-all;
+      font-size: 0.8rem;
+    }
+    .share-limits {
+      display: grid;
+      grid-template-columns: repeat(auto-fit, minmax(180px, 1fr));
+      gap: 0.75rem;
+    }
+    .share-limits label {
+      display: flex;
+      flex-direction: column;
+      gap: 0.3rem;
+      font-size: 0.85rem;
+      color: #475569;
+    }
+    .share-limits input {
+      border-radius: 10px;
+      border: 1px solid rgba(148, 163, 184, 0.5);
+      padding: 0.45rem 0.7rem;
+      font-size: 0.9rem;
+    }
+    .share-password-row {
+      display: flex;
+      gap: 0.75rem;
+      align-items: flex-end;
+      flex-wrap: wrap;
+    }
+    .share-password-row label {
+      flex: 1 1 200px;
+    }
+    .share-password-row .checkbox-label {
+      flex: 0 0 auto;
+      flex-direction: row;
+      align-items: center;
+      gap: 0.4rem;
+    }
+    .share-unlock {
+      max-width: 520px;
+      margin: 2rem auto;
+      display: flex;
+      flex-direction: column;
+      gap: 0.9rem;
+      text-align: center;
+      align-items: center;
+    }
+    .share-unlock h2 {
+      margin: 0;
+      font-size: 1.25rem;
+    }
+    .share-unlock p {
+      margin: 0;
+      color: #64748b;
+    }
+    .share-remaining {
+      margin: 0;
+      font-size: 0.85rem;
+      color: #475569;
+    }
+    .share-remaining.expired {
+      color: #b91c1c;
+      font-weight: 600;
+    }
+    .analytics-summary {
+      display: grid;
+      grid-template-columns: repeat(3, 1fr);
+      gap: 0.75rem;
+    }
+    .analytics-summary div {
+      display: flex;
+      flex-direction: column;
+      padding: 0.6rem 0.75rem;
+      border-radius: 10px;
+      background: rgba(148, 163, 184, 0.12);
+    }
+    .analytics-summary strong {
+      font-size: 1.35rem;
+    }
+    .analytics-summary span {
+      font-size: 0.8rem;
+      color: #64748b;
+    }
+    .analytics-chart {
+      display: flex;
+      align-items: flex-end;
+      gap: 2px;
+      height: 120px;
+      margin-top: 0.75rem;
+      border-bottom: 1px solid rgba(148, 163, 184, 0.5);
+    }
+    .analytics-bar {
+      flex: 1;
+      position: relative;
+      min-height: 1px;
+      background: rgba(59, 130, 246, 0.25);
+      border-radius: 3px 3px 0 0;
+    }
+    .analytics-bar span {
+      position: absolute;
+      bottom: 0;
+      left: 0;
+      right: 0;
+      background: #3b82f6;
+      border-radius: 3px 3px 0 0;
+    }
+    .analytics-list {
+      margin: 0.4rem 0 0;
+      padding-left: 1.2rem;
+      font-size: 0.85rem;
+      color: #364152;
+      max-height: 200px;
+      overflow-y: auto;
+    }
+    .analytics-list li {
+      margin-bottom: 0.3rem;
+    }
+    .share-links {
+      display: flex;
+      flex-direction: column;
+      gap: 0.6rem;
+      margin-top: 0.75rem;
+    }
+    .share-links-hint {
+      margin: 0;
+      font-size: 0.85rem;
+      color: #64748b;
+    }
+    .share-links-list {
+      display: flex;
+      flex-direction: column;
+      gap: 0.5rem;
+    }
+    .share-link-item {
+      display: flex;
+      flex-direction: column;
+      gap: 0.35rem;
+      padding: 0.6rem 0.75rem;
+      border-radius: 10px;
+      background: rgba(148, 163, 184, 0.12);
+    }
+    .share-link-item.inactive {
+      opacity: 0.65;
+    }
+    .share-link-item small {
+      color: #475569;
+    }
+    .share-links-create {
+      display: flex;
+      flex-wrap: wrap;
+      gap: 0.5rem;
+      align-items: center;
+    }
+    .share-links-create input[type="text"],
+    .share-links-create input[type="number"],
+    .share-links-create input[type="datetime-local"] {
+      border-radius: 10px;
+      border: 1px solid rgba(148, 163, 184, 0.5);
+      padding: 0.45rem 0.7rem;
+      font-size: 0.9rem;
+    }
+    .share-links-create .checkbox-label {
+      display: flex;
+      align-items: center;
+      gap: 0.3rem;
+      font-size: 0.85rem;
+    }
+    .toast {
+      position: fixed;
+      bottom: 2rem;
+      right: 2rem;
+      background: #111827;
+      color: #fff;
+      padding: 0.85rem 1.25rem;
+      border-radius: 12px;
+      box-shadow: 0 15px 40px rgba(17, 24, 39, 0.35);
+      opacity: 0;
+      transform: translateY(15px);
+      pointer-events: none;
+      transition: opacity 0.2s ease, transform 0.2s ease;
       z-index: 1200;
       font-size: 0.95rem;
     }
@@ -843,7 +1461,7 @@ const PageTemplate = `<!DOCTYPE html>
     }
   </style>
 </head>
-<body data-page-view="{{.View}}" data-logged-in="{{if .LoggedIn}}true{{else}}false{{end}}" data-upload-limit="{{.SubmissionUploadLimit}}" data-shared-mode="{{if .SharedMode}}true{{else}}false{{end}}" data-sub-shared-mode="{{if .SubmissionSharedMode}}true{{else}}false{{end}}" data-active-folder="{{if .ActiveFolder}}{{.ActiveFolder.Slug}}{{end}}" data-active-folder-id="{{if .ActiveFolder}}{{.ActiveFolder.ID}}{{end}}" data-active-folder-visibility="{{if .ActiveFolder}}{{.ActiveFolder.Visibility}}{{end}}" data-active-folder-share-token="{{if .ActiveFolder}}{{.ActiveFolder.SharedToken}}{{end}}" data-active-folder-share-url="{{if .ActiveFolder}}{{.ActiveFolder.ShareURL}}{{end}}" data-active-folder-share-views="{{if .ActiveFolder}}{{.ActiveFolder.SharedViews}}{{end}}" data-active-folder-name="{{if .ActiveFolder}}{{.ActiveFolder.Name}}{{end}}" data-sub-active-group="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.Slug}}{{end}}" data-sub-active-group-id="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.ID}}{{end}}" data-sub-active-group-visibility="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.Visibility}}{{end}}" data-sub-active-group-share-token="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.SharedToken}}{{end}}" data-sub-active-group-share-url="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.ShareURL}}{{end}}">
+<body data-page-view="{{.View}}" data-logged-in="{{if .LoggedIn}}true{{else}}false{{end}}" data-upload-limit="{{.SubmissionUploadLimit}}" data-shared-mode="{{if .SharedMode}}true{{else}}false{{end}}" data-sub-shared-mode="{{if .SubmissionSharedMode}}true{{else}}false{{end}}" data-active-folder="{{if .ActiveFolder}}{{.ActiveFolder.Slug}}{{end}}" data-active-folder-id="{{if .ActiveFolder}}{{.ActiveFolder.ID}}{{end}}" data-active-folder-visibility="{{if .ActiveFolder}}{{.ActiveFolder.Visibility}}{{end}}" data-active-folder-share-token="{{if .ActiveFolder}}{{.ActiveFolder.SharedToken}}{{end}}" data-active-folder-share-url="{{if .ActiveFolder}}{{.ActiveFolder.ShareURL}}{{end}}" data-active-folder-share-views="{{if .ActiveFolder}}{{.ActiveFolder.SharedViews}}{{end}}" data-active-folder-share-expires="{{if .ActiveFolder}}{{.ActiveFolder.ShareExpiresAt}}{{end}}" data-active-folder-share-max-views="{{if .ActiveFolder}}{{.ActiveFolder.ShareMaxViews}}{{end}}" data-active-folder-share-remaining="{{if .ActiveFolder}}{{.ActiveFolder.ShareRemaining}}{{end}}" data-active-folder-share-expired="{{if and .ActiveFolder .ActiveFolder.ShareExpired}}true{{else}}false{{end}}" data-active-folder-password="{{if and .ActiveFolder .ActiveFolder.PasswordProtected}}true{{else}}false{{end}}" data-active-folder-name="{{if .ActiveFolder}}{{.ActiveFolder.Name}}{{end}}" data-active-folder-comments="{{if and .ActiveFolder .ActiveFolder.CommentsOpen}}true{{else}}false{{end}}" data-sub-active-group="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.Slug}}{{end}}" data-sub-active-group-id="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.ID}}{{end}}" data-sub-active-group-visibility="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.Visibility}}{{end}}" data-sub-active-group-share-token="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.SharedToken}}{{end}}" data-sub-active-group-share-url="{{if .ActiveSubmissionGroup}}{{.ActiveSubmissionGroup.ShareURL}}{{end}}">
   <div class="app-wrapper">
     {{if .LoggedIn}}
     <aside class="side-menu">
@@ -870,6 +1488,16 @@ const PageTemplate = `<!DOCTYPE html>
     </div>
   </header>
   <main class="page">
+    {{if .SharePasswordRequired}}
+    <section class="section-card share-unlock">
+      <h2>Link chroniony haslem</h2>
+      <p>Podaj haslo otrzymane od wlasciciela, aby zobaczyc zawartosc.</p>
+      <form id="shareUnlockForm" class="inline-form" data-kind="{{.ShareUnlockKind}}" data-token="{{.ShareUnlockToken}}">
+        <input type="password" name="password" placeholder="Haslo" autocomplete="current-password" required>
+        <button class="btn btn-tertiary" type="submit">Otworz</button>
+      </form>
+    </section>
+    {{else}}
     <section class="view-section view-gallery">
     {{if not .SharedMode}}
     <section class="section-card folders-panel">
@@ -893,7 +1521,7 @@ const PageTemplate = `<!DOCTYPE html>
                 {{if eq .Visibility "public"}}Publiczny{{else if eq .Visibility "shared"}}Udostepniony{{else}}Prywatny{{end}}
               </span>
               {{if eq .Visibility "shared"}}
-              <span>{{.SharedViews}} wejsc</span>
+              <span>{{.SharedViews}} wejsc{{if .ShareExpired}} • link wygasl{{end}}</span>
               {{end}}
             </div>
           </div>
@@ -944,6 +1572,16 @@ const PageTemplate = `<!DOCTYPE html>
           <button class="submit-btn" type="submit">Przeslij</button>
         </form>
       </div>
+      {{else if and .SharedMode .ShareAllowUpload}}
+      <div class="upload-panel">
+        <form id="uploadForm">
+          <input type="hidden" name="folder" value="{{.ActiveFolder.Slug}}">
+          <input type="hidden" name="shareToken" value="{{.ShareToken}}">
+          <input type="file" name="file" required accept=".jpg,.jpeg,.png,.gif,.bmp,.webp,.avif">
+          <input type="text" name="name" placeholder="Nazwa pliku (opcjonalnie)">
+          <button class="submit-btn" type="submit">Przeslij</button>
+        </form>
+      </div>
       {{else if .SharedMode}}
       <div class="info-panel">Ten folder jest udostepniony tylko do odczytu.</div>
       {{else if not .LoggedIn}}
@@ -953,28 +1591,39 @@ const PageTemplate = `<!DOCTYPE html>
       {{if .Images}}
       <section class="gallery" data-folder="{{.ActiveFolder.Slug}}">
         {{range .Images}}
-        <div class="tile" data-name="{{.Name}}">
+        <div class="tile" data-name="{{.Name}}" data-comments="{{.Comments}}">
           <button type="button" class="thumb" data-src="{{.URL}}" aria-label="Zobacz {{.Name}}">
             <img src="{{.URL}}" alt="{{.Name}}">
           </button>
           <div class="tile-meta">
             <span class="filename" title="{{.Name}}">{{.Name}}</span>
+            {{if .Author}}<span class="image-credit" title="{{if .OriginalName}}Oryginalna nazwa: {{.OriginalName}}{{end}}">fot. {{.Author}}</span>{{end}}
+            {{if not $.SharedMode}}<span class="comment-count" data-comment-count {{if not .Comments}}hidden{{end}}>Komentarze: {{.Comments}}</span>{{end}}
             {{if $.AllowFolderManagement}}
             <div class="tile-actions">
+              <button type="button" class="image-rename-btn image-share-btn" data-name="{{.Name}}" data-folder="{{$.ActiveFolder.Slug}}">Link</button>
               <button type="button" class="image-rename-btn" data-name="{{.Name}}" data-folder="{{$.ActiveFolder.Slug}}">Zmien nazwe</button>
               <button type="button" class="delete-btn" data-name="{{.Name}}" data-folder="{{$.ActiveFolder.Slug}}">Usun</button>
             </div>
+            {{else if and $.SharedMode $.ShareAllowDownload}}
+            <div class="tile-actions">
+              <a class="download-link" href="{{$.ActiveFolder.ShareURL}}?download={{.Name}}" download="{{.Name}}">Pobierz</a>
+            </div>
             {{end}}
           </div>
         </div>
         {{end}}
       </section>
+      {{else if and .SharedMode (not .ShareAllowView)}}
+      <p class="empty">Ten link pozwala tylko na przesylanie plikow.</p>
       {{else}}
       <p class="empty">Brak obrazow w tym folderze.</p>
       {{end}}
       {{else}}
       <div class="empty-state large">
-        {{if .SharedMode}}
+        {{if .ShareExpired}}
+        Ten link wygasl. Popros wlasciciela folderu o nowy link.
+        {{else if .SharedMode}}
         Folder nie jest juz udostepniony lub link wygasl.
         {{else}}
         Wybierz folder z listy powyzej, aby zobaczyc jego zawartosc.
@@ -1012,6 +1661,9 @@ const PageTemplate = `<!DOCTYPE html>
                 {{if .SharedViews}}
                 <span>{{.SharedViews}} wejsc</span>
                 {{end}}
+                {{if .PendingCount}}
+                <span class="status-badge pending">{{.PendingCount}} do moderacji</span>
+                {{end}}
               </div>
             </div>
             <div class="submission-card-actions">
@@ -1057,8 +1709,14 @@ const PageTemplate = `<!DOCTYPE html>
             {{end}}
             {{if .AllowSubmissionManagement}}
             <button type="button" class="ghost" id="submissionRegenerateLink">Nowy link</button>
+            <button type="button" class="ghost" id="submissionQrButton" data-qr-url="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/qr">Kod QR</button>
+            <a class="btn btn-tertiary" href="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/poster?size=a4">Plakat A4</a>
+            <a class="btn btn-tertiary" href="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/poster?size=a5">Plakat A5</a>
             {{end}}
           </div>
+          {{if and .AllowSubmissionManagement .ActiveSubmissionGroup.ShareRemaining}}
+          <p class="share-remaining {{if .ActiveSubmissionGroup.ShareExpired}}expired{{end}}">{{if .ActiveSubmissionGroup.ShareExpired}}Link wygasl: {{end}}{{.ActiveSubmissionGroup.ShareRemaining}}</p>
+          {{end}}
         </div>
 
         {{if .AllowSubmissionManagement}}
@@ -1091,46 +1749,279 @@ const PageTemplate = `<!DOCTYPE html>
               </span>
             </label>
           </div>
+          <label class="checkbox-label">
+            <input type="checkbox" name="inviteOnly" {{if .ActiveSubmissionGroup.InviteOnly}}checked{{end}}>
+            Tylko dla zaproszonych osob (kazda osoba przesyla przez wlasny link lub kod)
+          </label>
+          <span class="section-label">Glosowanie i oceny</span>
+          <div class="submission-types">
+            <label class="checkbox-label"><input type="checkbox" name="votingVisitors" {{if .ActiveSubmissionGroup.VisitorVoting}}checked{{end}}> Glosowanie odwiedzajacych (tylko grupy publiczne)</label>
+            <label class="checkbox-label"><input type="checkbox" name="votingJury" {{if .ActiveSubmissionGroup.JuryScoring}}checked{{end}}> Oceny jury (1-10)</label>
+            <label class="checkbox-label"><input type="checkbox" name="votingHideResults" {{if .ActiveSubmissionGroup.HideResults}}checked{{end}}> Ukryj wyniki do zamkniecia grupy</label>
+          </div>
+          <span class="section-label">Komentarze</span>
+          <label class="checkbox-label"><input type="checkbox" name="commentsOpen" {{if .ActiveSubmissionGroup.CommentsOpen}}checked{{end}}> Odwiedzajacy moga komentowac zaakceptowane pliki (tylko grupy publiczne)</label>
+          <span class="section-label">Ograniczenia linku</span>
+          <div class="share-limits">
+            <label>
+              Wygasa
+              <input type="datetime-local" name="sharedExpiresAt" data-iso="{{.ActiveSubmissionGroup.ShareExpiresAt}}">
+            </label>
+            <label>
+              Limit wejsc
+              <input type="number" name="sharedMaxViews" min="0" step="1" placeholder="bez limitu" value="{{if .ActiveSubmissionGroup.ShareMaxViews}}{{.ActiveSubmissionGroup.ShareMaxViews}}{{end}}">
+            </label>
+          </div>
+          <span class="section-label">Zasady przesylania</span>
+          <div class="share-limits">
+            <label>
+              Maks. rozmiar pliku (MB)
+              <input type="number" name="rulesMaxFileMB" min="1" max="100" step="1" placeholder="10" value="{{if .ActiveSubmissionGroup.Rules.MaxFileMB}}{{.ActiveSubmissionGroup.Rules.MaxFileMB}}{{end}}">
+            </label>
+            <label>
+              Plikow na osobe
+              <input type="number" name="rulesMaxFilesPerContributor" min="0" step="1" placeholder="bez limitu" value="{{if .ActiveSubmissionGroup.Rules.MaxFilesPerContributor}}{{.ActiveSubmissionGroup.Rules.MaxFilesPerContributor}}{{end}}">
+            </label>
+            <label>
+              Laczny limit grupy (MB)
+              <input type="number" name="rulesMaxGroupMB" min="0" step="1" placeholder="bez limitu" value="{{if .ActiveSubmissionGroup.Rules.MaxGroupMB}}{{.ActiveSubmissionGroup.Rules.MaxGroupMB}}{{end}}">
+            </label>
+            <label>
+              Min. szerokosc obrazu (px)
+              <input type="number" name="rulesMinImageWidth" min="0" step="1" placeholder="dowolna" value="{{if .ActiveSubmissionGroup.Rules.MinImageWidth}}{{.ActiveSubmissionGroup.Rules.MinImageWidth}}{{end}}">
+            </label>
+            <label>
+              Min. wysokosc obrazu (px)
+              <input type="number" name="rulesMinImageHeight" min="0" step="1" placeholder="dowolna" value="{{if .ActiveSubmissionGroup.Rules.MinImageHeight}}{{.ActiveSubmissionGroup.Rules.MinImageHeight}}{{end}}">
+            </label>
+          </div>
+          <div class="submission-types">
+            {{range .ActiveSubmissionGroup.Rules.TypeOptions}}
+            <label class="checkbox-label"><input type="checkbox" name="rulesAllowedTypes" value="{{.Key}}" {{if .Checked}}checked{{end}}> {{.Label}}</label>
+            {{end}}
+          </div>
+          <span class="section-label">Pola formularza</span>
+          <div class="submission-fields-editor" id="submissionFieldsEditor">
+            {{range $field := .ActiveSubmissionGroup.Fields}}
+            <div class="submission-field-row">
+              <input type="hidden" data-field-key value="{{$field.Key}}">
+              <input type="text" data-field-label value="{{$field.Label}}" placeholder="Etykieta" maxlength="100">
+              <select data-field-type>
+                {{range $.SubmissionFieldTypes}}<option value="{{.Key}}" {{if eq .Key $field.Type}}selected{{end}}>{{.Label}}</option>{{end}}
+              </select>
+              <label class="checkbox-label"><input type="checkbox" data-field-required {{if $field.Required}}checked{{end}}> Wymagane</label>
+              <input type="text" data-field-options value="{{$field.OptionsText}}" placeholder="Opcje listy, po przecinku">
+              <button type="button" class="ghost" data-field-remove>Usun</button>
+            </div>
+            {{end}}
+          </div>
+          <template id="submissionFieldTemplate">
+            <div class="submission-field-row">
+              <input type="hidden" data-field-key value="">
+              <input type="text" data-field-label placeholder="Etykieta" maxlength="100">
+              <select data-field-type>
+                {{range .SubmissionFieldTypes}}<option value="{{.Key}}">{{.Label}}</option>{{end}}
+              </select>
+              <label class="checkbox-label"><input type="checkbox" data-field-required> Wymagane</label>
+              <input type="text" data-field-options placeholder="Opcje listy, po przecinku">
+              <button type="button" class="ghost" data-field-remove>Usun</button>
+            </div>
+          </template>
+          <button type="button" class="ghost" id="addSubmissionField">Dodaj pole</button>
+          <span class="section-label">Przyjmowanie zgloszen</span>
+          <div class="share-limits">
+            <label>
+              Otwarcie
+              <input type="datetime-local" name="opensAt" data-iso="{{.ActiveSubmissionGroup.SubmissionOpensAt}}">
+            </label>
+            <label>
+              Zamkniecie
+              <input type="datetime-local" name="closesAt" data-iso="{{.ActiveSubmissionGroup.SubmissionClosesAt}}">
+            </label>
+          </div>
+          <div class="share-password-row">
+            <label>
+              {{if .ActiveSubmissionGroup.PasswordProtected}}Nowe haslo (link jest chroniony){{else}}Haslo do linku (opcjonalnie){{end}}
+              <input type="password" name="sharedPassword" autocomplete="new-password" placeholder="{{if .ActiveSubmissionGroup.PasswordProtected}}pozostaw puste, aby nie zmieniac{{else}}brak hasla{{end}}">
+            </label>
+            {{if .ActiveSubmissionGroup.PasswordProtected}}
+            <label class="checkbox-label">
+              <input type="checkbox" name="removeSharedPassword">
+              Usun haslo
+            </label>
+            {{end}}
+          </div>
           <div class="modal-actions">
             <button class="primary" type="submit">Zapisz</button>
           </div>
         </form>
         {{end}}
 
+        {{if and .AllowSubmissionManagement (eq .ActiveSubmissionGroup.Visibility "shared")}}
+        <div class="share-links" data-share-links data-kind="group" data-target-id="{{.ActiveSubmissionGroup.ID}}">
+          <strong>Dodatkowe linki</strong>
+          <p class="share-links-hint">Kazdy link ma wlasna nazwe, statystyki, ograniczenia i uprawnienia.</p>
+          <div class="share-links-list" data-share-links-list></div>
+          <div class="share-links-create">
+            <input type="text" data-link-field="label" placeholder="Nazwa linku, np. Klient A">
+            <input type="datetime-local" data-link-field="expiresAt" title="Wygasa">
+            <input type="number" data-link-field="maxViews" min="0" step="1" placeholder="Limit wejsc">
+            <label class="checkbox-label"><input type="checkbox" data-link-field="allowView" checked> Ogladanie</label>
+            <label class="checkbox-label"><input type="checkbox" data-link-field="allowDownload" checked> Pobieranie</label>
+            <label class="checkbox-label"><input type="checkbox" data-link-field="allowUpload" checked> Przesylanie</label>
+            <button type="button" class="ghost" data-share-link-create>Dodaj link</button>
+          </div>
+        </div>
+        {{end}}
+
         {{if .SubmissionSharedMode}}
         <div class="info-panel">Ten widok pokazuje jedynie pliki przeslane z tego urzadzenia. Aby zobaczyc inne, uzyj wlasnego linku.</div>
         {{end}}
 
+        {{with .ActiveSubmissionGroup}}
+        {{if .SubmissionMessage}}
+        <div class="info-panel submission-window {{.SubmissionState}}">
+          <span>{{.SubmissionMessage}}</span>
+          {{if .SubmissionCountdown}}
+          <span>{{.SubmissionCountdownLabel}}: <span class="submission-countdown" data-countdown="{{.SubmissionCountdown}}"></span></span>
+          {{end}}
+        </div>
+        {{end}}
+        {{end}}
+
         {{if .AllowSubmissionUpload}}
-        <form id="submissionUploadForm" class="upload-panel">
+        <form id="submissionUploadForm" class="upload-panel" data-max-bytes="{{.ActiveSubmissionGroup.Rules.MaxFileBytes}}" data-max-mb="{{.ActiveSubmissionGroup.Rules.MaxFileMB}}">
           <input type="hidden" name="group" value="{{.ActiveSubmissionGroup.Slug}}">
           {{if .SubmissionSharedMode}}
-          <input type="hidden" name="token" value="{{.ActiveSubmissionGroup.SharedToken}}">
+          <input type="hidden" name="token" value="{{.ShareToken}}">
           {{end}}
+          {{if .SubmissionInvite}}
+          <input type="hidden" name="name" value="{{.SubmissionInvite.Name}}">
+          <p class="invite-identity">Przesylasz jako <strong>{{.SubmissionInvite.Name}}</strong></p>
+          {{else}}
           <label>
             Twoja nazwa
             <input type="text" name="name" placeholder="np. Jan Kowalski" required>
           </label>
+          {{end}}
+          {{range .ActiveSubmissionGroup.Fields}}
+          {{if eq .Type "checkbox"}}
+          <label class="checkbox-label">
+            <input type="checkbox" name="{{.InputName}}" value="1" {{if .Required}}required{{end}}>
+            {{.Label}}
+          </label>
+          {{else}}
+          <label>
+            {{.Label}}{{if not .Required}} (opcjonalnie){{end}}
+            {{if eq .Type "select"}}
+            <select name="{{.InputName}}" {{if .Required}}required{{end}}>
+              <option value="">Wybierz...</option>
+              {{range .Options}}<option value="{{.}}">{{.}}</option>{{end}}
+            </select>
+            {{else if eq .Type "number"}}
+            <input type="number" name="{{.InputName}}" step="any" {{if .Required}}required{{end}}>
+            {{else if eq .Type "email"}}
+            <input type="email" name="{{.InputName}}" maxlength="500" {{if .Required}}required{{end}}>
+            {{else}}
+            <input type="text" name="{{.InputName}}" maxlength="500" {{if .Required}}required{{end}}>
+            {{end}}
+          </label>
+          {{end}}
+          {{end}}
           <label>
             Plik
-            <input type="file" name="file" required accept=".jpg,.jpeg,.png,.gif,.bmp,.svg,.webp,.avif,.pdf">
+            <input type="file" name="file" required accept="{{.ActiveSubmissionGroup.Rules.Accept}}">
           </label>
-          <small>Maksymalny rozmiar {{.SubmissionUploadLimit}} MB. Dozwolone obrazy i PDF.</small>
+          <ul class="submission-rules">
+            {{range .ActiveSubmissionGroup.Rules.Summary}}<li>{{.}}</li>{{end}}
+          </ul>
           <button class="submit-btn" type="submit">Przeslij</button>
         </form>
-        {{else}}
+        {{else if and .ActiveSubmissionGroup.InviteOnly (eq .ActiveSubmissionGroup.SubmissionState "open")}}
+        <div class="info-panel">Pliki moga przesylac tylko zaproszone osoby. Otworz link z zaproszenia albo wpisz kod zaproszenia ponizej.</div>
+        {{else if eq .ActiveSubmissionGroup.SubmissionState "open"}}
         <div class="info-panel">Wysylanie plikow jest wylaczone dla tej grupy.</div>
         {{end}}
 
+        {{if .SubmissionReceipt}}
+        <div class="receipt-panel">
+          <span>{{if .SubmissionInvite}}Twoj kod zaproszenia{{else}}Twoj kod potwierdzenia{{end}}</span>
+          <code id="receiptCode">{{.SubmissionReceipt}}</code>
+          <small>Zapisz go. Na innym urzadzeniu lub po wyczyszczeniu ciasteczek wpisz ten kod, aby znow zobaczyc swoje pliki.</small>
+        </div>
+        {{end}}
+        {{if not (or .AllowSubmissionManagement .SubmissionInvite)}}
+        <details class="receipt-claim">
+          <summary>{{if .ActiveSubmissionGroup.InviteOnly}}Masz kod zaproszenia lub potwierdzenia?{{else}}Masz kod potwierdzenia z innego urzadzenia?{{end}}</summary>
+          <form id="receiptClaimForm">
+            <input type="text" name="code" placeholder="XXXX-XXXX-XXXX" autocomplete="off" autocapitalize="characters" required>
+            <button type="submit" class="btn btn-secondary">Przywroc moje pliki</button>
+          </form>
+        </details>
+        {{end}}
+
+        {{if .SubmissionStatusFilters}}
+        <div class="moderation-bar">
+          <nav class="moderation-filters">
+            {{range .SubmissionStatusFilters}}
+            <a class="moderation-filter {{if eq .Key $.SubmissionStatus}}active{{end}}" href="/?view=submitted&group={{$.ActiveSubmissionGroup.Slug}}{{if .Key}}&status={{.Key}}{{end}}">{{.Label}} <span>{{.Count}}</span></a>
+            {{end}}
+          </nav>
+          {{if .SubmissionEntries}}
+          <div class="moderation-actions">
+            <label class="checkbox-label"><input type="checkbox" id="moderationSelectAll"> Zaznacz wszystkie</label>
+            <button type="button" class="btn btn-secondary" data-moderate-bulk="approved">Akceptuj zaznaczone</button>
+            <button type="button" class="ghost" data-moderate-bulk="rejected">Odrzuc zaznaczone</button>
+            <button type="button" class="ghost" id="deleteSelected">Usun zaznaczone</button>
+          </div>
+          <div class="moderation-actions">
+            <a class="btn btn-tertiary" href="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/export?format=csv{{if .SubmissionStatus}}&status={{.SubmissionStatus}}{{end}}">Eksport CSV</a>
+            <a class="btn btn-tertiary" href="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/export?format=json{{if .SubmissionStatus}}&status={{.SubmissionStatus}}{{end}}">Eksport JSON</a>
+            <a class="btn btn-tertiary" href="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/archive{{if .SubmissionStatus}}?status={{.SubmissionStatus}}{{end}}">Pobierz pliki (ZIP)</a>
+            <button type="button" class="ghost" id="archiveSelected">Pobierz zaznaczone (ZIP)</button>
+          </div>
+          {{if .PublishFolders}}
+          <div class="moderation-actions">
+            <label>
+              Folder galerii
+              <select id="publishFolderSelect">
+                {{range .PublishFolders}}<option value="{{.ID}}">{{.Name}}</option>{{end}}
+              </select>
+            </label>
+            <label>
+              Tryb
+              <select id="publishModeSelect">
+                <option value="copy">Kopiuj</option>
+                <option value="move">Przenies</option>
+              </select>
+            </label>
+            <button type="button" class="btn btn-secondary" id="publishSelected">Dodaj zaznaczone do galerii</button>
+          </div>
+          {{end}}
+          <p class="moderation-hint">Skroty: J/K - nastepny/poprzedni plik, X - zaznacz, A - akceptuj, R - odrzuc, N - notatka, P - dodaj do galerii.</p>
+          {{end}}
+        </div>
+        {{end}}
+
+        {{if and .SubmissionVoting .SubmissionEntries}}
+        <div class="info-panel">Masz jeden glos w tej grupie. Kliknij Glosuj przy ulubionej pracy. Glos mozna pozniej przeniesc na inna.</div>
+        {{end}}
         {{if .SubmissionEntries}}
-        <div class="submission-list">
-          {{range .SubmissionEntries}}
-          <article class="submission-entry">
+        <input type="file" id="entryReplaceInput" hidden accept="{{.ActiveSubmissionGroup.Rules.Accept}}">
+        <div class="submission-list" id="submissionList">
+          {{range $entry := .SubmissionEntries}}
+          <article class="submission-entry" data-entry-id="{{.ID}}" data-status="{{.Status}}" data-review-note="{{.ReviewNote}}" tabindex="-1">
+            {{if $.SubmissionStatusFilters}}
+            <input type="checkbox" class="moderation-select" value="{{.ID}}" aria-label="Zaznacz {{.Original}}">
+            {{end}}
             <div class="submission-preview">
               {{if .IsImage}}
               <img src="{{.URL}}" alt="{{.Original}}">
               {{else if .IsPDF}}
-              <div class="pdf-preview">PDF</div>
+              <div class="pdf-preview">
+                {{if .PreviewURL}}<img src="{{.PreviewURL}}" alt="Pierwsza strona {{.Original}}" loading="lazy" data-pdf-thumb>{{end}}
+                <span>PDF</span>
+              </div>
               {{else}}
               <div class="file-preview">Plik</div>
               {{end}}
@@ -1138,22 +2029,218 @@ const PageTemplate = `<!DOCTYPE html>
             <div class="submission-info">
               <h3>{{.Original}}</h3>
               <p>Dodane przez <strong>{{.UploadedBy}}</strong> • {{.UploadedAt}} • {{.SizeLabel}}</p>
+              {{if .PDFPages}}
+              <p class="pdf-meta">{{.PDFPages}} str.{{if .PDFTitle}} • {{.PDFTitle}}{{end}}{{if .PDFAuthor}} • {{.PDFAuthor}}{{end}}</p>
+              {{end}}
+              {{if or $.AllowSubmissionManagement (and .Own (ne .Status "approved"))}}
+              <p>
+                <span class="status-badge {{.Status}}">{{.StatusLabel}}</span>
+                {{if and $.AllowSubmissionManagement .Published}}<span class="status-badge published">W galerii</span>{{end}}
+                {{if .ScanLabel}}<span class="status-badge scan-{{.ScanStatus}}">{{.ScanLabel}}</span>{{end}}
+                {{if and (not $.AllowSubmissionManagement) (eq .Status "pending")}}Plik pojawi sie po akceptacji organizatora.{{end}}
+                {{if .ReviewedAt}}<small>Sprawdzone {{.ReviewedAt}}</small>{{end}}
+              </p>
+              {{end}}
+              {{if and $.SubmissionResultsVisible $.ActiveSubmissionGroup.VisitorVoting (eq .Status "approved")}}
+              <p><span class="status-badge votes">Glosy: {{.Votes}}</span></p>
+              {{end}}
+              {{if .Fields}}
+              <dl class="submission-field-answers">
+                {{range .Fields}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>{{end}}
+              </dl>
+              {{end}}
+              {{if .ReviewNote}}
+              <p class="review-note">Notatka: {{.ReviewNote}}</p>
+              {{end}}
               <div class="submission-actions">
-                <a class="btn btn-secondary" href="{{.URL}}" target="_blank" rel="noopener">Podglad</a>
+                {{if or .IsImage (not $.SubmissionSharedMode) $.ShareAllowDownload}}<a class="btn btn-secondary" href="{{.URL}}" target="_blank" rel="noopener">Podglad</a>{{end}}
+                {{if .ViewerPages}}<button type="button" class="ghost" data-pdf-viewer aria-expanded="false">Przegladaj strony</button>{{end}}
+                {{if and $.SubmissionVoting (eq .Status "approved") (not .Own)}}<button type="button" class="ghost {{if .Voted}}voted{{end}}" data-entry-vote data-voted="{{.Voted}}">{{if .Voted}}Cofnij glos{{else}}Glosuj{{end}}</button>{{end}}
+                {{if $.SubmissionComments}}<button type="button" class="ghost" data-entry-comments aria-expanded="false">Komentarze ({{.Comments}})</button>{{end}}
+                {{if and $.JuryScoreOptions (eq .Status "approved")}}
+                <label class="jury-score">
+                  Ocena jury
+                  <select data-jury-score>
+                    <option value="0">-</option>
+                    {{range $.JuryScoreOptions}}<option value="{{.}}" {{if eq . $entry.JuryScore}}selected{{end}}>{{.}}</option>{{end}}
+                  </select>
+                </label>
+                {{end}}
+                {{if or (not $.SubmissionSharedMode) $.ShareAllowDownload}}
                 <a class="btn btn-tertiary" href="{{.DownloadURL}}">Pobierz</a>
+                {{end}}
+                {{if $.SubmissionStatusFilters}}
+                {{if ne .Status "approved"}}<button type="button" class="ghost" data-moderate="approved">Akceptuj</button>{{end}}
+                {{if ne .Status "rejected"}}<button type="button" class="ghost" data-moderate="rejected">Odrzuc</button>{{end}}
+                <button type="button" class="ghost" data-moderate-note>Notatka</button>
+                {{if and $.PublishFolders .IsImage (eq .Status "approved")}}<button type="button" class="ghost" data-publish>Do galerii</button>{{end}}
+                <button type="button" class="ghost" data-entry-rename data-name="{{.UploadedBy}}">Zmien autora</button>
+                <button type="button" class="ghost" data-entry-retitle data-original="{{.Original}}">Zmien nazwe</button>
+                <button type="button" class="ghost" data-entry-delete>Usun</button>
+                {{end}}
+                {{if and .Own (not .Published) (eq $.ActiveSubmissionGroup.SubmissionState "open")}}
+                {{if not $.SubmissionInvite}}<button type="button" class="ghost" data-entry-rename data-name="{{.UploadedBy}}">Zmien imie</button>{{end}}
+                <button type="button" class="ghost" data-entry-replace>Zastap plik</button>
+                <button type="button" class="ghost" data-entry-withdraw>Wycofaj</button>
+                {{end}}
+              </div>
+              {{if .ViewerPages}}
+              <div class="pdf-viewer" data-preview-url="{{.PreviewURL}}" data-pages="{{.ViewerPages}}" hidden>
+                {{if gt .PDFPages .ViewerPages}}<p class="pdf-viewer-note">Pokazano pierwsze {{.ViewerPages}} z {{.PDFPages}} stron. Pelny dokument otworzysz przyciskiem Podglad.</p>{{end}}
               </div>
+              {{end}}
+              {{if $.SubmissionComments}}<div class="comments-thread" data-comments-thread hidden></div>{{end}}
             </div>
           </article>
           {{end}}
         </div>
+        {{else if and .SubmissionSharedMode (not .ShareAllowView)}}
+        <p class="empty">Ten link pozwala tylko na przesylanie plikow.</p>
         {{else}}
         <p class="empty">Brak plikow w tej grupie.</p>
         {{end}}
+        {{if .SubmissionResults}}
+        <section class="results-panel">
+          <div class="results-header">
+            <h3>Wyniki</h3>
+            {{if .AllowSubmissionManagement}}<a class="btn btn-tertiary" href="/api/submissions/groups/{{.ActiveSubmissionGroup.ID}}/results?format=csv">Pobierz CSV</a>{{end}}
+          </div>
+          {{if and .AllowSubmissionManagement .ActiveSubmissionGroup.HideResults}}<p class="webhooks-hint">Odwiedzajacy zobacza wyniki dopiero po zamknieciu przyjmowania zgloszen.</p>{{end}}
+          <table class="results-table">
+            <thead>
+              <tr>
+                <th>Miejsce</th><th>Autor</th><th>Plik</th>
+                {{if .ActiveSubmissionGroup.VisitorVoting}}<th>Glosy</th>{{end}}
+                {{if .ActiveSubmissionGroup.JuryScoring}}<th>Srednia jury</th><th>Oceny</th>{{end}}
+              </tr>
+            </thead>
+            <tbody>
+              {{range .SubmissionResults}}
+              <tr>
+                <td>{{.Rank}}</td>
+                <td>{{.Author}}</td>
+                <td><a href="{{.URL}}" target="_blank" rel="noopener">{{.Original}}</a></td>
+                {{if $.ActiveSubmissionGroup.VisitorVoting}}<td>{{.Votes}}</td>{{end}}
+                {{if $.ActiveSubmissionGroup.JuryScoring}}<td>{{if .ScoreLabel}}{{.ScoreLabel}}{{else}}-{{end}}</td><td>{{.Scores}}</td>{{end}}
+              </tr>
+              {{end}}
+            </tbody>
+          </table>
+        </section>
+        {{else if .SubmissionResultsHidden}}
+        <div class="info-panel">Wyniki zostana opublikowane po zamknieciu przyjmowania zgloszen.</div>
+        {{end}}
+        {{if .SubmissionChanges}}
+        <details class="submission-changes">
+          <summary>Historia zmian ({{len .SubmissionChanges}})</summary>
+          <ul>
+            {{range .SubmissionChanges}}
+            <li><span>{{.CreatedAt}}</span> <strong>{{.UploaderName}}</strong> • {{.ActionLabel}}: {{.Detail}}{{if eq .Actor "admin"}} <em>(organizator)</em>{{end}}</li>
+            {{end}}
+          </ul>
+        </details>
+        {{end}}
+        {{if .AllowSubmissionManagement}}
+        <details class="webhooks-panel" id="invitesPanel" data-group-id="{{.ActiveSubmissionGroup.ID}}" {{if .ActiveSubmissionGroup.InviteOnly}}open{{end}}>
+          <summary>Zaproszone osoby ({{.SubmissionInvitesDone}}/{{len .SubmissionInvites}} przeslalo)</summary>
+          {{if not .ActiveSubmissionGroup.InviteOnly}}<p class="webhooks-hint">Grupa przyjmuje tez pliki od osob spoza listy. Wlacz opcje "Tylko dla zaproszonych osob" w ustawieniach, aby to zablokowac.</p>{{end}}
+          {{if .SubmissionInvites}}
+          <ul class="webhook-list">
+            {{range .SubmissionInvites}}
+            <li data-invite-id="{{.ID}}" data-name="{{.Name}}" data-url="{{.URL}}">
+              <div>
+                <strong>{{.Name}}</strong>
+                <small>{{if .Files}}<span class="status-badge approved">Przeslano: {{.Files}}</span> ostatnio {{.LastUpload}}{{else}}<span class="status-badge pending">Brak zgloszenia</span>{{end}}</small>
+                <small>Kod: <code>{{.Code}}</code></small>
+              </div>
+              <div class="webhook-actions">
+                <button type="button" class="ghost" data-invite-copy>Kopiuj link</button>
+                <button type="button" class="ghost" data-invite-rename>Zmien imie</button>
+                <button type="button" class="ghost" data-invite-regenerate>Nowy kod</button>
+                <button type="button" class="ghost" data-invite-delete>Usun</button>
+              </div>
+            </li>
+            {{end}}
+          </ul>
+          {{end}}
+          <form id="inviteForm" class="webhook-form">
+            <label>
+              Dodaj osoby (jedno imie w wierszu)
+              <textarea name="names" rows="4" placeholder="Jan Kowalski&#10;Anna Nowak" required></textarea>
+            </label>
+            <button type="submit" class="btn btn-secondary">Dodaj zaproszenia</button>
+          </form>
+        </details>
+        <details class="webhooks-panel" id="webhooksPanel" data-group-id="{{.ActiveSubmissionGroup.ID}}">
+          <summary>Powiadomienia webhook ({{len .Webhooks}})</summary>
+          <p class="webhooks-hint">Kazde wywolanie to POST z danymi JSON. Naglowek <code>X-Grafiki-Signature</code> zawiera podpis <code>sha256=</code> HMAC tresci, liczony sekretem webhooka. Nieudane wysylki sa ponawiane z rosnacym odstepem.</p>
+          {{if .Webhooks}}
+          <ul class="webhook-list">
+            {{range .Webhooks}}
+            <li data-webhook-id="{{.ID}}">
+              <div>
+                <strong>{{.URL}}</strong>
+                <small>{{if .Global}}Wszystkie grupy{{else}}Ta grupa{{end}} • {{.EventLabels}}{{if not .Active}} • wylaczony{{end}}</small>
+                <small>Sekret: <code>{{.Secret}}</code></small>
+              </div>
+              <div class="webhook-actions">
+                <button type="button" class="ghost" data-webhook-test>Test</button>
+                <button type="button" class="ghost" data-webhook-toggle data-active="{{.Active}}">{{if .Active}}Wylacz{{else}}Wlacz{{end}}</button>
+                <button type="button" class="ghost" data-webhook-delete>Usun</button>
+              </div>
+            </li>
+            {{end}}
+          </ul>
+          {{end}}
+          <form id="webhookForm" class="webhook-form">
+            <label>
+              Adres
+              <input type="url" name="url" placeholder="https://example.com/hook" required>
+            </label>
+            <label>
+              Zakres
+              <select name="scope">
+                <option value="group">Ta grupa</option>
+                <option value="global">Wszystkie grupy</option>
+              </select>
+            </label>
+            <div class="submission-types">
+              {{range .WebhookEvents}}
+              <label class="checkbox-label"><input type="checkbox" name="events" value="{{.Key}}" checked> {{.Label}}</label>
+              {{end}}
+            </div>
+            <button type="submit" class="btn btn-secondary">Dodaj webhook</button>
+          </form>
+          {{if .WebhookDeliveries}}
+          <table class="webhook-deliveries">
+            <thead>
+              <tr><th>Czas</th><th>Adres</th><th>Zdarzenie</th><th>Status</th><th>Proby</th><th>Odpowiedz</th><th></th></tr>
+            </thead>
+            <tbody>
+              {{range .WebhookDeliveries}}
+              <tr>
+                <td>{{.CreatedAt}}</td>
+                <td>{{.URL}}</td>
+                <td>{{.Event}}</td>
+                <td><span class="status-badge delivery-{{.Status}}">{{.StatusLabel}}</span>{{if .NextAttempt}} <small>ponowienie {{.NextAttempt}}</small>{{end}}</td>
+                <td>{{.Attempts}}</td>
+                <td>{{if .ResponseCode}}{{.ResponseCode}} {{end}}{{.Error}}</td>
+                <td>{{if eq .Status "failed"}}<button type="button" class="ghost" data-delivery-retry="{{.ID}}">Ponow</button>{{end}}</td>
+              </tr>
+              {{end}}
+            </tbody>
+          </table>
+          {{end}}
+        </details>
+        {{end}}
+        {{else if .ShareExpired}}
+        <p class="empty-state large">Ten link wygasl. Popros organizatora o nowy link.</p>
         {{else}}
         <p class="empty-state large">Wybierz grupe, aby zobaczyc przeslane pliki.</p>
         {{end}}
       </div>
     </section>
+    {{end}}
   </main>
   <div class="fullscreen-backdrop" id="backdrop" role="dialog" aria-modal="true">
     <div class="fullscreen-content">
@@ -1164,6 +2251,10 @@ const PageTemplate = `<!DOCTYPE html>
         <span class="zoom-value" id="zoomValue">100%</span>
       </div>
     </div>
+    {{if and .ActiveFolder (not .SharedMode)}}
+    <button type="button" class="comments-toggle" id="fullscreenCommentsToggle" hidden>Komentarze</button>
+    <aside class="comments-drawer" id="fullscreenComments" hidden></aside>
+    {{end}}
   </div>
 
   <div class="modal-backdrop" id="loginModal">
@@ -1236,6 +2327,13 @@ const PageTemplate = `<!DOCTYPE html>
           </label>
         </div>
       </div>
+      <div class="modal-section">
+        <span class="section-label">Komentarze</span>
+        <label class="checkbox-label">
+          <input type="checkbox" id="folderCommentsOpenInput">
+          Odwiedzajacy moga komentowac obrazy (tylko foldery publiczne)
+        </label>
+      </div>
       <div class="modal-section">
         <div class="share-details" id="shareDetails" hidden>
           <strong>Udostepniony link</strong>
@@ -1247,9 +2345,71 @@ const PageTemplate = `<!DOCTYPE html>
             <span>Wejscia: <strong id="shareViewsValue">0</strong></span>
             <button type="button" class="ghost" id="regenerateLinkButton">Nowy link</button>
             <button type="button" class="ghost" id="downloadQrButton">Pobierz QR</button>
+            <button type="button" class="ghost" id="folderAnalyticsButton">Statystyki</button>
+          </div>
+          <div class="share-limits">
+            <label>
+              Wygasa
+              <input type="datetime-local" id="shareExpiresInput">
+            </label>
+            <label>
+              Limit wejsc
+              <input type="number" id="shareMaxViewsInput" min="0" step="1" placeholder="bez limitu">
+            </label>
+          </div>
+          <div class="share-password-row">
+            <label>
+              <span id="sharePasswordLabel">Haslo do linku (opcjonalnie)</span>
+              <input type="password" id="sharePasswordInput" autocomplete="new-password" placeholder="brak hasla">
+            </label>
+            <label class="checkbox-label" id="shareRemovePasswordLabel" hidden>
+              <input type="checkbox" id="shareRemovePasswordInput">
+              Usun haslo
+            </label>
+          </div>
+          <p class="share-remaining" id="shareRemainingValue" hidden></p>
+          <div class="share-links" data-share-links data-kind="folder" data-target-id="">
+            <strong>Dodatkowe linki</strong>
+            <p class="share-links-hint">Kazdy link ma wlasna nazwe, statystyki, ograniczenia i uprawnienia.</p>
+            <div class="share-links-list" data-share-links-list></div>
+            <div class="share-links-create">
+              <input type="text" data-link-field="label" placeholder="Nazwa linku, np. Klient A">
+              <input type="datetime-local" data-link-field="expiresAt" title="Wygasa">
+              <input type="number" data-link-field="maxViews" min="0" step="1" placeholder="Limit wejsc">
+              <label class="checkbox-label"><input type="checkbox" data-link-field="allowView" checked> Ogladanie</label>
+              <label class="checkbox-label"><input type="checkbox" data-link-field="allowDownload" checked> Pobieranie</label>
+              <label class="checkbox-label"><input type="checkbox" data-link-field="allowUpload"> Przesylanie</label>
+              <button type="button" class="ghost" data-share-link-create>Dodaj link</button>
+            </div>
           </div>
         </div>
       </div>
+      <div class="modal-section embed-details" id="embedDetails" hidden>
+        <strong>Osadzanie na stronie</strong>
+        <p class="share-links-hint">Wklej kod na blogu lub w CMS. Adres galerii obsluguje tez oEmbed.</p>
+        <div class="share-limits">
+          <label>
+            Uklad
+            <select id="embedLayoutInput">
+              <option value="grid">Siatka</option>
+              <option value="masonry">Mozaika</option>
+              <option value="strip">Pasek</option>
+            </select>
+          </label>
+          <label>
+            Kolumny
+            <input type="number" id="embedColumnsInput" min="1" max="6" step="1" value="3">
+          </label>
+          <label class="checkbox-label">
+            <input type="checkbox" id="embedCaptionsInput">
+            Podpisy
+          </label>
+        </div>
+        <div class="share-link-row">
+          <code id="embedCodeValue"></code>
+          <button type="button" class="ghost" id="copyEmbedCode">Kopiuj kod</button>
+        </div>
+      </div>
       <div class="modal-actions">
         <button class="primary" type="submit">Zapisz</button>
         <button class="ghost" type="button" id="folderSettingsCancel">Zamknij</button>
@@ -1257,15 +2417,126 @@ const PageTemplate = `<!DOCTYPE html>
     </form>
   </div>
 
-  <div class="toast" id="statusMessage" role="status" aria-live="polite"></div>
+  <div class="modal-backdrop" id="imageShareModal">
+    <div class="modal">
+      <div class="modal-section">
+        <h2>Link do obrazu</h2>
+        <p class="modal-subtitle" id="imageShareName"></p>
+      </div>
+      <div class="modal-section">
+        <div class="share-details" id="imageShareDetails" hidden>
+          <div class="share-link-row">
+            <code id="imageShareLinkValue"></code>
+            <button type="button" class="ghost" id="imageShareCopy">Kopiuj</button>
+          </div>
+          <div class="share-link-row">
+            <span>Wejscia: <strong id="imageShareViews">0</strong></span>
+            <button type="button" class="ghost" id="imageShareQr">Pobierz QR</button>
+            <button type="button" class="ghost" id="imageShareRegenerate">Nowy link</button>
+          </div>
+          <p class="share-remaining" id="imageShareRemaining" hidden></p>
+        </div>
+        <p class="share-links-hint" id="imageShareEmpty">Ten obraz nie ma jeszcze wlasnego linku.</p>
+        <div class="share-limits">
+          <label>
+            Wygasa (opcjonalnie)
+            <input type="datetime-local" id="imageShareExpires">
+          </label>
+        </div>
+      </div>
+      <div class="modal-actions">
+        <button class="primary" type="button" id="imageShareSave">Utworz link</button>
+        <button class="ghost" type="button" id="imageShareRevoke" hidden>Usun link</button>
+        <button class="ghost" type="button" id="imageShareClose">Zamknij</button>
+      </div>
     </div>
   </div>
 
-  <script>
-    const state = (() => {
-      const dataset = document.body?.dataset || {};
-      return {
-        pageView: dataset.pageView || 'gallery',
+  <div class="modal-backdrop" id="qrModal">
+    <form class="modal" id="qrForm">
+      <div class="modal-section">
+        <h2>Kod QR</h2>
+        <p class="modal-subtitle">Dobierz rozmiar, kolory i format do wydruku.</p>
+      </div>
+      <div class="modal-section share-limits">
+        <label>
+          Rozmiar (px)
+          <input type="number" name="size" min="64" max="4096" step="8" value="512">
+        </label>
+        <label>
+          Korekcja bledow
+          <select name="level">
+            <option value="L">Niska (7%)</option>
+            <option value="M" selected>Srednia (15%)</option>
+            <option value="Q">Wysoka (25%)</option>
+            <option value="H">Najwyzsza (30%)</option>
+          </select>
+        </label>
+        <label>
+          Kolor kodu
+          <input type="color" name="fg" value="#000000">
+        </label>
+        <label>
+          Tlo
+          <input type="color" name="bg" value="#ffffff">
+        </label>
+        <label>
+          Format
+          <select name="format">
+            <option value="png">PNG</option>
+            <option value="svg">SVG</option>
+            <option value="pdf">PDF</option>
+          </select>
+        </label>
+        <label class="checkbox-label">
+          <input type="checkbox" name="logo">
+          Logo na srodku (wymusza najwyzsza korekcje)
+        </label>
+      </div>
+      <div class="modal-actions">
+        <button class="primary" type="submit">Pobierz</button>
+        <button class="ghost" type="button" id="qrCancel">Zamknij</button>
+      </div>
+    </form>
+  </div>
+
+  <div class="modal-backdrop" id="folderAnalyticsModal">
+    <div class="modal modal-large">
+      <div class="modal-section">
+        <h2>Statystyki linku</h2>
+        <p class="modal-subtitle">Wejscia z linkow udostepnionych z ostatnich 30 dni. Ponowne wejscia tej samej osoby w ciagu 30 minut nie sa liczone jako unikalne.</p>
+      </div>
+      <div class="modal-section">
+        <div class="analytics-summary">
+          <div><strong id="analyticsTotal">0</strong><span>wszystkie wejscia</span></div>
+          <div><strong id="analyticsUnique">0</strong><span>unikalne wejscia</span></div>
+          <div><strong id="analyticsVisitors">0</strong><span>odwiedzajacy</span></div>
+        </div>
+        <div class="analytics-chart" id="analyticsChart"></div>
+      </div>
+      <div class="modal-section">
+        <span class="section-label">Najpopularniejsze obrazy</span>
+        <ol class="analytics-list" id="analyticsTopImages"></ol>
+      </div>
+      <div class="modal-section">
+        <span class="section-label">Ostatnie wejscia</span>
+        <ul class="analytics-list" id="analyticsRecent"></ul>
+      </div>
+      <div class="modal-actions">
+        <button class="ghost" type="button" id="folderAnalyticsClose">Zamknij</button>
+      </div>
+    </div>
+  </div>
+
+  <div class="toast" id="statusMessage" role="status" aria-live="polite"></div>
+    </div>
+  </div>
+
+  <script>
+    const state = (() => {
+      const dataset = document.body?.dataset || {};
+      return {
+        pageView: dataset.pageView || 'gallery',
         loggedIn: dataset.loggedIn === 'true',
         sharedMode: dataset.sharedMode === 'true',
         activeFolder: dataset.activeFolder || '',
@@ -1274,7 +2545,13 @@ const PageTemplate = `<!DOCTYPE html>
         activeFolderShareToken: dataset.activeFolderShareToken || '',
         activeFolderShareUrl: dataset.activeFolderShareUrl || '',
         activeFolderShareViews: Number(dataset.activeFolderShareViews || 0),
+        activeFolderShareExpires: dataset.activeFolderShareExpires || '',
+        activeFolderShareMaxViews: Number(dataset.activeFolderShareMaxViews || 0),
+        activeFolderShareRemaining: dataset.activeFolderShareRemaining || '',
+        activeFolderShareExpired: dataset.activeFolderShareExpired === 'true',
+        activeFolderPasswordProtected: dataset.activeFolderPassword === 'true',
         activeFolderName: dataset.activeFolderName || '',
+        activeFolderCommentsOpen: dataset.activeFolderComments === 'true',
         submissionSharedMode: dataset.subSharedMode === 'true',
         activeSubmissionGroup: dataset.subActiveGroup || '',
         activeSubmissionGroupId: Number(dataset.subActiveGroupId || 0),
@@ -1308,12 +2585,30 @@ const PageTemplate = `<!DOCTYPE html>
     const folderSettingsForm = document.getElementById('folderSettingsForm');
     const folderSettingsCancel = document.getElementById('folderSettingsCancel');
     const folderNameInput = document.getElementById('folderNameInput');
+    const folderCommentsOpenInput = document.getElementById('folderCommentsOpenInput');
     const shareDetails = document.getElementById('shareDetails');
+    const embedDetails = document.getElementById('embedDetails');
+    const embedLayoutInput = document.getElementById('embedLayoutInput');
+    const embedColumnsInput = document.getElementById('embedColumnsInput');
+    const embedCaptionsInput = document.getElementById('embedCaptionsInput');
+    const embedCodeValue = document.getElementById('embedCodeValue');
+    const copyEmbedCode = document.getElementById('copyEmbedCode');
     const shareLinkValue = document.getElementById('shareLinkValue');
     const shareViewsValue = document.getElementById('shareViewsValue');
     const copyShareLink = document.getElementById('copyShareLink');
     const regenerateLinkButton = document.getElementById('regenerateLinkButton');
     const downloadQrButton = document.getElementById('downloadQrButton');
+    const folderAnalyticsButton = document.getElementById('folderAnalyticsButton');
+    const folderAnalyticsModal = document.getElementById('folderAnalyticsModal');
+    const folderAnalyticsClose = document.getElementById('folderAnalyticsClose');
+    const shareExpiresInput = document.getElementById('shareExpiresInput');
+    const shareMaxViewsInput = document.getElementById('shareMaxViewsInput');
+    const shareRemainingValue = document.getElementById('shareRemainingValue');
+    const sharePasswordInput = document.getElementById('sharePasswordInput');
+    const sharePasswordLabel = document.getElementById('sharePasswordLabel');
+    const shareRemovePasswordInput = document.getElementById('shareRemovePasswordInput');
+    const shareRemovePasswordLabel = document.getElementById('shareRemovePasswordLabel');
+    const shareUnlockForm = document.getElementById('shareUnlockForm');
     const viewSwitchButtons = document.querySelectorAll('[data-view-target]');
     const newSubmissionGroupForm = document.getElementById('newSubmissionGroupForm');
     const submissionGroupSettingsForm = document.getElementById('submissionGroupSettingsForm');
@@ -1335,6 +2630,40 @@ const PageTemplate = `<!DOCTYPE html>
       panFrame: null
     };
 
+    function isoToLocalInput(iso) {
+      if (!iso) return '';
+      const date = new Date(iso);
+      if (Number.isNaN(date.getTime())) return '';
+      const pad = value => String(value).padStart(2, '0');
+      return date.getFullYear() + '-' + pad(date.getMonth() + 1) + '-' + pad(date.getDate()) + 'T' + pad(date.getHours()) + ':' + pad(date.getMinutes());
+    }
+
+    function localInputToISO(value) {
+      if (!value) return '';
+      const date = new Date(value);
+      return Number.isNaN(date.getTime()) ? '' : date.toISOString();
+    }
+
+    function shareLimitsPayload(expiresInput, maxViewsInput) {
+      const payload = {};
+      if (expiresInput) {
+        payload.sharedExpiresAt = localInputToISO(expiresInput.value);
+      }
+      if (maxViewsInput) {
+        const maxViews = Number(maxViewsInput.value || 0);
+        payload.sharedMaxViews = Number.isFinite(maxViews) && maxViews > 0 ? Math.floor(maxViews) : 0;
+      }
+      return payload;
+    }
+
+    function sharePasswordPayload(passwordInput, removeInput) {
+      if (removeInput?.checked) {
+        return {sharedPassword: ''};
+      }
+      const password = passwordInput?.value.trim() || '';
+      return password ? {sharedPassword: password} : {};
+    }
+
     function showMessage(text, type = 'info') {
       if (!messageEl) return;
       messageEl.textContent = text;
@@ -1366,6 +2695,20 @@ const PageTemplate = `<!DOCTYPE html>
       });
     });
 
+    const submissionFieldsEditor = document.getElementById('submissionFieldsEditor');
+    const submissionFieldTemplate = document.getElementById('submissionFieldTemplate');
+    document.getElementById('addSubmissionField')?.addEventListener('click', () => {
+      const row = submissionFieldTemplate.content.firstElementChild.cloneNode(true);
+      submissionFieldsEditor.appendChild(row);
+      row.querySelector('[data-field-label]').focus();
+    });
+    submissionFieldsEditor?.addEventListener('click', event => {
+      const remove = event.target.closest('[data-field-remove]');
+      if (remove) {
+        remove.closest('.submission-field-row').remove();
+      }
+    });
+
     submissionGroupSettingsForm?.addEventListener('submit', async event => {
       event.preventDefault();
       if (!state.activeSubmissionGroupId) {
@@ -1379,11 +2722,53 @@ const PageTemplate = `<!DOCTYPE html>
         return;
       }
       const visibility = formData.get('submissionVisibility');
+      const limits = shareLimitsPayload(
+        submissionGroupSettingsForm.elements['sharedExpiresAt'],
+        submissionGroupSettingsForm.elements['sharedMaxViews']
+      );
+      const password = sharePasswordPayload(
+        submissionGroupSettingsForm.elements['sharedPassword'],
+        submissionGroupSettingsForm.elements['removeSharedPassword']
+      );
+      const schedule = {
+        opensAt: localInputToISO(submissionGroupSettingsForm.elements['opensAt']?.value),
+        closesAt: localInputToISO(submissionGroupSettingsForm.elements['closesAt']?.value)
+      };
+      const allowedTypes = formData.getAll('rulesAllowedTypes').map(String);
+      if (!allowedTypes.length) {
+        showMessage('Wybierz co najmniej jeden typ pliku', 'error');
+        return;
+      }
+      const ruleNumber = name => Math.max(0, Math.floor(Number(formData.get(name) || 0)) || 0);
+      const rules = {
+        maxFileMB: ruleNumber('rulesMaxFileMB'),
+        allowedTypes,
+        maxFilesPerContributor: ruleNumber('rulesMaxFilesPerContributor'),
+        maxGroupMB: ruleNumber('rulesMaxGroupMB'),
+        minImageWidth: ruleNumber('rulesMinImageWidth'),
+        minImageHeight: ruleNumber('rulesMinImageHeight')
+      };
+      const fields = Array.from(submissionGroupSettingsForm.querySelectorAll('#submissionFieldsEditor .submission-field-row')).map(row => ({
+        key: row.querySelector('[data-field-key]').value,
+        label: row.querySelector('[data-field-label]').value.trim(),
+        type: row.querySelector('[data-field-type]').value,
+        required: row.querySelector('[data-field-required]').checked,
+        options: row.querySelector('[data-field-options]').value.split(',').map(option => option.trim()).filter(Boolean)
+      }));
+      const voting = {
+        visitors: formData.get('votingVisitors') === 'on',
+        jury: formData.get('votingJury') === 'on',
+        hideResults: formData.get('votingHideResults') === 'on'
+      };
+      if (fields.some(field => !field.label)) {
+        showMessage('Kazde pole formularza musi miec etykiete', 'error');
+        return;
+      }
       try {
         const updated = await fetchJSON('/api/submissions/groups/' + state.activeSubmissionGroupId, {
           method: 'PATCH',
           headers: {'Content-Type': 'application/json'},
-          body: JSON.stringify({name, visibility})
+          body: JSON.stringify({name, visibility, inviteOnly: formData.get('inviteOnly') === 'on', voting, commentsOpen: formData.get('commentsOpen') === 'on', ...limits, ...password, ...schedule, rules, fields})
         });
         const slug = updated.slug || updated.Slug;
         const next = new URL(window.location.href);
@@ -1397,6 +2782,58 @@ const PageTemplate = `<!DOCTYPE html>
       }
     });
 
+    if (submissionGroupSettingsForm) {
+      ['sharedExpiresAt', 'opensAt', 'closesAt'].forEach(name => {
+        const field = submissionGroupSettingsForm.elements[name];
+        if (field) {
+          field.value = isoToLocalInput(field.dataset.iso);
+        }
+      });
+    }
+
+    const countdowns = document.querySelectorAll('[data-countdown]');
+    if (countdowns.length) {
+      const pad = value => String(value).padStart(2, '0');
+      const tick = () => {
+        countdowns.forEach(element => {
+          const left = Math.max(0, Math.floor((new Date(element.dataset.countdown).getTime() - Date.now()) / 1000));
+          const days = Math.floor(left / 86400);
+          const clock = pad(Math.floor(left % 86400 / 3600)) + ':' + pad(Math.floor(left % 3600 / 60)) + ':' + pad(left % 60);
+          element.textContent = (days > 0 ? days + ' d ' : '') + clock;
+          if (left === 0 && !element.dataset.done) {
+            element.dataset.done = 'true';
+            setTimeout(() => window.location.reload(), 1500);
+          }
+        });
+      };
+      tick();
+      setInterval(tick, 1000);
+    }
+
+    shareUnlockForm?.addEventListener('submit', async event => {
+      event.preventDefault();
+      const password = String(new FormData(shareUnlockForm).get('password') || '');
+      if (!password) {
+        showMessage('Podaj haslo', 'error');
+        return;
+      }
+      try {
+        await fetchJSON('/api/shared/unlock', {
+          method: 'POST',
+          headers: {'Content-Type': 'application/json'},
+          body: JSON.stringify({
+            kind: shareUnlockForm.dataset.kind,
+            token: shareUnlockForm.dataset.token,
+            password
+          })
+        });
+        window.location.reload();
+      } catch (err) {
+        shareUnlockForm.reset();
+        showMessage(err.message, 'error');
+      }
+    });
+
     submissionRegenerateLinkButton?.addEventListener('click', async () => {
       if (!state.activeSubmissionGroupId) {
         return;
@@ -1435,12 +2872,18 @@ const PageTemplate = `<!DOCTYPE html>
         showMessage('Podpisz sie przed wysylka', 'error');
         return;
       }
-      if (!formData.get('file')) {
+      const file = formData.get('file');
+      if (!file || !file.name) {
         showMessage('Wybierz plik do przeslania', 'error');
         return;
       }
+      const maxBytes = Number(submissionUploadForm.dataset.maxBytes || 0);
+      if (maxBytes && file.size > maxBytes) {
+        showMessage('Plik jest za duzy. Limit to ' + submissionUploadForm.dataset.maxMb + ' MB', 'error');
+        return;
+      }
       try {
-        await fetchJSON('/api/submissions/upload', {
+        await fetchJSON('/api/submissions/upload?group=' + encodeURIComponent(formData.get('group') || ''), {
           method: 'POST',
           body: formData
         });
@@ -1450,6 +2893,457 @@ const PageTemplate = `<!DOCTYPE html>
       }
     });
 
+    const submissionList = document.getElementById('submissionList');
+    const moderationSelectAll = document.getElementById('moderationSelectAll');
+    const moderationEnabled = Boolean(submissionList?.querySelector('.moderation-select'));
+    let moderationFocus = -1;
+
+    async function moderateEntries(ids, status, note) {
+      if (!ids.length) {
+        showMessage('Zaznacz co najmniej jeden plik', 'error');
+        return;
+      }
+      const payload = { ids: ids.map(Number), status };
+      if (note !== undefined) {
+        payload.note = note;
+      }
+      try {
+        await fetchJSON('/api/submissions/moderation', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify(payload)
+        });
+        window.location.reload();
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    }
+
+    function moderationEntries() {
+      return submissionList ? Array.from(submissionList.querySelectorAll('.submission-entry')) : [];
+    }
+
+    function focusModerationEntry(index) {
+      const entries = moderationEntries();
+      if (!entries.length) return;
+      moderationFocus = Math.max(0, Math.min(entries.length - 1, index));
+      entries.forEach((entry, i) => entry.classList.toggle('focused', i === moderationFocus));
+      entries[moderationFocus].focus();
+      entries[moderationFocus].scrollIntoView({ block: 'nearest' });
+    }
+
+    async function publishEntries(ids) {
+      const folderSelect = document.getElementById('publishFolderSelect');
+      const modeSelect = document.getElementById('publishModeSelect');
+      if (!folderSelect) return;
+      if (!ids.length) {
+        showMessage('Zaznacz co najmniej jeden plik', 'error');
+        return;
+      }
+      try {
+        const result = await fetchJSON('/api/submissions/publish', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ ids: ids.map(Number), folderId: Number(folderSelect.value), mode: modeSelect?.value || 'copy' })
+        });
+        if (result.skipped.length && !result.published.length) {
+          showMessage(result.skipped[0].reason, 'error');
+          return;
+        }
+        if (result.skipped.length) {
+          showMessage('Dodano ' + result.published.length + ', pominieto ' + result.skipped.length + ': ' + result.skipped.map(item => item.reason).join(', '), 'info');
+          setTimeout(() => window.location.reload(), 2500);
+          return;
+        }
+        window.location.reload();
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    }
+
+    function selectedModerationIds() {
+      return Array.from(submissionList.querySelectorAll('.moderation-select:checked')).map(box => box.value);
+    }
+
+    function editReviewNote(entry) {
+      const note = prompt('Notatka dla tego pliku (pozostaw puste, aby usunac)', entry.dataset.reviewNote || '');
+      if (note === null) return;
+      moderateEntries([entry.dataset.entryId], entry.dataset.status, note);
+    }
+
+    if (moderationEnabled) {
+      submissionList.addEventListener('click', event => {
+        const entry = event.target.closest('.submission-entry');
+        if (!entry) return;
+        const decision = event.target.closest('[data-moderate]');
+        if (decision) {
+          moderateEntries([entry.dataset.entryId], decision.dataset.moderate);
+        } else if (event.target.closest('[data-moderate-note]')) {
+          editReviewNote(entry);
+        } else if (event.target.closest('[data-publish]')) {
+          publishEntries([entry.dataset.entryId]);
+        }
+      });
+
+      moderationSelectAll?.addEventListener('change', () => {
+        submissionList.querySelectorAll('.moderation-select').forEach(box => {
+          box.checked = moderationSelectAll.checked;
+        });
+      });
+
+      document.querySelectorAll('[data-moderate-bulk]').forEach(button => {
+        button.addEventListener('click', () => {
+          moderateEntries(selectedModerationIds(), button.dataset.moderateBulk);
+        });
+      });
+
+      document.getElementById('publishSelected')?.addEventListener('click', () => {
+        publishEntries(selectedModerationIds());
+      });
+
+      document.getElementById('deleteSelected')?.addEventListener('click', async () => {
+        const ids = selectedModerationIds().map(Number);
+        if (!ids.length) {
+          showMessage('Zaznacz pliki do usuniecia', 'error');
+          return;
+        }
+        if (!confirm('Usunac zaznaczone pliki (' + ids.length + ')? Tej operacji nie mozna cofnac.')) return;
+        try {
+          await fetchJSON('/api/submissions/delete', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ ids })
+          });
+          window.location.reload();
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      });
+
+      document.getElementById('archiveSelected')?.addEventListener('click', () => {
+        const ids = selectedModerationIds();
+        if (!ids.length) {
+          showMessage('Zaznacz pliki do pobrania', 'error');
+          return;
+        }
+        window.location.href = '/api/submissions/groups/' + state.activeSubmissionGroupId + '/archive?ids=' + ids.join(',');
+      });
+
+      document.addEventListener('keydown', event => {
+        if (event.ctrlKey || event.metaKey || event.altKey) return;
+        if (event.target.closest('input, textarea, select, .modal.active')) return;
+        const entries = moderationEntries();
+        const current = entries[moderationFocus];
+        switch (event.key.toLowerCase()) {
+          case 'j':
+            focusModerationEntry(moderationFocus + 1);
+            break;
+          case 'k':
+            focusModerationEntry(moderationFocus - 1);
+            break;
+          case 'x':
+            if (current) {
+              const box = current.querySelector('.moderation-select');
+              box.checked = !box.checked;
+            }
+            break;
+          case 'a':
+          case 'r': {
+            const selected = selectedModerationIds();
+            const ids = selected.length ? selected : (current ? [current.dataset.entryId] : []);
+            moderateEntries(ids, event.key.toLowerCase() === 'a' ? 'approved' : 'rejected');
+            break;
+          }
+          case 'p': {
+            const selected = selectedModerationIds();
+            publishEntries(selected.length ? selected : (current ? [current.dataset.entryId] : []));
+            break;
+          }
+          case 'n':
+            if (current) editReviewNote(current);
+            break;
+          default:
+            return;
+        }
+        event.preventDefault();
+      });
+    }
+
+    const receiptClaimForm = document.getElementById('receiptClaimForm');
+    receiptClaimForm?.addEventListener('submit', async event => {
+      event.preventDefault();
+      const code = new FormData(receiptClaimForm).get('code');
+      try {
+        await fetchJSON('/api/submissions/receipt', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ code })
+        });
+        window.location.reload();
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    const invitesPanel = document.getElementById('invitesPanel');
+    const inviteForm = document.getElementById('inviteForm');
+
+    inviteForm?.addEventListener('submit', async event => {
+      event.preventDefault();
+      const names = String(new FormData(inviteForm).get('names') || '').split('\n').map(name => name.trim()).filter(Boolean);
+      if (!names.length) {
+        showMessage('Podaj co najmniej jedno imie', 'error');
+        return;
+      }
+      try {
+        const result = await fetchJSON('/api/submissions/groups/' + invitesPanel.dataset.groupId + '/invites', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ names })
+        });
+        if (result.skipped && result.skipped.length) {
+          alert('Pominieto osoby, ktore juz sa na liscie: ' + result.skipped.join(', '));
+        }
+        window.location.reload();
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    invitesPanel?.addEventListener('click', async event => {
+      const item = event.target.closest('[data-invite-id]');
+      if (!item) return;
+      const inviteURL = '/api/submissions/invites/' + item.dataset.inviteId;
+      try {
+        if (event.target.closest('[data-invite-copy]')) {
+          await navigator.clipboard.writeText(item.dataset.url);
+          showMessage('Skopiowano link dla: ' + item.dataset.name, 'info');
+        } else if (event.target.closest('[data-invite-rename]')) {
+          const name = prompt('Podaj nowe imie', item.dataset.name || '');
+          if (name === null || !name.trim()) return;
+          await fetchJSON(inviteURL, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name: name.trim() })
+          });
+          window.location.reload();
+        } else if (event.target.closest('[data-invite-regenerate]')) {
+          if (!confirm('Wygenerowac nowy kod? Stary link przestanie dzialac.')) return;
+          await fetchJSON(inviteURL, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ regenerate: true })
+          });
+          window.location.reload();
+        } else if (event.target.closest('[data-invite-delete]')) {
+          if (!confirm('Usunac zaproszenie? Przeslane pliki zostana w grupie.')) return;
+          await fetchJSON(inviteURL, { method: 'DELETE' });
+          window.location.reload();
+        }
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    const webhooksPanel = document.getElementById('webhooksPanel');
+    const webhookForm = document.getElementById('webhookForm');
+    if (webhooksPanel && sessionStorage.getItem('webhooksOpen') === '1') {
+      webhooksPanel.open = true;
+    }
+    webhooksPanel?.addEventListener('toggle', () => {
+      sessionStorage.setItem('webhooksOpen', webhooksPanel.open ? '1' : '0');
+    });
+
+    webhookForm?.addEventListener('submit', async event => {
+      event.preventDefault();
+      const formData = new FormData(webhookForm);
+      const events = formData.getAll('events').map(String);
+      if (!events.length) {
+        showMessage('Wybierz co najmniej jedno zdarzenie', 'error');
+        return;
+      }
+      const groupId = formData.get('scope') === 'global' ? 0 : Number(webhooksPanel.dataset.groupId);
+      try {
+        await fetchJSON('/api/webhooks', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ url: String(formData.get('url') || '').trim(), groupId, events })
+        });
+        window.location.reload();
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    webhooksPanel?.addEventListener('click', async event => {
+      const retry = event.target.closest('[data-delivery-retry]');
+      const item = event.target.closest('[data-webhook-id]');
+      try {
+        if (retry) {
+          await fetchJSON('/api/webhooks/deliveries/' + retry.dataset.deliveryRetry + '/retry', { method: 'POST' });
+          window.location.reload();
+          return;
+        }
+        if (!item) return;
+        const hookURL = '/api/webhooks/' + item.dataset.webhookId;
+        if (event.target.closest('[data-webhook-test]')) {
+          await fetchJSON(hookURL + '/test', { method: 'POST' });
+          showMessage('Wyslano zdarzenie testowe', 'info');
+          setTimeout(() => window.location.reload(), 1500);
+        } else if (event.target.closest('[data-webhook-toggle]')) {
+          const button = event.target.closest('[data-webhook-toggle]');
+          await fetchJSON(hookURL, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ active: button.dataset.active !== 'true' })
+          });
+          window.location.reload();
+        } else if (event.target.closest('[data-webhook-delete]')) {
+          if (!confirm('Usunac ten webhook wraz z historia wysylek?')) return;
+          await fetchJSON(hookURL, { method: 'DELETE' });
+          window.location.reload();
+        }
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    const entryReplaceInput = document.getElementById('entryReplaceInput');
+    let entryReplaceTarget = null;
+
+    submissionList?.addEventListener('error', event => {
+      if (event.target.matches?.('img[data-pdf-thumb]')) {
+        event.target.remove();
+      } else if (event.target.matches?.('.pdf-viewer img')) {
+        const note = document.createElement('p');
+        note.className = 'empty';
+        note.textContent = 'Brak podgladu strony ' + event.target.dataset.page;
+        event.target.replaceWith(note);
+      }
+    }, true);
+
+    function togglePDFViewer(entry, button) {
+      const viewer = entry.querySelector('.pdf-viewer');
+      if (!viewer) return;
+      if (!viewer.dataset.loaded) {
+        const pages = Number(viewer.dataset.pages || 0);
+        for (let page = 1; page <= pages; page++) {
+          const img = document.createElement('img');
+          img.loading = 'lazy';
+          img.alt = 'Strona ' + page;
+          img.dataset.page = page;
+          const previewUrl = viewer.dataset.previewUrl;
+          img.src = previewUrl + (previewUrl.includes('?') ? '&' : '?') + 'size=page&page=' + page;
+          viewer.appendChild(img);
+        }
+        viewer.dataset.loaded = '1';
+      }
+      viewer.hidden = !viewer.hidden;
+      button.setAttribute('aria-expanded', String(!viewer.hidden));
+      button.textContent = viewer.hidden ? 'Przegladaj strony' : 'Ukryj strony';
+    }
+
+    submissionList?.addEventListener('click', async event => {
+      const entry = event.target.closest('.submission-entry');
+      if (!entry) return;
+      const viewerButton = event.target.closest('[data-pdf-viewer]');
+      if (viewerButton) {
+        togglePDFViewer(entry, viewerButton);
+        return;
+      }
+      const commentsButton = event.target.closest('[data-entry-comments]');
+      if (commentsButton) {
+        const thread = entry.querySelector('[data-comments-thread]');
+        if (!thread) return;
+        if (!thread.dataset.loaded) {
+          mountComments(thread, { entry: entry.dataset.entryId }, count => {
+            commentsButton.textContent = 'Komentarze (' + count + ')';
+          });
+          thread.dataset.loaded = '1';
+        }
+        thread.hidden = !thread.hidden;
+        commentsButton.setAttribute('aria-expanded', String(!thread.hidden));
+        return;
+      }
+      if (event.target.closest('[data-comments-thread]')) return;
+      const entryURL = '/api/submissions/entries/' + entry.dataset.entryId;
+      try {
+        if (event.target.closest('[data-entry-vote]')) {
+          const button = event.target.closest('[data-entry-vote]');
+          await fetchJSON(entryURL + '/vote', { method: button.dataset.voted === 'true' ? 'DELETE' : 'POST' });
+          window.location.reload();
+        } else if (event.target.closest('[data-entry-rename]')) {
+          const button = event.target.closest('[data-entry-rename]');
+          const name = prompt('Podaj nowe imie', button.dataset.name || '');
+          if (name === null || !name.trim()) return;
+          await fetchJSON(entryURL, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ name: name.trim() })
+          });
+          window.location.reload();
+        } else if (event.target.closest('[data-entry-retitle]')) {
+          const button = event.target.closest('[data-entry-retitle]');
+          const originalName = prompt('Podaj nowa nazwe pliku', button.dataset.original || '');
+          if (originalName === null || !originalName.trim()) return;
+          await fetchJSON(entryURL, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ originalName: originalName.trim() })
+          });
+          window.location.reload();
+        } else if (event.target.closest('[data-entry-delete]')) {
+          if (!confirm('Czy na pewno usunac ten plik? Tej operacji nie mozna cofnac.')) return;
+          await fetchJSON(entryURL, { method: 'DELETE' });
+          window.location.reload();
+        } else if (event.target.closest('[data-entry-withdraw]')) {
+          if (!confirm('Czy na pewno wycofac ten plik? Tej operacji nie mozna cofnac.')) return;
+          await fetchJSON(entryURL, { method: 'DELETE' });
+          window.location.reload();
+        } else if (event.target.closest('[data-entry-replace]') && entryReplaceInput) {
+          entryReplaceTarget = entryURL;
+          entryReplaceInput.value = '';
+          entryReplaceInput.click();
+        }
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    submissionList?.addEventListener('change', async event => {
+      const select = event.target.closest('[data-jury-score]');
+      const entry = event.target.closest('.submission-entry');
+      if (!select || !entry) return;
+      try {
+        await fetchJSON('/api/submissions/entries/' + entry.dataset.entryId + '/score', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ score: Number(select.value) })
+        });
+        showMessage('Zapisano ocene', 'info');
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    entryReplaceInput?.addEventListener('change', async () => {
+      const file = entryReplaceInput.files[0];
+      if (!file || !entryReplaceTarget) return;
+      const maxBytes = Number(submissionUploadForm?.dataset.maxBytes || 0);
+      if (maxBytes && file.size > maxBytes) {
+        showMessage('Plik jest za duzy. Limit to ' + submissionUploadForm.dataset.maxMb + ' MB', 'error');
+        return;
+      }
+      const formData = new FormData();
+      formData.append('file', file);
+      try {
+        await fetchJSON(entryReplaceTarget + '/replace', { method: 'POST', body: formData });
+        window.location.reload();
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
     function openModal(modal) {
       modal?.classList.add('active');
     }
@@ -1560,6 +3454,7 @@ const PageTemplate = `<!DOCTYPE html>
       fullImage.src = src;
       fullImage.alt = alt;
       resetView();
+      hideImageComments();
       if (zoomControls) {
         zoomControls.hidden = false;
       }
@@ -1568,6 +3463,7 @@ const PageTemplate = `<!DOCTYPE html>
 
     function closeFullscreen() {
       backdrop?.classList.remove('active');
+      hideImageComments();
       if (zoomControls) {
         zoomControls.hidden = true;
       }
@@ -1578,6 +3474,50 @@ const PageTemplate = `<!DOCTYPE html>
       }
     }
 
+    const fullscreenCommentsToggle = document.getElementById('fullscreenCommentsToggle');
+    const fullscreenComments = document.getElementById('fullscreenComments');
+    let fullscreenTile = null;
+
+    function setImageCommentCount(tile, count) {
+      tile.dataset.comments = String(count);
+      const badge = tile.querySelector('[data-comment-count]');
+      if (badge) {
+        badge.hidden = !count;
+        badge.textContent = 'Komentarze: ' + count;
+      }
+      if (fullscreenCommentsToggle && fullscreenTile === tile) {
+        fullscreenCommentsToggle.textContent = 'Komentarze (' + count + ')';
+      }
+    }
+
+    function showImageComments(tile) {
+      if (!fullscreenCommentsToggle || !tile) return;
+      fullscreenTile = tile;
+      fullscreenCommentsToggle.hidden = false;
+      fullscreenCommentsToggle.textContent = 'Komentarze (' + (tile.dataset.comments || 0) + ')';
+    }
+
+    function hideImageComments() {
+      fullscreenTile = null;
+      if (fullscreenCommentsToggle) {
+        fullscreenCommentsToggle.hidden = true;
+      }
+      if (fullscreenComments) {
+        fullscreenComments.hidden = true;
+        fullscreenComments.replaceChildren();
+      }
+    }
+
+    fullscreenCommentsToggle?.addEventListener('click', () => {
+      const tile = fullscreenTile;
+      if (!tile) return;
+      fullscreenComments.hidden = !fullscreenComments.hidden;
+      if (!fullscreenComments.hidden && !fullscreenComments.childElementCount) {
+        const folder = tile.closest('.gallery')?.dataset.folder || '';
+        mountComments(fullscreenComments, { folder, name: tile.dataset.name }, count => setImageCommentCount(tile, count));
+      }
+    });
+
     document.querySelectorAll('.thumb').forEach(btn => {
       btn.addEventListener('click', () => {
         const src = btn.dataset.src;
@@ -1586,6 +3526,8 @@ const PageTemplate = `<!DOCTYPE html>
           closeFullscreen();
         } else {
           openFullscreen(src, alt);
+          showImageComments(btn.closest('.tile'));
+          trackShareEvent('open', alt);
         }
       });
     });
@@ -1626,6 +3568,158 @@ const PageTemplate = `<!DOCTYPE html>
       }
       return data;
     }
+
+    // mountComments renders the thread of an image ({folder, name}) or a
+    // submission entry ({entry}) and reports the visible comment count.
+    function mountComments(container, target, onCount) {
+      const post = async payload => {
+        await fetchJSON('/api/comments', {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ ...target, entry: Number(target.entry || 0), ...payload })
+        });
+        await load();
+      };
+
+      const commentForm = (data, parentId) => {
+        const form = document.createElement('form');
+        form.className = 'comment-form';
+        if (!data.canModerate) {
+          const author = document.createElement('input');
+          author.name = 'author';
+          author.maxLength = 60;
+          author.required = true;
+          author.placeholder = 'Twoje imie';
+          form.appendChild(author);
+        }
+        const body = document.createElement('textarea');
+        body.name = 'body';
+        body.maxLength = 2000;
+        body.rows = parentId ? 2 : 3;
+        body.required = true;
+        body.placeholder = parentId ? 'Twoja odpowiedz' : 'Dodaj komentarz';
+        const submit = document.createElement('button');
+        submit.type = 'submit';
+        submit.className = 'primary';
+        submit.textContent = parentId ? 'Odpowiedz' : 'Wyslij';
+        form.append(body, submit);
+        form.addEventListener('submit', async event => {
+          event.preventDefault();
+          submit.disabled = true;
+          try {
+            await post({ parentId, author: form.elements['author']?.value || '', body: body.value });
+          } catch (err) {
+            showMessage(err.message, 'error');
+            submit.disabled = false;
+          }
+        });
+        return form;
+      };
+
+      const moderate = async (comment, options) => {
+        try {
+          await fetchJSON('/api/comments/' + comment.id, options);
+          await load();
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      };
+
+      const renderComment = (comment, data) => {
+        const item = document.createElement('div');
+        item.className = 'comment' + (comment.hidden ? ' comment-hidden' : '');
+        item.style.marginLeft = (comment.depth * 1.25) + 'rem';
+        if (comment.hidden && !data.canModerate) {
+          item.textContent = 'Komentarz ukryty przez moderatora.';
+          return item;
+        }
+        const header = document.createElement('div');
+        header.className = 'comment-header';
+        if (comment.admin) {
+          const badge = document.createElement('span');
+          badge.className = 'comment-admin';
+          badge.textContent = 'Administrator';
+          header.appendChild(badge);
+        } else {
+          const author = document.createElement('strong');
+          author.textContent = comment.author;
+          header.appendChild(author);
+        }
+        const time = document.createElement('small');
+        time.textContent = comment.createdAt + (comment.hidden ? ' • ukryty' : '');
+        header.appendChild(time);
+        const body = document.createElement('p');
+        body.className = 'comment-body';
+        body.textContent = comment.body;
+        const actions = document.createElement('div');
+        actions.className = 'comment-actions';
+        const action = (label, handler) => {
+          const button = document.createElement('button');
+          button.type = 'button';
+          button.className = 'ghost';
+          button.textContent = label;
+          button.addEventListener('click', handler);
+          actions.appendChild(button);
+          return button;
+        };
+        if (data.canComment) {
+          action('Odpowiedz', () => {
+            const open = item.querySelector('.comment-form');
+            if (open) {
+              open.remove();
+              return;
+            }
+            const form = commentForm(data, comment.id);
+            item.appendChild(form);
+            form.querySelector('textarea')?.focus();
+          });
+        }
+        if (data.canModerate) {
+          action(comment.hidden ? 'Pokaz' : 'Ukryj', () => moderate(comment, {
+            method: 'PATCH',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({ hidden: !comment.hidden })
+          }));
+          action('Usun', () => {
+            if (!confirm('Usunac komentarz razem z odpowiedziami?')) return;
+            moderate(comment, { method: 'DELETE' });
+          });
+        }
+        item.append(header, body, actions);
+        return item;
+      };
+
+      async function load() {
+        let data;
+        try {
+          data = await fetchJSON('/api/comments?' + new URLSearchParams(target));
+        } catch (err) {
+          container.textContent = err.message;
+          return;
+        }
+        onCount?.(data.count || 0);
+        const list = document.createElement('div');
+        list.className = 'comment-list';
+        (data.comments || []).forEach(comment => list.appendChild(renderComment(comment, data)));
+        if (!list.childElementCount) {
+          const empty = document.createElement('p');
+          empty.className = 'empty';
+          empty.textContent = 'Brak komentarzy.';
+          list.appendChild(empty);
+        }
+        container.replaceChildren(list);
+        if (data.canComment) {
+          container.appendChild(commentForm(data, 0));
+        } else {
+          const note = document.createElement('p');
+          note.className = 'comment-note';
+          note.textContent = 'Komentowac moga tylko administratorzy.';
+          container.appendChild(note);
+        }
+      }
+
+      load();
+    }
     if (loginButton) {
       loginButton.addEventListener('click', () => {
         openModal(loginModal);
@@ -1799,7 +3893,97 @@ const PageTemplate = `<!DOCTYPE html>
       });
     });
 
-    document.querySelectorAll('.image-rename-btn').forEach(btn => {
+    const imageShareModal = document.getElementById('imageShareModal');
+    const imageShareDetails = document.getElementById('imageShareDetails');
+    const imageShareLinkValue = document.getElementById('imageShareLinkValue');
+    const imageShareExpires = document.getElementById('imageShareExpires');
+    const imageShareSave = document.getElementById('imageShareSave');
+    const imageShareRevoke = document.getElementById('imageShareRevoke');
+    const imageShareRemaining = document.getElementById('imageShareRemaining');
+    let imageShareTarget = null;
+
+    function renderImageShare(data) {
+      const shared = Boolean(data.shared);
+      imageShareDetails.hidden = !shared;
+      document.getElementById('imageShareEmpty').hidden = shared;
+      imageShareRevoke.hidden = !shared;
+      imageShareSave.textContent = shared ? 'Zapisz' : 'Utworz link';
+      imageShareLinkValue.textContent = data.url || '';
+      imageShareLinkValue.dataset.link = data.url || '';
+      document.getElementById('imageShareViews').textContent = String(data.views || 0);
+      imageShareExpires.value = isoToLocalInput(data.sharedExpiresAt);
+      imageShareRemaining.hidden = !data.shareRemaining;
+      imageShareRemaining.textContent = (data.shareExpired ? 'Link wygasl: ' : '') + (data.shareRemaining || '');
+      imageShareRemaining.classList.toggle('expired', Boolean(data.shareExpired));
+    }
+
+    async function saveImageShare(extra = {}) {
+      if (!imageShareTarget) return;
+      try {
+        const data = await fetchJSON('/api/images/share', {
+          method: 'POST',
+          headers: {'Content-Type': 'application/json'},
+          body: JSON.stringify({...imageShareTarget, expiresAt: localInputToISO(imageShareExpires.value), ...extra})
+        });
+        renderImageShare(data);
+        showMessage('Zapisano link');
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    }
+
+    document.querySelectorAll('.image-share-btn').forEach(btn => {
+      btn.addEventListener('click', async event => {
+        event.preventDefault();
+        event.stopPropagation();
+        imageShareTarget = {folder: btn.dataset.folder, name: btn.dataset.name};
+        document.getElementById('imageShareName').textContent = btn.dataset.name || '';
+        try {
+          const params = new URLSearchParams(imageShareTarget);
+          renderImageShare(await fetchJSON('/api/images/share?' + params.toString()));
+          openModal(imageShareModal);
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      });
+    });
+
+    imageShareSave?.addEventListener('click', () => saveImageShare());
+    document.getElementById('imageShareRegenerate')?.addEventListener('click', () => {
+      if (confirm('Stary link przestanie dzialac. Kontynuowac?')) {
+        saveImageShare({regenerate: true});
+      }
+    });
+    imageShareRevoke?.addEventListener('click', async () => {
+      if (!imageShareTarget || !confirm('Usunac link do tego obrazu?')) return;
+      try {
+        renderImageShare(await fetchJSON('/api/images/share', {
+          method: 'DELETE',
+          headers: {'Content-Type': 'application/json'},
+          body: JSON.stringify(imageShareTarget)
+        }));
+        showMessage('Usunieto link');
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+    document.getElementById('imageShareQr')?.addEventListener('click', () => {
+      if (!imageShareTarget) return;
+      openQrModal('/api/images/share/qr?' + new URLSearchParams(imageShareTarget).toString());
+    });
+    document.getElementById('imageShareCopy')?.addEventListener('click', async () => {
+      const link = imageShareLinkValue.dataset.link;
+      if (!link) return;
+      try {
+        await navigator.clipboard.writeText(link);
+        showMessage('Skopiowano link');
+      } catch (_) {
+        showMessage('Nie udalo sie skopiowac linku', 'error');
+      }
+    });
+    document.getElementById('imageShareClose')?.addEventListener('click', () => closeModal(imageShareModal));
+
+    document.querySelectorAll('.image-rename-btn:not(.image-share-btn)').forEach(btn => {
       btn.addEventListener('click', async event => {
         event.preventDefault();
         event.stopPropagation();
@@ -1911,9 +4095,41 @@ const PageTemplate = `<!DOCTYPE html>
       shareLinkValue.textContent = link || 'Brak linku';
       shareLinkValue.dataset.link = link;
       shareViewsValue.textContent = String(data.sharedViews ?? 0);
+      if (shareExpiresInput) {
+        shareExpiresInput.value = isoToLocalInput(data.sharedExpiresAt);
+      }
+      if (shareMaxViewsInput) {
+        shareMaxViewsInput.value = data.sharedMaxViews ? String(data.sharedMaxViews) : '';
+      }
+      if (sharePasswordInput) {
+        sharePasswordInput.value = '';
+        sharePasswordInput.placeholder = data.passwordProtected ? 'pozostaw puste, aby nie zmieniac' : 'brak hasla';
+      }
+      if (sharePasswordLabel) {
+        sharePasswordLabel.textContent = data.passwordProtected ? 'Nowe haslo (link jest chroniony)' : 'Haslo do linku (opcjonalnie)';
+      }
+      if (shareRemovePasswordLabel) {
+        shareRemovePasswordLabel.hidden = !data.passwordProtected;
+      }
+      if (shareRemovePasswordInput) {
+        shareRemovePasswordInput.checked = false;
+      }
+      if (shareRemainingValue) {
+        shareRemainingValue.hidden = !data.shareRemaining;
+        shareRemainingValue.textContent = (data.shareExpired ? 'Link wygasl: ' : '') + (data.shareRemaining || '');
+        shareRemainingValue.classList.toggle('expired', Boolean(data.shareExpired));
+      }
+      const folderLinks = shareDetails.querySelector('[data-share-links]');
+      if (folderLinks && data.id && folderLinks.dataset.targetId !== String(data.id)) {
+        folderLinks.dataset.targetId = String(data.id);
+        folderLinks.reloadShareLinks?.();
+      }
       copyShareLink.disabled = !link;
       regenerateLinkButton.disabled = !data.id;
       downloadQrButton.disabled = !data.id;
+      if (folderAnalyticsButton) {
+        folderAnalyticsButton.disabled = !data.id;
+      }
     }
 
     function currentFolderData() {
@@ -1923,7 +4139,13 @@ const PageTemplate = `<!DOCTYPE html>
         visibility: state.activeFolderVisibility || 'private',
         sharedToken: state.activeFolderShareToken || '',
         shareUrl: state.activeFolderShareUrl || '',
-        sharedViews: state.activeFolderShareViews || 0
+        sharedViews: state.activeFolderShareViews || 0,
+        sharedExpiresAt: state.activeFolderShareExpires || '',
+        sharedMaxViews: state.activeFolderShareMaxViews || 0,
+        shareRemaining: state.activeFolderShareRemaining || '',
+        shareExpired: state.activeFolderShareExpired,
+        passwordProtected: state.activeFolderPasswordProtected,
+        commentsOpen: state.activeFolderCommentsOpen
       };
     }
 
@@ -1941,10 +4163,54 @@ const PageTemplate = `<!DOCTYPE html>
       folderSettingsForm?.querySelectorAll('input[name="visibility"]').forEach(radio => {
         radio.checked = radio.value === data.visibility;
       });
+      if (folderCommentsOpenInput) {
+        folderCommentsOpenInput.checked = data.commentsOpen;
+      }
       updateShareDetails({...data, visibility: data.visibility});
+      updateEmbedDetails(data);
       openModal(folderSettingsModal);
     });
 
+    function updateEmbedDetails(data) {
+      if (!embedDetails) return;
+      let path = '';
+      if (data.visibility === 'public' && state.activeFolder) {
+        path = '/embed/' + encodeURIComponent(state.activeFolder);
+      } else if (data.visibility === 'shared' && data.sharedToken) {
+        path = '/embed/shared/' + encodeURIComponent(data.sharedToken);
+      }
+      embedDetails.hidden = !path;
+      if (!path) return;
+      const params = new URLSearchParams();
+      if (embedLayoutInput.value !== 'grid') {
+        params.set('layout', embedLayoutInput.value);
+      }
+      const columns = Math.min(6, Math.max(1, Number(embedColumnsInput.value) || 3));
+      if (columns !== 3) {
+        params.set('columns', String(columns));
+      }
+      if (embedCaptionsInput.checked) {
+        params.set('captions', '1');
+      }
+      const query = params.toString();
+      const src = window.location.origin + path + (query ? '?' + query : '');
+      const title = (data.name || 'Galeria').replace(/&/g, '&amp;').replace(/"/g, '&quot;').replace(/</g, '&lt;');
+      embedCodeValue.textContent = '<iframe src="' + src + '" width="800" height="600" title="' + title + '" style="border:0" loading="lazy" allowfullscreen></iframe>';
+    }
+
+    [embedLayoutInput, embedColumnsInput, embedCaptionsInput].forEach(input => {
+      input?.addEventListener('change', () => updateEmbedDetails(currentFolderData()));
+    });
+
+    copyEmbedCode?.addEventListener('click', async () => {
+      try {
+        await navigator.clipboard.writeText(embedCodeValue.textContent);
+        showMessage('Skopiowano kod');
+      } catch (_) {
+        showMessage('Nie udalo sie skopiowac kodu', 'error');
+      }
+    });
+
     folderSettingsCancel?.addEventListener('click', () => closeModal(folderSettingsModal));
 
     folderSettingsForm?.addEventListener('submit', async event => {
@@ -1952,6 +4218,13 @@ const PageTemplate = `<!DOCTYPE html>
       if (!state.activeFolderId) return;
       const visibility = folderSettingsForm.elements['visibility'].value;
       const payload = { visibility };
+      if (folderCommentsOpenInput) {
+        payload.commentsOpen = folderCommentsOpenInput.checked;
+      }
+      if (visibility === 'shared') {
+        Object.assign(payload, shareLimitsPayload(shareExpiresInput, shareMaxViewsInput));
+        Object.assign(payload, sharePasswordPayload(sharePasswordInput, shareRemovePasswordInput));
+      }
       if (folderNameInput) {
         const nameValue = folderNameInput.value.trim();
         if (!nameValue) {
@@ -1993,9 +4266,258 @@ const PageTemplate = `<!DOCTYPE html>
 
     downloadQrButton?.addEventListener('click', () => {
       if (!state.activeFolderId) return;
-      window.open('/api/folders/' + state.activeFolderId + '/qr', '_blank');
+      openQrModal('/api/folders/' + state.activeFolderId + '/qr');
+    });
+
+    function renderAnalytics(data) {
+      document.getElementById('analyticsTotal').textContent = String(data.totalVisits || 0);
+      document.getElementById('analyticsUnique').textContent = String(data.uniqueVisits || 0);
+      document.getElementById('analyticsVisitors').textContent = String(data.uniqueVisitors || 0);
+
+      const chart = document.getElementById('analyticsChart');
+      const days = data.days || [];
+      const max = Math.max(1, ...days.map(day => day.visits));
+      chart.replaceChildren(...days.map(day => {
+        const bar = document.createElement('div');
+        bar.className = 'analytics-bar';
+        bar.style.height = (day.visits / max * 100) + '%';
+        bar.title = day.date + ': ' + day.visits + ' wejsc, ' + day.unique + ' unikalnych';
+        const unique = document.createElement('span');
+        unique.style.height = (day.visits ? day.unique / day.visits * 100 : 0) + '%';
+        bar.appendChild(unique);
+        return bar;
+      }));
+
+      const listItem = text => {
+        const li = document.createElement('li');
+        li.textContent = text;
+        return li;
+      };
+      const topImages = document.getElementById('analyticsTopImages');
+      topImages.replaceChildren(...(data.topImages || []).map(image =>
+        listItem(image.name + ' - otwarcia: ' + image.opens + ', pobrania: ' + image.downloads)));
+      if (!topImages.children.length) {
+        topImages.appendChild(listItem('Brak danych.'));
+      }
+      const recent = document.getElementById('analyticsRecent');
+      recent.replaceChildren(...(data.recent || []).map(visit => {
+        const parts = [visit.time, visit.link];
+        if (!visit.unique) parts.push('powtorne');
+        if (visit.referrer) parts.push('z ' + visit.referrer);
+        if (visit.userAgent) parts.push(visit.userAgent);
+        return listItem(parts.join(' • '));
+      }));
+      if (!recent.children.length) {
+        recent.appendChild(listItem('Brak wejsc.'));
+      }
+    }
+
+    folderAnalyticsButton?.addEventListener('click', async () => {
+      if (!state.activeFolderId) return;
+      try {
+        const data = await fetchJSON('/api/folders/' + state.activeFolderId + '/analytics');
+        renderAnalytics(data);
+        openModal(folderAnalyticsModal);
+      } catch (err) {
+        showMessage(err.message, 'error');
+      }
+    });
+
+    folderAnalyticsClose?.addEventListener('click', () => closeModal(folderAnalyticsModal));
+
+    function trackShareEvent(action, image) {
+      if (!state.sharedMode || !state.activeFolderShareToken || !image) return;
+      const body = JSON.stringify({token: state.activeFolderShareToken, image, action});
+      if (navigator.sendBeacon) {
+        navigator.sendBeacon('/api/shared/events', new Blob([body], {type: 'application/json'}));
+      } else {
+        fetch('/api/shared/events', {method: 'POST', headers: {'Content-Type': 'application/json'}, body, keepalive: true}).catch(() => {});
+      }
+    }
+
+    document.querySelectorAll('.download-link').forEach(link => {
+      link.addEventListener('click', () => trackShareEvent('download', link.closest('.tile')?.dataset.name));
+    });
+
+    const qrModal = document.getElementById('qrModal');
+    const qrForm = document.getElementById('qrForm');
+
+    function openQrModal(url) {
+      if (!qrModal || !url) return;
+      qrModal.dataset.url = url;
+      openModal(qrModal);
+    }
+
+    qrForm?.addEventListener('submit', event => {
+      event.preventDefault();
+      const url = qrModal.dataset.url;
+      if (!url) return;
+      const formData = new FormData(qrForm);
+      const params = new URLSearchParams();
+      ['size', 'level', 'fg', 'bg', 'format'].forEach(name => params.set(name, String(formData.get(name) || '')));
+      if (formData.get('logo')) {
+        params.set('logo', '1');
+      }
+      window.open(url + (url.includes('?') ? '&' : '?') + params.toString(), '_blank');
+    });
+
+    document.getElementById('qrCancel')?.addEventListener('click', () => closeModal(qrModal));
+    document.getElementById('submissionQrButton')?.addEventListener('click', event => {
+      openQrModal(event.currentTarget.dataset.qrUrl);
     });
 
+    function shareLinkItem(link, reload, qrURL) {
+      const item = document.createElement('div');
+      item.className = 'share-link-item' + (link.enabled && !link.shareExpired ? '' : ' inactive');
+
+      const title = document.createElement('strong');
+      title.textContent = link.label;
+      item.appendChild(title);
+
+      const row = document.createElement('div');
+      row.className = 'share-link-row';
+      const code = document.createElement('code');
+      code.textContent = link.url;
+      row.appendChild(code);
+
+      const copyButton = document.createElement('button');
+      copyButton.type = 'button';
+      copyButton.className = 'ghost';
+      copyButton.textContent = 'Kopiuj';
+      copyButton.addEventListener('click', async () => {
+        try {
+          await navigator.clipboard.writeText(link.url);
+          showMessage('Skopiowano link');
+        } catch (_) {
+          showMessage('Nie udalo sie skopiowac linku', 'error');
+        }
+      });
+      row.appendChild(copyButton);
+
+      const toggleButton = document.createElement('button');
+      toggleButton.type = 'button';
+      toggleButton.className = 'ghost';
+      toggleButton.textContent = link.enabled ? 'Wylacz' : 'Wlacz';
+      toggleButton.addEventListener('click', async () => {
+        try {
+          await fetchJSON('/api/share-links/' + link.id, {
+            method: 'PATCH',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({enabled: !link.enabled})
+          });
+          reload();
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      });
+      row.appendChild(toggleButton);
+
+      const qrButton = document.createElement('button');
+      qrButton.type = 'button';
+      qrButton.className = 'ghost';
+      qrButton.textContent = 'QR';
+      qrButton.addEventListener('click', () => openQrModal(qrURL + '?link=' + link.id));
+      row.appendChild(qrButton);
+
+      const deleteButton = document.createElement('button');
+      deleteButton.type = 'button';
+      deleteButton.className = 'ghost';
+      deleteButton.textContent = 'Usun';
+      deleteButton.addEventListener('click', async () => {
+        if (!confirm('Usunac link "' + link.label + '"?')) return;
+        try {
+          await fetchJSON('/api/share-links/' + link.id, { method: 'DELETE' });
+          reload();
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      });
+      row.appendChild(deleteButton);
+      item.appendChild(row);
+
+      const permissions = [];
+      if (link.allowView) permissions.push('ogladanie');
+      if (link.allowDownload) permissions.push('pobieranie');
+      if (link.allowUpload) permissions.push('przesylanie');
+      const details = ['Wejscia: ' + link.views, 'Uprawnienia: ' + permissions.join(', ')];
+      if (!link.enabled) {
+        details.push('wylaczony');
+      } else if (link.shareExpired) {
+        details.push('wygasl');
+      }
+      if (link.shareRemaining) {
+        details.push(link.shareRemaining);
+      }
+      const meta = document.createElement('small');
+      meta.textContent = details.join(' • ');
+      item.appendChild(meta);
+      return item;
+    }
+
+    function setupShareLinks(container) {
+      const list = container.querySelector('[data-share-links-list]');
+      const field = name => container.querySelector('[data-link-field="' + name + '"]');
+      const targetURL = () => {
+        const id = container.dataset.targetId;
+        if (!id) return '';
+        return (container.dataset.kind === 'group' ? '/api/submissions/groups/' : '/api/folders/') + id;
+      };
+      const endpoint = () => targetURL() ? targetURL() + '/links' : '';
+
+      async function reload() {
+        const url = endpoint();
+        if (!url || !list) return;
+        try {
+          const data = await fetchJSON(url);
+          list.replaceChildren(...(data.links || []).map(link => shareLinkItem(link, reload, targetURL() + '/qr')));
+          if (!list.children.length) {
+            const empty = document.createElement('small');
+            empty.textContent = 'Brak dodatkowych linkow.';
+            list.appendChild(empty);
+          }
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      }
+
+      container.querySelector('[data-share-link-create]')?.addEventListener('click', async () => {
+        const url = endpoint();
+        if (!url) return;
+        const label = field('label')?.value.trim() || '';
+        if (!label) {
+          showMessage('Podaj nazwe linku', 'error');
+          return;
+        }
+        const limits = shareLimitsPayload(field('expiresAt'), field('maxViews'));
+        try {
+          await fetchJSON(url, {
+            method: 'POST',
+            headers: {'Content-Type': 'application/json'},
+            body: JSON.stringify({
+              label,
+              expiresAt: limits.sharedExpiresAt,
+              maxViews: limits.sharedMaxViews,
+              allowView: Boolean(field('allowView')?.checked),
+              allowDownload: Boolean(field('allowDownload')?.checked),
+              allowUpload: Boolean(field('allowUpload')?.checked)
+            })
+          });
+          field('label').value = '';
+          field('expiresAt').value = '';
+          field('maxViews').value = '';
+          showMessage('Dodano link');
+          reload();
+        } catch (err) {
+          showMessage(err.message, 'error');
+        }
+      });
+
+      container.reloadShareLinks = reload;
+      reload();
+    }
+
+    document.querySelectorAll('[data-share-links]').forEach(setupShareLinks);
+
     copyShareLink?.addEventListener('click', async () => {
       const link = shareLinkValue?.dataset.link;
       if (!link) {
@@ -2013,3 +4535,265 @@ const PageTemplate = `<!DOCTYPE html>
 </body>
 </html>
 `
+
+const ImageShareTemplate = `<!DOCTYPE html>
+<html lang="pl">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <title>{{if .Expired}}Link wygasl{{else}}{{.Name}}{{end}}</title>
+  <link rel="icon" type="image/x-icon" href="/favicon.ico">
+` + previewMetaTags + `  <style>
+    :root {
+      color-scheme: dark;
+      font-family: 'Inter', 'Segoe UI', system-ui, sans-serif;
+    }
+    * {
+      box-sizing: border-box;
+    }
+    body {
+      margin: 0;
+      min-height: 100vh;
+      display: flex;
+      flex-direction: column;
+      align-items: center;
+      justify-content: center;
+      gap: 1rem;
+      padding: 1.5rem;
+      background: #0f172a;
+      color: #e2e8f0;
+    }
+    img {
+      max-width: 100%;
+      max-height: 80vh;
+      border-radius: 12px;
+      box-shadow: 0 20px 45px rgba(0, 0, 0, 0.45);
+    }
+    .meta {
+      display: flex;
+      align-items: center;
+      gap: 1rem;
+      flex-wrap: wrap;
+      justify-content: center;
+      font-size: 0.95rem;
+    }
+    .meta span {
+      color: #94a3b8;
+    }
+    a.download {
+      padding: 0.5rem 1.1rem;
+      border-radius: 999px;
+      background: #3b82f6;
+      color: #fff;
+      text-decoration: none;
+      font-weight: 600;
+    }
+    .expired {
+      text-align: center;
+      color: #94a3b8;
+    }
+  </style>
+</head>
+<body>
+  {{if .Expired}}
+  <div class="expired">
+    <h1>Link wygasl</h1>
+    <p>Popros wlasciciela galerii o nowy link do tego obrazu.</p>
+  </div>
+  {{else}}
+  <img src="{{.ImageURL}}" alt="{{.Name}}">
+  <div class="meta">
+    <strong>{{.Name}}</strong>
+    <span>{{.FolderName}}</span>
+    <a class="download" href="{{.DownloadURL}}">Pobierz</a>
+  </div>
+  {{end}}
+</body>
+</html>
+`
+
+const EmbedTemplate = `<!DOCTYPE html>
+<html lang="pl">
+<head>
+  <meta charset="UTF-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1.0">
+  <meta name="robots" content="noindex">
+  <title>{{if .Title}}{{.Title}}{{else}}Galeria zdjec{{end}}</title>
+  <style>
+    :root {
+      color-scheme: light dark;
+      font-family: 'Inter', 'Segoe UI', system-ui, sans-serif;
+    }
+    * {
+      box-sizing: border-box;
+    }
+    body {
+      margin: 0;
+      padding: 0.5rem;
+      background: transparent;
+      color: #1f2933;
+    }
+    .embed-header {
+      display: flex;
+      justify-content: space-between;
+      align-items: baseline;
+      gap: 1rem;
+      margin: 0 0 0.5rem;
+      font-size: 0.9rem;
+    }
+    .embed-header a {
+      color: #3b82f6;
+      text-decoration: none;
+      font-weight: 600;
+    }
+    .embed-gallery {
+      --columns: 3;
+      --gap: 0.4rem;
+    }
+    .embed-gallery.layout-grid {
+      display: grid;
+      grid-template-columns: repeat(var(--columns), minmax(0, 1fr));
+      gap: var(--gap);
+    }
+    .embed-gallery.layout-grid img {
+      aspect-ratio: 1;
+      object-fit: cover;
+    }
+    .embed-gallery.layout-masonry {
+      columns: var(--columns);
+      column-gap: var(--gap);
+    }
+    .embed-gallery.layout-masonry figure {
+      break-inside: avoid;
+      margin-bottom: var(--gap);
+    }
+    .embed-gallery.layout-strip {
+      display: grid;
+      grid-auto-flow: column;
+      grid-auto-columns: calc((100% - (var(--columns) - 1) * var(--gap)) / var(--columns));
+      gap: var(--gap);
+      overflow-x: auto;
+      scroll-snap-type: x mandatory;
+    }
+    .embed-gallery.layout-strip figure {
+      scroll-snap-align: start;
+    }
+    .embed-gallery.layout-strip img {
+      aspect-ratio: 4 / 3;
+      object-fit: cover;
+    }
+    figure {
+      margin: 0;
+    }
+    figure button {
+      display: block;
+      width: 100%;
+      padding: 0;
+      border: 0;
+      background: none;
+      cursor: zoom-in;
+    }
+    figure img {
+      display: block;
+      width: 100%;
+      border-radius: 6px;
+    }
+    figcaption {
+      margin-top: 0.2rem;
+      font-size: 0.75rem;
+      color: #64748b;
+      overflow: hidden;
+      text-overflow: ellipsis;
+      white-space: nowrap;
+    }
+    .embed-message {
+      padding: 2rem 1rem;
+      text-align: center;
+      color: #64748b;
+    }
+    .lightbox {
+      position: fixed;
+      inset: 0;
+      display: flex;
+      flex-direction: column;
+      align-items: center;
+      justify-content: center;
+      gap: 0.75rem;
+      background: rgba(15, 23, 42, 0.92);
+      color: #e2e8f0;
+      font-size: 0.85rem;
+    }
+    .lightbox[hidden] {
+      display: none;
+    }
+    .lightbox img {
+      max-width: 96vw;
+      max-height: 85vh;
+      border-radius: 6px;
+    }
+    .lightbox a {
+      color: #93c5fd;
+    }
+    @media (prefers-color-scheme: dark) {
+      body {
+        color: #e2e8f0;
+      }
+    }
+  </style>
+</head>
+<body>
+  <div class="embed-header">
+    <strong>{{.Title}}</strong>
+    {{if .PageURL}}<a href="{{.PageURL}}" target="_blank" rel="noopener">Otworz galerie</a>{{end}}
+  </div>
+  {{if .Message}}
+  <p class="embed-message">{{.Message}}</p>
+  {{else}}
+  <div class="embed-gallery layout-{{.Layout}}" style="--columns: {{.Columns}}">
+    {{range .Images}}
+    <figure>
+      <button type="button" data-src="{{.URL}}" data-name="{{.Name}}"><img src="{{.URL}}" alt="{{.Name}}" loading="lazy"></button>
+      {{if $.Captions}}<figcaption>{{.Name}}{{if .Author}} - fot. {{.Author}}{{end}}</figcaption>{{end}}
+    </figure>
+    {{end}}
+  </div>
+  <div class="lightbox" id="lightbox" hidden>
+    <img alt="">
+    <div>
+      <span id="lightboxName"></span>
+      {{if .AllowDownload}}<a id="lightboxDownload" href="" download>Pobierz</a>{{end}}
+    </div>
+  </div>
+  <script>
+    (() => {
+      const lightbox = document.getElementById('lightbox');
+      const image = lightbox.querySelector('img');
+      const name = document.getElementById('lightboxName');
+      const download = document.getElementById('lightboxDownload');
+      document.querySelectorAll('.embed-gallery button').forEach(button => {
+        button.addEventListener('click', () => {
+          image.src = button.dataset.src;
+          image.alt = button.dataset.name;
+          name.textContent = button.dataset.name;
+          if (download) {
+            download.href = button.dataset.src;
+          }
+          lightbox.hidden = false;
+        });
+      });
+      lightbox.addEventListener('click', event => {
+        if (event.target !== download) {
+          lightbox.hidden = true;
+        }
+      });
+      document.addEventListener('keydown', event => {
+        if (event.key === 'Escape') {
+          lightbox.hidden = true;
+        }
+      });
+    })();
+  </script>
+  {{end}}
+</body>
+</html>
+`
@@ -1,21 +1,16 @@
 package app
 
 import (
+	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"strings"
 )
 
+// clientIP returns the address of the connecting client. Forwarding headers
+// are applied earlier by WithClientAddr, and only for trusted proxies.
 func clientIP(r *http.Request) string {
-	if ip := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); ip != "" {
-		return ip
-	}
-	if ip := strings.TrimSpace(r.Header.Get("X-Forwarded-For")); ip != "" {
-		if comma := strings.Index(ip, ","); comma >= 0 {
-			ip = ip[:comma]
-		}
-		return strings.TrimSpace(ip)
-	}
 	host := strings.TrimSpace(r.RemoteAddr)
 	if host == "" {
 		return "-"
@@ -26,6 +21,92 @@ func clientIP(r *http.Request) string {
 	return host
 }
 
+// trustedProxies lists the reverse proxies whose forwarding headers are
+// believed.
+type trustedProxies []*net.IPNet
+
+func parseTrustedProxies(list []string) (trustedProxies, error) {
+	var proxies trustedProxies
+	for _, raw := range list {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "/") {
+			ip := net.ParseIP(raw)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", raw)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			raw = fmt.Sprintf("%s/%d", ip, bits)
+		}
+		_, network, err := net.ParseCIDR(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", raw, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+func (p trustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedClient reads the client address reported by a trusted proxy. In
+// X-Forwarded-For it takes the right-most hop that is not a proxy itself,
+// because everything left of it is supplied by the client.
+func (p trustedProxies) forwardedClient(r *http.Request) string {
+	if ip := strings.TrimSpace(r.Header.Get("CF-Connecting-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			return ""
+		}
+		if !p.contains(hop) {
+			return hop
+		}
+	}
+	return ""
+}
+
+// WithClientAddr rewrites RemoteAddr to the real client address for requests
+// that come through a configured trusted proxy. Anyone else keeps the socket
+// address, so rate limits cannot be dodged by sending forwarding headers.
+// Forwarding headers without any trusted proxy are logged once, since they
+// usually mean the config is missing trustedProxies.
+func (s *Server) WithClientAddr(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(s.proxies) == 0 && (r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("CF-Connecting-IP") != "") {
+			s.proxyWarning.Do(func() {
+				log.Printf("Ignoring forwarding headers from %s: set trustedProxies in the config when running behind a reverse proxy, otherwise all visitors share one address", clientIP(r))
+			})
+		}
+		if len(s.proxies) > 0 && s.proxies.contains(clientIP(r)) {
+			if ip := s.proxies.forwardedClient(r); ip != "" {
+				r = r.Clone(r.Context())
+				r.RemoteAddr = net.JoinHostPort(ip, "0")
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func AddressForLog(addr string) string {
 	if strings.HasPrefix(addr, ":") {
 		return "127.0.0.1" + addr
@@ -0,0 +1,647 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webhookEventSubmissionCreated  = "submission.created"
+	webhookEventSubmissionApproved = "submission.approved"
+	webhookEventGroupClosed        = "group.closed"
+	webhookEventPing               = "ping"
+
+	webhookStatusPending   = "pending"
+	webhookStatusDelivered = "delivered"
+	webhookStatusFailed    = "failed"
+
+	webhookSignatureHeader = "X-Grafiki-Signature"
+	webhookEventHeader     = "X-Grafiki-Event"
+	webhookDeliveryHeader  = "X-Grafiki-Delivery"
+
+	webhookMaxAttempts     = 6
+	webhookRetryBase       = 30 * time.Second
+	webhookPollInterval    = 30 * time.Second
+	webhookTimeout         = 10 * time.Second
+	webhookBatchSize       = 20
+	webhookDeliveriesLimit = 30
+	webhookErrorMax        = 300
+)
+
+type webhookEventOption struct {
+	Key   string
+	Label string
+}
+
+var webhookEvents = []webhookEventOption{
+	{webhookEventSubmissionCreated, "Nowe zgloszenie"},
+	{webhookEventSubmissionApproved, "Akceptacja zgloszenia"},
+	{webhookEventGroupClosed, "Zamkniecie grupy"},
+}
+
+var webhookStatusLabels = map[string]string{
+	webhookStatusPending:   "W kolejce",
+	webhookStatusDelivered: "Dostarczone",
+	webhookStatusFailed:    "Nieudane",
+}
+
+// webhookClient does not follow redirects so a delivery always reaches the
+// configured address or fails.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+type webhookView struct {
+	ID          int64    `json:"id"`
+	GroupID     int64    `json:"groupId,omitempty"`
+	Global      bool     `json:"global"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+	EventLabels string   `json:"eventLabels"`
+	Active      bool     `json:"active"`
+}
+
+type webhookDeliveryView struct {
+	ID           int64  `json:"id"`
+	WebhookID    int64  `json:"webhookId"`
+	URL          string `json:"url"`
+	Event        string `json:"event"`
+	Status       string `json:"status"`
+	StatusLabel  string `json:"statusLabel"`
+	Attempts     int    `json:"attempts"`
+	ResponseCode int    `json:"responseCode,omitempty"`
+	Error        string `json:"error,omitempty"`
+	CreatedAt    string `json:"createdAt"`
+	NextAttempt  string `json:"nextAttempt,omitempty"`
+}
+
+type webhookGroup struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+type webhookPayload struct {
+	Event      string                 `json:"event"`
+	CreatedAt  string                 `json:"createdAt"`
+	Group      *webhookGroup          `json:"group,omitempty"`
+	Submission *submissionExportEntry `json:"submission,omitempty"`
+}
+
+func parseWebhookEvents(raw []string) ([]string, error) {
+	var events []string
+	seen := map[string]bool{}
+	for _, event := range raw {
+		event = strings.TrimSpace(event)
+		valid := false
+		for _, option := range webhookEvents {
+			if option.Key == event {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("nieznane zdarzenie %q", event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		return nil, errors.New("wybierz co najmniej jedno zdarzenie")
+	}
+	return events, nil
+}
+
+func webhookEventLabels(events []string) string {
+	labels := make([]string, 0, len(events))
+	for _, event := range events {
+		for _, option := range webhookEvents {
+			if option.Key == event {
+				labels = append(labels, option.Label)
+			}
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+func validWebhookURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay doubles the wait after every failed attempt.
+func webhookRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return webhookRetryBase << (attempts - 1)
+}
+
+// webhooksForGroup lists global webhooks and the ones set up for a group.
+func (s *Server) webhooksForGroup(groupID int64) ([]webhookView, error) {
+	rows, err := s.db.Query(`SELECT id, group_id, url, secret, events, active FROM webhooks
+		WHERE group_id IS NULL OR group_id = ? ORDER BY group_id IS NOT NULL, id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []webhookView{}
+	for rows.Next() {
+		var hook webhookView
+		var group sql.NullInt64
+		var events string
+		if err := rows.Scan(&hook.ID, &group, &hook.URL, &hook.Secret, &events, &hook.Active); err != nil {
+			return nil, err
+		}
+		hook.GroupID = group.Int64
+		hook.Global = !group.Valid
+		hook.Events = strings.Split(events, ",")
+		hook.EventLabels = webhookEventLabels(hook.Events)
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *Server) webhookDeliveries(groupID int64) ([]webhookDeliveryView, error) {
+	rows, err := s.db.Query(`SELECT d.id, d.webhook_id, w.url, d.event, d.status, d.attempts, d.response_code, d.last_error, d.next_attempt_at, d.created_at
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE w.group_id IS NULL OR w.group_id = ? ORDER BY d.id DESC LIMIT ?`, groupID, webhookDeliveriesLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []webhookDeliveryView{}
+	for rows.Next() {
+		var delivery webhookDeliveryView
+		var code sql.NullInt64
+		var lastError sql.NullString
+		var nextAttempt int64
+		var createdAt time.Time
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.URL, &delivery.Event, &delivery.Status,
+			&delivery.Attempts, &code, &lastError, &nextAttempt, &createdAt); err != nil {
+			return nil, err
+		}
+		delivery.StatusLabel = webhookStatusLabels[delivery.Status]
+		delivery.ResponseCode = int(code.Int64)
+		delivery.Error = lastError.String
+		delivery.CreatedAt = createdAt.Local().Format("02.01.2006 15:04:05")
+		if delivery.Status == webhookStatusPending && delivery.Attempts > 0 {
+			delivery.NextAttempt = time.Unix(nextAttempt, 0).Local().Format("15:04:05")
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, rows.Err()
+}
+
+func newWebhookPayload(event string, group *submissionGroupRecord, entry *submissionEntryRecord) ([]byte, error) {
+	payload := webhookPayload{Event: event, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	if group != nil {
+		payload.Group = &webhookGroup{ID: group.ID, Name: group.Name, Slug: group.Slug}
+		if entry != nil {
+			exported := exportSubmissionEntry(group, entry)
+			payload.Submission = &exported
+		}
+	}
+	return json.Marshal(payload)
+}
+
+func (s *Server) enqueueWebhookDelivery(webhookID int64, event string, payload []byte) error {
+	_, err := s.db.Exec(`INSERT INTO webhook_deliveries (webhook_id, event, payload, status, next_attempt_at) VALUES (?, ?, ?, ?, ?)`,
+		webhookID, event, string(payload), webhookStatusPending, time.Now().Unix())
+	return err
+}
+
+// queueWebhookEvent stores a delivery for every active webhook subscribed to
+// the event. Sending happens in the background worker.
+func (s *Server) queueWebhookEvent(event string, group *submissionGroupRecord, entry *submissionEntryRecord) {
+	rows, err := s.db.Query(`SELECT id, events FROM webhooks WHERE active = 1 AND (group_id IS NULL OR group_id = ?)`, group.ID)
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		var events string
+		if err := rows.Scan(&id, &events); err != nil {
+			log.Printf("webhooks: %v", err)
+			continue
+		}
+		for _, subscribed := range strings.Split(events, ",") {
+			if subscribed == event {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return
+	}
+
+	payload, err := newWebhookPayload(event, group, entry)
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	for _, id := range ids {
+		if err := s.enqueueWebhookDelivery(id, event, payload); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+	}
+	s.wakeWebhooks()
+}
+
+func (s *Server) wakeWebhooks() {
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWebhookWorker sends queued webhook deliveries in the background and
+// notices submission groups that closed since the last check.
+func (s *Server) StartWebhookWorker() {
+	go func() {
+		ticker := time.NewTicker(webhookPollInterval)
+		defer ticker.Stop()
+		for {
+			s.notifyClosedGroups()
+			s.deliverDueWebhooks()
+			select {
+			case <-ticker.C:
+			case <-s.webhookWake:
+			}
+		}
+	}()
+}
+
+func (s *Server) notifyClosedGroups() {
+	rows, err := s.db.Query(`SELECT id, closes_at FROM submission_groups WHERE closes_at IS NOT NULL AND close_notified_at IS NULL`)
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	now := time.Now()
+	var closed []int64
+	for rows.Next() {
+		var id int64
+		var closesAt sql.NullTime
+		if err := rows.Scan(&id, &closesAt); err != nil {
+			log.Printf("webhooks: %v", err)
+			continue
+		}
+		if closesAt.Valid && !now.Before(closesAt.Time) {
+			closed = append(closed, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range closed {
+		if _, err := s.db.Exec(`UPDATE submission_groups SET close_notified_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+			log.Printf("webhooks: %v", err)
+			continue
+		}
+		group, err := s.getSubmissionGroupByID(id)
+		if err != nil {
+			log.Printf("webhooks: %v", err)
+			continue
+		}
+		s.queueWebhookEvent(webhookEventGroupClosed, group, nil)
+	}
+}
+
+// markClosedGroupsNotified records groups that are already closed as
+// notified without sending anything.
+func markClosedGroupsNotified(db *sql.DB, now time.Time) error {
+	rows, err := db.Query(`SELECT id, closes_at FROM submission_groups WHERE closes_at IS NOT NULL AND close_notified_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	var closed []int64
+	for rows.Next() {
+		var id int64
+		var closesAt sql.NullTime
+		if err := rows.Scan(&id, &closesAt); err != nil {
+			rows.Close()
+			return err
+		}
+		if closesAt.Valid && !now.Before(closesAt.Time) {
+			closed = append(closed, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range closed {
+		if _, err := db.Exec(`UPDATE submission_groups SET close_notified_at = CURRENT_TIMESTAMP WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type webhookJob struct {
+	id       int64
+	event    string
+	payload  string
+	attempts int
+	url      string
+	secret   string
+}
+
+func (s *Server) deliverDueWebhooks() {
+	rows, err := s.db.Query(`SELECT d.id, d.event, d.payload, d.attempts, w.url, w.secret
+		FROM webhook_deliveries d JOIN webhooks w ON w.id = d.webhook_id
+		WHERE d.status = ? AND d.next_attempt_at <= ? AND w.active = 1
+		ORDER BY d.next_attempt_at, d.id LIMIT ?`, webhookStatusPending, time.Now().Unix(), webhookBatchSize)
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	var jobs []webhookJob
+	for rows.Next() {
+		var job webhookJob
+		if err := rows.Scan(&job.id, &job.event, &job.payload, &job.attempts, &job.url, &job.secret); err != nil {
+			log.Printf("webhooks: %v", err)
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	rows.Close()
+
+	for _, job := range jobs {
+		code, err := sendWebhook(job)
+		job.attempts++
+		status := webhookStatusDelivered
+		var lastError sql.NullString
+		next := time.Now()
+		if err != nil {
+			message := err.Error()
+			if len(message) > webhookErrorMax {
+				message = message[:webhookErrorMax]
+			}
+			lastError = sql.NullString{String: message, Valid: true}
+			status = webhookStatusPending
+			next = next.Add(webhookRetryDelay(job.attempts))
+			if job.attempts >= webhookMaxAttempts {
+				status = webhookStatusFailed
+			}
+		}
+		if _, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, last_error = ?, next_attempt_at = ?,
+			delivered_at = CASE WHEN ? = 'delivered' THEN CURRENT_TIMESTAMP ELSE delivered_at END WHERE id = ?`,
+			status, job.attempts, sql.NullInt64{Int64: int64(code), Valid: code != 0}, lastError, next.Unix(), status, job.id); err != nil {
+			log.Printf("webhooks: %v", err)
+		}
+	}
+}
+
+// sendWebhook posts the payload signed with HMAC-SHA256 of the body. Any
+// response other than 2xx counts as a failure.
+func sendWebhook(job webhookJob) (int, error) {
+	body := []byte(job.payload)
+	req, err := http.NewRequest(http.MethodPost, job.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "grafiki-webhooks")
+	req.Header.Set(webhookEventHeader, job.event)
+	req.Header.Set(webhookDeliveryHeader, strconv.FormatInt(job.id, 10))
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(job.secret, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("odpowiedz %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *Server) deleteWebhook(id int64) error {
+	if _, err := s.db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	return err
+}
+
+func (s *Server) deleteGroupWebhooks(groupID int64) error {
+	if _, err := s.db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE group_id = ?)`, groupID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM webhooks WHERE group_id = ?`, groupID)
+	return err
+}
+
+// handleWebhooks lists webhooks with their recent deliveries or adds a new
+// one: GET /api/webhooks?group=<id> and POST /api/webhooks.
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		groupID, _ := strconv.ParseInt(r.URL.Query().Get("group"), 10, 64)
+		hooks, err := s.webhooksForGroup(groupID)
+		if err != nil {
+			log.Printf("webhooks: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac webhookow")
+			return
+		}
+		deliveries, err := s.webhookDeliveries(groupID)
+		if err != nil {
+			log.Printf("webhooks: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie pobrac webhookow")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"webhooks": hooks, "deliveries": deliveries})
+	case http.MethodPost:
+		var req struct {
+			URL     string   `json:"url"`
+			GroupID int64    `json:"groupId"`
+			Events  []string `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+			return
+		}
+		target := strings.TrimSpace(req.URL)
+		if !validWebhookURL(target) || len(target) > 500 {
+			writeJSONError(w, http.StatusBadRequest, "Podaj adres http:// lub https://")
+			return
+		}
+		events, err := parseWebhookEvents(req.Events)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		group := sql.NullInt64{}
+		if req.GroupID != 0 {
+			if _, err := s.getSubmissionGroupByID(req.GroupID); err != nil {
+				writeJSONError(w, http.StatusBadRequest, "Grupa nie istnieje")
+				return
+			}
+			group = sql.NullInt64{Int64: req.GroupID, Valid: true}
+		}
+		secret, err := randomToken()
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie utworzyc webhooka")
+			return
+		}
+		result, err := s.db.Exec(`INSERT INTO webhooks (group_id, url, secret, events) VALUES (?, ?, ?, ?)`,
+			group, target, secret, strings.Join(events, ","))
+		if err != nil {
+			log.Printf("create webhook: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie utworzyc webhooka")
+			return
+		}
+		id, _ := result.LastInsertId()
+		writeJSON(w, http.StatusCreated, webhookView{
+			ID:          id,
+			GroupID:     group.Int64,
+			Global:      !group.Valid,
+			URL:         target,
+			Secret:      secret,
+			Events:      events,
+			EventLabels: webhookEventLabels(events),
+			Active:      true,
+		})
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+	}
+}
+
+// handleWebhookByID switches a webhook on or off, removes it or sends a test
+// event: PATCH and DELETE /api/webhooks/<id>, POST /api/webhooks/<id>/test
+// and POST /api/webhooks/deliveries/<id>/retry.
+func (s *Server) handleWebhookByID(w http.ResponseWriter, r *http.Request) {
+	if !s.sessions.authenticated(w, r) {
+		writeJSONError(w, http.StatusUnauthorized, "Wymagane logowanie")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/webhooks/"), "/"), "/")
+	if len(parts) == 3 && parts[0] == "deliveries" && parts[2] == "retry" {
+		s.retryWebhookDelivery(w, r, parts[1])
+		return
+	}
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "test") {
+		http.NotFound(w, r)
+		return
+	}
+
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM webhooks WHERE id = ?`, id).Scan(&exists); err != nil || exists == 0 {
+		writeJSONError(w, http.StatusNotFound, "Webhook nie istnieje")
+		return
+	}
+
+	if len(parts) == 2 {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+			return
+		}
+		payload, err := newWebhookPayload(webhookEventPing, nil, nil)
+		if err == nil {
+			err = s.enqueueWebhookDelivery(id, webhookEventPing, payload)
+		}
+		if err != nil {
+			log.Printf("webhook test: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie wyslac testu")
+			return
+		}
+		s.wakeWebhooks()
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "ok"})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPatch:
+		var req struct {
+			Active *bool `json:"active"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Active == nil {
+			writeJSONError(w, http.StatusBadRequest, "Nieprawidlowe dane")
+			return
+		}
+		if _, err := s.db.Exec(`UPDATE webhooks SET active = ? WHERE id = ?`, *req.Active, id); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie zapisac zmian")
+			return
+		}
+		if *req.Active {
+			s.wakeWebhooks()
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": "ok", "active": *req.Active})
+	case http.MethodDelete:
+		if err := s.deleteWebhook(id); err != nil {
+			log.Printf("delete webhook: %v", err)
+			writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie usunac webhooka")
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	default:
+		w.Header().Set("Allow", "PATCH, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+	}
+}
+
+func (s *Server) retryWebhookDelivery(w http.ResponseWriter, r *http.Request, rawID string) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, "Metoda niedozwolona")
+		return
+	}
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	result, err := s.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ? WHERE id = ? AND status = ?`,
+		webhookStatusPending, time.Now().Unix(), id, webhookStatusFailed)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Nie udalo sie ponowic wysylki")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeJSONError(w, http.StatusNotFound, "Nie znaleziono nieudanej wysylki")
+		return
+	}
+	s.wakeWebhooks()
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,208 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func openTestDatabase(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := OpenDatabase(filepath.Join(t.TempDir(), "gallery.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookStandIn answers deliveries with the given status codes in turn and
+// repeats the last one.
+func webhookStandIn(t *testing.T, codes ...int) (*httptest.Server, chan webhookRequest) {
+	t.Helper()
+	requests := make(chan webhookRequest, 16)
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- webhookRequest{header: r.Header.Clone(), body: body}
+		code := codes[min(calls, len(codes)-1)]
+		calls++
+		w.WriteHeader(code)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func queueTestDelivery(t *testing.T, db *sql.DB, url, secret, payload string, attempts int) int64 {
+	t.Helper()
+	res, err := db.Exec(`INSERT INTO webhooks (url, secret, events) VALUES (?, ?, ?)`, url, secret, webhookEventPing)
+	if err != nil {
+		t.Fatalf("insert webhook: %v", err)
+	}
+	hookID, _ := res.LastInsertId()
+	res, err = db.Exec(`INSERT INTO webhook_deliveries (webhook_id, event, payload, attempts, next_attempt_at) VALUES (?, ?, ?, ?, 0)`,
+		hookID, webhookEventPing, payload, attempts)
+	if err != nil {
+		t.Fatalf("insert delivery: %v", err)
+	}
+	id, _ := res.LastInsertId()
+	return id
+}
+
+type deliveryState struct {
+	status   string
+	attempts int
+	code     sql.NullInt64
+	next     int64
+}
+
+func loadDelivery(t *testing.T, db *sql.DB, id int64) deliveryState {
+	t.Helper()
+	var state deliveryState
+	if err := db.QueryRow(`SELECT status, attempts, response_code, next_attempt_at FROM webhook_deliveries WHERE id = ?`, id).
+		Scan(&state.status, &state.attempts, &state.code, &state.next); err != nil {
+		t.Fatalf("load delivery: %v", err)
+	}
+	return state
+}
+
+func TestWebhookDeliverySignedAndRetried(t *testing.T) {
+	db := openTestDatabase(t)
+	s := &Server{db: db}
+	standIn, requests := webhookStandIn(t, http.StatusServiceUnavailable, http.StatusNoContent)
+	payload := `{"event":"ping"}`
+	id := queueTestDelivery(t, db, standIn.URL, "sekret", payload, 0)
+
+	started := time.Now()
+	s.deliverDueWebhooks()
+	req := <-requests
+	mac := hmac.New(sha256.New, []byte("sekret"))
+	mac.Write([]byte(payload))
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get(webhookSignatureHeader) != want {
+		t.Fatalf("signature = %q, want %q", req.header.Get(webhookSignatureHeader), want)
+	}
+	if string(req.body) != payload {
+		t.Fatalf("body = %q, want %q", req.body, payload)
+	}
+	if got := req.header.Get(webhookEventHeader); got != webhookEventPing {
+		t.Fatalf("event header = %q", got)
+	}
+	if got := req.header.Get(webhookDeliveryHeader); got != strconv.FormatInt(id, 10) {
+		t.Fatalf("delivery header = %q", got)
+	}
+
+	state := loadDelivery(t, db, id)
+	if state.status != webhookStatusPending || state.attempts != 1 || state.code.Int64 != http.StatusServiceUnavailable {
+		t.Fatalf("after failure: %+v", state)
+	}
+	if earliest := started.Add(webhookRetryBase).Unix(); state.next < earliest {
+		t.Fatalf("retry at %d, want not before %d", state.next, earliest)
+	}
+
+	s.deliverDueWebhooks()
+	select {
+	case <-requests:
+		t.Fatal("retried before the backoff elapsed")
+	default:
+	}
+
+	if _, err := db.Exec(`UPDATE webhook_deliveries SET next_attempt_at = 0 WHERE id = ?`, id); err != nil {
+		t.Fatal(err)
+	}
+	s.deliverDueWebhooks()
+	<-requests
+	state = loadDelivery(t, db, id)
+	if state.status != webhookStatusDelivered || state.attempts != 2 || state.code.Int64 != http.StatusNoContent {
+		t.Fatalf("after retry: %+v", state)
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	db := openTestDatabase(t)
+	s := &Server{db: db}
+	standIn, requests := webhookStandIn(t, http.StatusInternalServerError)
+	id := queueTestDelivery(t, db, standIn.URL, "sekret", `{}`, webhookMaxAttempts-1)
+
+	s.deliverDueWebhooks()
+	<-requests
+	if state := loadDelivery(t, db, id); state.status != webhookStatusFailed || state.attempts != webhookMaxAttempts {
+		t.Fatalf("after last attempt: %+v", state)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1: webhookRetryBase,
+		2: 2 * webhookRetryBase,
+		3: 4 * webhookRetryBase,
+	} {
+		if got := webhookRetryDelay(attempts); got != want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestMigrationMarksClosedGroupsNotified(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(filepath.Join(t.TempDir(), "old.db")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// A database from before close notifications.
+	if _, err := db.Exec(`CREATE TABLE submission_groups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		slug TEXT NOT NULL UNIQUE,
+		path TEXT NOT NULL UNIQUE,
+		visibility TEXT NOT NULL DEFAULT 'private',
+		shared_token TEXT UNIQUE,
+		shared_views INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		closes_at DATETIME
+	)`); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for _, group := range []struct {
+		slug     string
+		closesAt any
+	}{
+		{"closed", now.Add(-time.Hour)},
+		{"open", now.Add(time.Hour)},
+		{"unlimited", nil},
+	} {
+		if _, err := db.Exec(`INSERT INTO submission_groups (name, slug, path, closes_at) VALUES (?, ?, ?, ?)`,
+			group.slug, group.slug, group.slug, group.closesAt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := migrateDatabase(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for slug, want := range map[string]bool{"closed": true, "open": false, "unlimited": false} {
+		var notified bool
+		if err := db.QueryRow(`SELECT close_notified_at IS NOT NULL FROM submission_groups WHERE slug = ?`, slug).Scan(&notified); err != nil {
+			t.Fatal(err)
+		}
+		if notified != want {
+			t.Errorf("%s: notified = %v, want %v", slug, notified, want)
+		}
+	}
+}